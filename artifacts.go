@@ -0,0 +1,103 @@
+package expect
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"perri.to/expect/snapshots"
+)
+
+// artifactsDirEnv, if set, enables writing the actual output of failed comparisons to the directory it holds,
+// regardless of the configuration, which is handy in CI.
+const artifactsDirEnv = "EXPECT_ARTIFACTS_DIR"
+
+// ArtifactsConfig holds where the actual output of failed comparisons is written.
+type ArtifactsConfig struct {
+	// Dir is where the actual outputs are written, in a directory per package, if not set they are written next
+	// to the snapshot, as sidecars.
+	Dir string `json:"dir,omitempty"`
+}
+
+// actualPath returns where the actual output for the snapshot in snapshotFilePath is written or "" if it is not.
+func (c *Config) actualPath(snapshotFilePath string) (string, error) {
+	dir := os.Getenv(artifactsDirEnv)
+	if dir == "" {
+		if c.Artifacts == nil {
+			return "", nil
+		}
+		dir = c.Artifacts.Dir
+	}
+	name := filepath.Base(snapshotFilePath)
+	ext := filepath.Ext(name)
+	if dir == "" {
+		return snapshots.SidecarPath(snapshotFilePath, "actual"+ext), nil
+	}
+	// go test runs in the directory of the package, which tells apart snapshots with the same name.
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("determining test working directory: %w", err)
+	}
+	return filepath.Join(dir, packagePath(wd), strings.TrimSuffix(name, ext)+".actual"+ext), nil
+}
+
+// packagePath returns the directory of the package in wd relative to the module root, so packages with the same
+// name are told apart, it is "." for the root package and outside of a module.
+func packagePath(wd string) string {
+	rel, err := filepath.Rel(configDirs(wd)[0], wd)
+	if err != nil {
+		return filepath.Base(wd)
+	}
+	return rel
+}
+
+// writeActual writes what comparable dumps to the actual output path of the snapshot, if there is one, and returns
+// it.
+func writeActual(snapshotFilePath string, comparable snapshots.Comparable, config *Config) (string, error) {
+	p, err := config.actualPath(snapshotFilePath)
+	if err != nil || p == "" {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), snapshotFilePerm); err != nil {
+		return "", fmt.Errorf("creating actual output folders %w", err)
+	}
+	fd, err := os.OpenFile(p, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, snapshotFilePerm)
+	if err != nil {
+		return "", fmt.Errorf("creating actual output file %w", err)
+	}
+	defer fd.Close()
+	if err := dumpTo(fd, comparable); err != nil {
+		return "", fmt.Errorf("writing actual output %w", err)
+	}
+	return p, nil
+}
+
+// dumpTo writes what comparable dumps to w, streaming it if the comparable supports it.
+func dumpTo(w io.Writer, comparable snapshots.Comparable) error {
+	dumper, ok := comparable.(snapshots.StreamDumper)
+	if !ok {
+		_, err := w.Write(comparable.Dump())
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	if err := dumper.DumpTo(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// removeActual removes the actual output of a previous failure of the snapshot, if there is one.
+func removeActual(snapshotFilePath string, config *Config) error {
+	p, err := config.actualPath(snapshotFilePath)
+	if err != nil || p == "" {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing stale actual output %w", err)
+	}
+	return nil
+}
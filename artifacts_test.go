@@ -0,0 +1,127 @@
+package expect
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"perri.to/expect/snapshots/comparabletypes"
+)
+
+func Test_fromSnapshotWritesActual(t *testing.T) {
+	suite := NewSuite()
+	tests := []struct {
+		name      string
+		artifacts func(snapshotDir, artifactsDir string) *ArtifactsConfig
+		env       bool
+		want      func(snapshotDir, artifactsDir string) string
+	}{
+		{
+			name: "not_configured",
+			want: func(_, _ string) string { return "" },
+		},
+		{
+			name:      "sidecar",
+			artifacts: func(_, _ string) *ArtifactsConfig { return &ArtifactsConfig{} },
+			want: func(snapshotDir, _ string) string {
+				return filepath.Join(snapshotDir, "test_writes_actual.txt.sidecar.actual.txt")
+			},
+		},
+		{
+			name: "artifacts_dir",
+			artifacts: func(_, artifactsDir string) *ArtifactsConfig {
+				return &ArtifactsConfig{Dir: artifactsDir}
+			},
+			want: func(_, artifactsDir string) string {
+				return filepath.Join(artifactsDir, "test_writes_actual.actual.txt")
+			},
+		},
+		{
+			name: "environment",
+			env:  true,
+			want: func(_, artifactsDir string) string {
+				return filepath.Join(artifactsDir, "test_writes_actual.actual.txt")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshotDir, artifactsDir := t.TempDir(), t.TempDir()
+			config := &Config{SnapShotDir: snapshotDir}
+			if tt.artifacts != nil {
+				config.Artifacts = tt.artifacts(snapshotDir, artifactsDir)
+			}
+			if tt.env {
+				t.Setenv(artifactsDirEnv, artifactsDir)
+			}
+			want := tt.want(snapshotDir, artifactsDir)
+			compare := func(s string) error {
+				delete(suite.names, "test_writes_actual")
+				return suite.fromSnapshot("test_writes_actual", comparabletypes.NewStringComparable(s), false, config)
+			}
+
+			err := NewSuite(WithUpdate()).fromSnapshot("test_writes_actual",
+				comparabletypes.NewStringComparable("expected output\n"), false, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var failed *ErrTestFailed
+			if err := compare("actual output\n"); !errors.As(err, &failed) {
+				t.Fatalf("fromSnapshot() = %v, want it to fail", err)
+			}
+			if failed.actual != want {
+				t.Errorf("actual output written to %q, want %q", failed.actual, want)
+			}
+			if want == "" {
+				return
+			}
+			if !strings.HasSuffix(failed.failure, "actual output written to "+want+"\n") {
+				t.Errorf("failure %q does not name the actual output", failed.failure)
+			}
+			actual, err := os.ReadFile(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(actual) != "actual output\n" {
+				t.Errorf("actual output is %q, want %q", actual, "actual output\n")
+			}
+
+			if err := compare("expected output\n"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(want); err == nil {
+				t.Errorf("stale actual output %q was not removed", want)
+			}
+		})
+	}
+}
+
+func Test_packagePath(t *testing.T) {
+	module := t.TempDir()
+	if err := os.WriteFile(filepath.Join(module, "go.mod"), []byte("module example.com/m\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	tests := []struct {
+		wd   string
+		want string
+	}{
+		{wd: module, want: "."},
+		{wd: filepath.Join(module, "api", "v1"), want: filepath.Join("api", "v1")},
+		{wd: filepath.Join(module, "store", "v1"), want: filepath.Join("store", "v1")},
+		{wd: outside, want: "."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if err := os.MkdirAll(tt.wd, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if got := packagePath(tt.wd); got != tt.want {
+				t.Errorf("packagePath(%s) = %q, want %q", tt.wd, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,17 +1,17 @@
 package expect
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
-	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
-	"sync"
 	"testing"
 
 	"perri.to/expect/snapshots"
@@ -21,18 +21,16 @@ type Args struct {
 	shouldUpdate   bool
 	shouldCleanup  bool
 	runInArguments bool
+	color          snapshots.ColorMode
+	runDiffTool    bool
+	reportDir      string
+	junitDir       string
 }
 
-var currentRunArgs *Args
-var registeredName map[string]bool
-var registerNameMutex sync.Mutex
-
-// ran should be set to true if we ran at least one test.
-var ran bool
-
-func init() {
+// parseArgs reads the flags expect understands from the arguments of the test binary.
+func parseArgs(osArgs []string) *Args {
 	var args Args
-	for _, arg := range os.Args {
+	for _, arg := range osArgs {
 		argList := strings.Split(arg, "=")
 		if len(argList) > 0 {
 			arg = argList[0]
@@ -44,10 +42,23 @@ func init() {
 			args.shouldCleanup = true
 		case "-run", "-test.run":
 			args.runInArguments = true
+		case "-expect.difftool":
+			args.runDiffTool = true
+		case "-expect.report":
+			if len(argList) > 1 {
+				args.reportDir = strings.Join(argList[1:], "=")
+			}
+		case "-expect.junit":
+			if len(argList) > 1 {
+				args.junitDir = strings.Join(argList[1:], "=")
+			}
+		case "-expect.color":
+			if len(argList) > 1 {
+				args.color = snapshots.ColorMode(argList[1])
+			}
 		}
 	}
-	currentRunArgs = &args
-	registeredName = map[string]bool{}
+	return &args
 }
 
 const snapshotFilePerm = 0755
@@ -65,20 +76,15 @@ func (e *ErrRepeated) Is(err error) bool {
 	return isErr
 }
 
-func registerTestName(testName string) error {
-	registerNameMutex.Lock()
-	defer registerNameMutex.Unlock()
-	ran = true // we ran at least once
-	if registeredName[testName] {
-		return &ErrRepeated{repeatedSnapshot: testName}
-	}
-	registeredName[testName] = true
-	return nil
-}
-
 type fileHeader struct {
 	OS        string `json:"os"`
 	LimitToOS bool   `json:"limit_to_os"`
+	// Codec is how the body is compressed, if it is.
+	Codec Codec `json:"codec,omitempty"`
+	// Blob, if set, references the body, which is stored apart.
+	Blob *blobRef `json:"blob,omitempty"`
+	// Extension is the one of the comparable that wrote the snapshot, which can have dots, like schema.json.
+	Extension string `json:"extension,omitempty"`
 }
 
 func (f *fileHeader) dump() ([]byte, error) {
@@ -89,6 +95,28 @@ func (f *fileHeader) load(h []byte) error {
 	return json.Unmarshal(h, f)
 }
 
+// addBlobTo adds the digest of the blob of the snapshot, if it has one, to referenced.
+func (f *fileHeader) addBlobTo(referenced map[string]bool) {
+	if f.Blob != nil {
+		referenced[f.Blob.SHA256] = true
+	}
+}
+
+// snapshotName returns the name of the expectation stored in fileName, snapshots written before the extension was
+// recorded in the header are assumed to have one without dots.
+func (f *fileHeader) snapshotName(fileName string) string {
+	base := filepath.Base(fileName)
+	ext := filepath.Ext(base)
+	if f.Extension != "" && strings.HasSuffix(base, "."+f.Extension) {
+		ext = "." + f.Extension
+	}
+	name, err := url.PathUnescape(strings.TrimSuffix(base, ext))
+	if err != nil {
+		return base
+	}
+	return name
+}
+
 func (f *fileHeader) considerForCleanup() bool {
 	return (f.LimitToOS && runtime.GOOS == f.OS) || !f.LimitToOS
 }
@@ -100,11 +128,9 @@ type fileContents struct {
 
 var headerSep = []byte("\n\n")
 
+// dump writes the snapshot to fileName, if the header has a Blob the body is stored as a blob and the reference is
+// filled with its digest and size.
 func (f *fileContents) dump(fileName string) error {
-	h, err := f.header.dump()
-	if err != nil {
-		return fmt.Errorf("dumping header %w", err)
-	}
 	abs, err := filepath.Abs(fileName)
 	if err != nil {
 		return fmt.Errorf("getting abs path for dump file %w", err)
@@ -114,8 +140,22 @@ func (f *fileContents) dump(fileName string) error {
 	if err != nil {
 		return fmt.Errorf("creating snapshot folders %w", err)
 	}
+	body, err := f.header.Codec.encode(f.body)
+	if err != nil {
+		return fmt.Errorf("compressing body %w", err)
+	}
+	if f.header.Blob != nil {
+		if f.header.Blob, err = writeBlob(blobDir(fileName), body); err != nil {
+			return err
+		}
+		body = nil
+	}
+	h, err := f.header.dump()
+	if err != nil {
+		return fmt.Errorf("dumping header %w", err)
+	}
 	return os.WriteFile(fileName,
-		append(h, append(headerSep, f.body...)...),
+		append(h, append(headerSep, body...)...),
 		snapshotFilePerm)
 }
 
@@ -135,6 +175,189 @@ func readFileContents(fileName string) (*fileContents, error) {
 	return fc, nil
 }
 
+// bodyReader reads the body of a snapshot and closes its file.
+type bodyReader struct {
+	io.Reader
+	io.Closer
+}
+
+// readHeader reads the header of a snapshot from r, which is left at the start of the body.
+func readHeader(r *bufio.Reader) (*fileHeader, error) {
+	header := &fileHeader{OS: runtime.GOOS}
+	// the header is indented json, it has no blank lines, so it ends at the first one.
+	var h []byte
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF && len(h) == 0 && len(line) == 0 {
+			// empty snapshot
+			return header, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed expectation, cannot find separator")
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			break
+		}
+		h = append(h, line...)
+	}
+	if err := header.load(h); err != nil {
+		return nil, fmt.Errorf("loading snapshot header: %w", err)
+	}
+	return header, nil
+}
+
+// readFileHeader reads only the header of a snapshot.
+func readFileHeader(fileName string) (*fileHeader, error) {
+	fd, err := os.Open(fileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotSnapshotted
+		}
+		return nil, err
+	}
+	defer fd.Close()
+	return readHeader(bufio.NewReader(fd))
+}
+
+// openFileContents reads the header of a snapshot and returns a reader of its body, which must be closed.
+func openFileContents(fileName string) (*fileHeader, io.ReadCloser, error) {
+	fd, err := os.Open(fileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, ErrNotSnapshotted
+		}
+		return nil, nil, err
+	}
+	r := bufio.NewReader(fd)
+	header, err := readHeader(r)
+	if err != nil {
+		fd.Close()
+		return nil, nil, err
+	}
+	var stored io.ReadCloser = bodyReader{r, fd}
+	if header.Blob != nil {
+		fd.Close()
+		if stored, err = openBlob(blobDir(fileName), header.Blob); err != nil {
+			return nil, nil, err
+		}
+	}
+	body, err := header.Codec.decompress(stored)
+	if err != nil {
+		stored.Close()
+		return nil, nil, fmt.Errorf("decompressing body: %w", err)
+	}
+	return header, bodyReader{body, closers{body, stored}}, nil
+}
+
+// closers closes all of its closers, in order, and returns the first error.
+type closers []io.Closer
+
+func (c closers) Close() error {
+	var first error
+	for _, closer := range c {
+		if err := closer.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// OpenSnapshot returns a reader of the body of the snapshot stored in fileName, decompressed if it is, which must
+// be closed.
+func OpenSnapshot(fileName string) (io.ReadCloser, error) {
+	_, body, err := openFileContents(fileName)
+	return body, err
+}
+
+// dumpFileContentsFrom writes a snapshot with the passed header and the body written by dumper, compressed and
+// stored as a blob as configured.
+func dumpFileContentsFrom(fileName string, header *fileHeader, dumper snapshots.StreamDumper,
+	compression *CompressionConfig, blobs *BlobConfig) error {
+	if err := os.MkdirAll(filepath.Dir(fileName), snapshotFilePerm); err != nil {
+		return fmt.Errorf("creating snapshot folders %w", err)
+	}
+	if blobs.threshold() < 0 {
+		return writeFileContentsFrom(fileName, func(w io.Writer) error {
+			_, err := dumpBody(w, w, header, dumper, compression)
+			return err
+		})
+	}
+	// the size is not known until the body is dumped, so it is dumped as a blob and copied back into the snapshot
+	// if it turns out to be small.
+	blob, err := newBlobWriter(blobDir(fileName))
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(blob)
+	size, err := dumpBody(io.Discard, bw, header, dumper, compression)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		blob.abort()
+		return err
+	}
+	if size <= int64(blobs.threshold()) {
+		defer blob.abort()
+		return writeFileContentsFrom(fileName, func(w io.Writer) error {
+			h, err := header.dump()
+			if err != nil {
+				return fmt.Errorf("dumping header %w", err)
+			}
+			w.Write(h)
+			w.Write(headerSep)
+			if _, err := blob.fd.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			_, err = io.Copy(w, blob.fd)
+			return err
+		})
+	}
+	if header.Blob, err = blob.commit(); err != nil {
+		return err
+	}
+	h, err := header.dump()
+	if err != nil {
+		return fmt.Errorf("dumping header %w", err)
+	}
+	return os.WriteFile(fileName, append(h, headerSep...), snapshotFilePerm)
+}
+
+// dumpBody writes the header to headerOut and the body written by dumper, compressed as configured, to out. It
+// returns the size of the body before compressing it.
+func dumpBody(headerOut, out io.Writer, header *fileHeader, dumper snapshots.StreamDumper,
+	compression *CompressionConfig) (int64, error) {
+	sw, err := newSnapshotWriter(headerOut, out, header, compression)
+	if err != nil {
+		return 0, err
+	}
+	if err := dumper.DumpTo(sw); err != nil {
+		return 0, fmt.Errorf("dumping body %w", err)
+	}
+	if err := sw.Close(); err != nil {
+		return 0, fmt.Errorf("compressing body %w", err)
+	}
+	return sw.size, nil
+}
+
+// writeFileContentsFrom creates, or truncates, fileName with what write writes.
+func writeFileContentsFrom(fileName string, write func(w io.Writer) error) error {
+	fd, err := os.OpenFile(fileName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, snapshotFilePerm)
+	if err != nil {
+		return fmt.Errorf("creating snapshot file %w", err)
+	}
+	w := bufio.NewWriter(fd)
+	if err := write(w); err != nil {
+		fd.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		fd.Close()
+		return fmt.Errorf("writing snapshot file %w", err)
+	}
+	return fd.Close()
+}
+
 func (f *fileContents) load(fileName string) error {
 	fContent, err := os.ReadFile(fileName)
 	if err != nil {
@@ -155,49 +378,67 @@ func (f *fileContents) load(fileName string) error {
 	if err := f.header.load(fContent[:sep]); err != nil {
 		return fmt.Errorf("loading header: %w", err)
 	}
-	f.body = fContent[sep+len(headerSep):]
+	body := fContent[sep+len(headerSep):]
+	if f.header.Blob != nil {
+		if body, err = readBlob(blobDir(fileName), f.header.Blob); err != nil {
+			return err
+		}
+	}
+	f.body, err = f.header.Codec.decode(body)
+	if err != nil {
+		return fmt.Errorf("decompressing body: %w", err)
+	}
 	return nil
 }
 
 // FromSnapshot will fail if the stored information is not equal (in a non-agnostic comparison) to the passed comparabletypes.
-func FromSnapshot(t *testing.T, name string, comparable snapshots.Comparable) {
-	doCompareAndEvaluateResult(t, name, comparable, false)
+// The opts change how the comparison is made on top of the configuration, ie:
+//
+//	expect.FromSnapshot(t, "response", c, expect.WithReplacers(comparabletypes.KindJSON, replacers), expect.OSDependent())
+func FromSnapshot(t *testing.T, name string, comparable snapshots.Comparable, opts ...Option) {
+	defaultSuite.FromSnapshot(t, name, comparable, opts...)
 }
 
 // FromSnapshotWithConfig will fail if the stored information is not equal (in a non-agnostic comparison) to the passed
 // comparabletypes, the config will be overriden/updated with the passed one.
+//
+// Deprecated: use FromSnapshot with WithConfig, which merges the passed config on top of the configuration.
 func FromSnapshotWithConfig(t *testing.T, name string, comparable snapshots.Comparable, config *Config) {
-	doCompareAndEvaluateResultWithConfig(t, name, comparable, false, config)
+	defaultSuite.doCompareAndEvaluateResultWithConfig(t, name, comparable, false, config)
 }
 
 // FromOSDependentSnapshot will fail if the stored information is not equal (in a non-agnostic comparison) to the passed
 // comparabletypes but only if the OS of both matches, this should prevent weird side effect of snapshotting in different
 // machines.
+//
+// Deprecated: use FromSnapshot with OSDependent.
 func FromOSDependentSnapshot(t *testing.T, name string, comparable snapshots.Comparable) {
-	doCompareAndEvaluateResult(t, name, comparable, true)
+	defaultSuite.FromSnapshot(t, name, comparable, OSDependent())
 }
 
 // FromOSDependentSnapshotWithConfig will fail if the stored information is not equal (in a non-agnostic comparison) to the passed
 // comparabletypes but only if the OS of both matches, this should prevent weird side effect of snapshotting in different
 // machines, the config will be overriden/updated with the passed one
+//
+// Deprecated: use FromSnapshot with OSDependent and WithConfig, which merges the passed config on top of the
+// configuration.
 func FromOSDependentSnapshotWithConfig(t *testing.T, name string, comparable snapshots.Comparable, config *Config) {
-	doCompareAndEvaluateResultWithConfig(t, name, comparable, true, config)
+	defaultSuite.doCompareAndEvaluateResultWithConfig(t, name, comparable, true, config)
 }
 
 // doCompareAndEvaluateResult does the actual snapshot running and decides if and how to fail according to results.
-func doCompareAndEvaluateResult(t *testing.T, name string, comparable snapshots.Comparable, limitOs bool) {
-	config, err := ReadConfig()
+func (s *Suite) doCompareAndEvaluateResultWithConfig(t *testing.T, name string, comparable snapshots.Comparable,
+	limitOs bool, config *Config) {
+
+	err := s.fromSnapshot(name, comparable, limitOs, config)
+	file, line := testCaller()
+	var message string
 	if err != nil {
-		t.Fatal(err)
+		message = err.Error()
+		annotate(os.Stdout, config, file, line, fmt.Sprintf("snapshot %s", name), message)
 	}
-	doCompareAndEvaluateResultWithConfig(t, name, comparable, limitOs, config)
-}
-
-// doCompareAndEvaluateResult does the actual snapshot running and decides if and how to fail according to results.
-func doCompareAndEvaluateResultWithConfig(t *testing.T, name string, comparable snapshots.Comparable, limitOs bool,
-	config *Config) {
-
-	if err := fromSnapshot(name, comparable, limitOs, config); err != nil {
+	s.report.setTest(name, t.Name(), file, line, message)
+	if err != nil {
 		if errors.Is(err, ErrNotSnapshotted) {
 			t.Fatal(fmt.Errorf("expected snapshot for %s to exist: %w", name, err))
 			return
@@ -222,6 +463,8 @@ func doCompareAndEvaluateResultWithConfig(t *testing.T, name string, comparable
 // ErrTestFailed should be returned when a comparison test fails.
 type ErrTestFailed struct {
 	failure string
+	// actual is the path where the actual output was written, if it was.
+	actual string
 }
 
 // Error implements errors for ErrTestFailed
@@ -256,16 +499,105 @@ func (e *ErrTestErrored) Unwrap() error {
 	return e.err
 }
 
-// fromSnapshot loads and compares the snapshot,  it is separated form the logic that handles testing.T to ease
-// unit testing.
-func fromSnapshot(name string, comparable snapshots.Comparable, limitOS bool, config *Config) error {
-	pathName := url.PathEscape(name)
-	if err := registerTestName(pathName); err != nil {
-		return &ErrTestErrored{
-			err: fmt.Errorf("setting new expectation: %w", err),
+// configure passes the configured options for its kind to c, if it takes any, and how to render differences, with
+// colors decided by mode.
+func configure(c snapshots.Comparable, config *Config, mode snapshots.ColorMode) error {
+	if rendering, ok := c.(snapshots.Rendering); ok {
+		renderer, err := config.Renderer(mode)
+		if err != nil {
+			return fmt.Errorf("configuring the rendering of differences: %w", err)
+		}
+		rendering.SetRenderer(renderer)
+	}
+	configurable, ok := c.(snapshots.Configurable)
+	if !ok {
+		return nil
+	}
+	options, ok := config.Options[c.Kind()]
+	if !ok {
+		return nil
+	}
+	if err := configurable.Configure(options); err != nil {
+		return fmt.Errorf("configuring %s comparable: %w", c.Kind(), err)
+	}
+	return nil
+}
+
+// truncateDiff cuts diff, at a line break, if it is longer than maxSize bytes and adds a summary of what was left
+// out.
+func truncateDiff(diff string, maxSize int) string {
+	if maxSize <= 0 || len(diff) <= maxSize {
+		return diff
+	}
+	cut := strings.LastIndexByte(diff[:maxSize], '\n') + 1
+	if cut == 0 {
+		cut = maxSize
+	}
+	rest := diff[cut:]
+	lines := strings.Count(rest, "\n")
+	if !strings.HasSuffix(rest, "\n") {
+		lines++
+	}
+	return fmt.Sprintf("%s[...] difference truncated, showing %d of %d bytes, %d more lines\n",
+		diff[:cut], cut, len(diff), lines)
+}
+
+// loadExpectation loads the snapshot as a comparable of the same type as comparable, its body is also returned
+// unless the comparable loads it as a stream.
+func loadExpectation(snapshotFilePath string, comparable snapshots.Comparable) (snapshots.Comparable, []byte, error) {
+	loader, ok := comparable.(snapshots.StreamLoader)
+	if !ok {
+		fc, err := readFileContents(snapshotFilePath)
+		if err != nil {
+			return nil, nil, err
 		}
+		return comparable.Load(fc.body), fc.body, nil
+	}
+	_, body, err := openFileContents(snapshotFilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer body.Close()
+	expectation, err := loader.LoadReader(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading snapshot stream: %w", err)
 	}
+	return expectation, nil, nil
+}
 
+// writeSnapshot stores comparable as the snapshot, previous is the body of the snapshot it replaces, if any.
+func writeSnapshot(snapshotFilePath string, limitOS bool, comparable snapshots.Comparable, previous []byte,
+	config *Config) {
+	header := &fileHeader{OS: runtime.GOOS, LimitToOS: limitOS, Extension: comparable.Extension()}
+	if dumper, ok := comparable.(snapshots.StreamDumper); ok {
+		if err := dumpFileContentsFrom(snapshotFilePath, header, dumper, config.Compression, config.Blobs); err != nil {
+			panic(err)
+		}
+		if err := removeActual(snapshotFilePath, config); err != nil {
+			panic(err)
+		}
+		return
+	}
+	fcNew := fileContents{header: header, body: comparable.Dump()}
+	if merger, ok := comparable.(snapshots.Merger); ok && previous != nil {
+		fcNew.body = merger.Merge(previous)
+	}
+	header.Codec = config.Compression.codecFor(len(fcNew.body))
+	if threshold := config.Blobs.threshold(); threshold >= 0 && len(fcNew.body) > threshold {
+		header.Blob = &blobRef{}
+	}
+	if err := fcNew.dump(snapshotFilePath); err != nil {
+		panic(err)
+	}
+	if err := removeActual(snapshotFilePath, config); err != nil {
+		panic(err)
+	}
+}
+
+// fromSnapshot loads and compares the snapshot,  it is separated form the logic that handles testing.T to ease
+// unit testing.
+func (s *Suite) fromSnapshot(name string, comparable snapshots.Comparable, limitOS bool, config *Config) error {
+	pathName := url.PathEscape(name)
 	// get the test file name just in case snapshot dir needs it
 	_, fileName, _, _ := runtime.Caller(0)
 	packageSnapshotDir := config.SnapshotDir(fileName)
@@ -273,27 +605,66 @@ func fromSnapshot(name string, comparable snapshots.Comparable, limitOS bool, co
 	if ext := comparable.Extension(); ext != "" {
 		snapshotFilePath = fmt.Sprintf("%s.%s", snapshotFilePath, ext)
 	}
+	if err := s.registerTestName(pathName, filepath.Base(snapshotFilePath)); err != nil {
+		return &ErrTestErrored{
+			err: fmt.Errorf("setting new expectation: %w", err),
+		}
+	}
 
-	updatingSnapshot := currentRunArgs != nil && currentRunArgs.shouldUpdate
+	// the outcome is set before each return, it is errored unless said otherwise.
+	entry := newReportEntry(name, snapshotFilePath, comparable.Kind(), OutcomeErrored)
+	defer func() { s.report.add(entry) }()
 
-	fc, err := readFileContents(snapshotFilePath)
+	if locator, ok := comparable.(snapshots.SnapshotLocator); ok {
+		locator.SetSnapshotPath(snapshotFilePath)
+	}
+	if err := configure(comparable, config, s.args.color); err != nil {
+		return &ErrTestErrored{err: err}
+	}
+	if err := config.Compression.validate(); err != nil {
+		return &ErrTestErrored{err: err}
+	}
+	timeout, err := config.compareTimeout()
 	if err != nil {
-		if updatingSnapshot && errors.Is(err, ErrNotSnapshotted) {
-			fcNew := fileContents{
-				header: &fileHeader{OS: runtime.GOOS, LimitToOS: limitOS},
-				body:   comparable.Dump(),
-			}
-			if err := fcNew.dump(snapshotFilePath); err != nil {
-				panic(err)
+		return &ErrTestErrored{err: err}
+	}
+
+	updatingSnapshot := s.args.shouldUpdate
+
+	// update writes comparable as the snapshot, unless it can not be stored.
+	update := func(previous []byte, outcome Outcome) error {
+		if validator, ok := comparable.(snapshots.Validator); ok {
+			if err := validator.Validate(); err != nil {
+				return &ErrTestErrored{err: fmt.Errorf("not writing an invalid result as the snapshot: %w", err)}
 			}
-			return nil
+		}
+		writeSnapshot(snapshotFilePath, limitOS, comparable, previous, config)
+		entry.Outcome = outcome
+		// the sidecars of the comparison, ie: a diff image, do not apply to the new snapshot.
+		if err := removeSidecars(snapshotFilePath); err != nil {
+			return &ErrTestErrored{err: err}
+		}
+		return nil
+	}
+
+	expectation, previous, err := loadExpectation(snapshotFilePath, comparable)
+	if err != nil {
+		if updatingSnapshot && errors.Is(err, ErrNotSnapshotted) {
+			return update(nil, OutcomeCreated)
 		}
 		return &ErrTestErrored{
 			err: fmt.Errorf("loading expectations file: %w", err),
 		}
 	}
-
-	expectation := comparable.Load(fc.body)
+	if closer, ok := expectation.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if locator, ok := expectation.(snapshots.SnapshotLocator); ok {
+		locator.SetSnapshotPath(snapshotFilePath)
+	}
+	if err := configure(expectation, config, s.args.color); err != nil {
+		return &ErrTestErrored{err: err}
+	}
 	// time to replace, comparable will know how to.
 	if replaceable, ok := config.Replacers[expectation.Kind()]; ok {
 		expectation.Replace(replaceable)
@@ -307,73 +678,79 @@ func fromSnapshot(name string, comparable snapshots.Comparable, limitOS bool, co
 		comparable.ReplaceSubtypes(config.Replacers)
 	}
 
-	diff, err := expectation.CompareTo(comparable)
+	diff, err := compare(expectation, comparable, timeout)
 	if err != nil {
 		// we are updating, don't care
 		if updatingSnapshot {
-			fcNew := fileContents{
-				header: &fileHeader{OS: runtime.GOOS, LimitToOS: limitOS},
-				body:   comparable.Dump(),
-			}
-			if err := fcNew.dump(snapshotFilePath); err != nil {
-				panic(err)
-			}
-			return nil
+			return update(previous, OutcomeUpdated)
 		}
 		return &ErrTestErrored{
 			err: fmt.Errorf("comparing expectation to result: %w", err),
 		}
 	}
 	if diff != "" {
+		entry.DiffSize = len(diff)
 		// we are updating, we only do so if there are differences
 		if updatingSnapshot {
-			fcNew := fileContents{
-				header: &fileHeader{OS: runtime.GOOS, LimitToOS: limitOS},
-				body:   comparable.Dump(),
-			}
-			if err := fcNew.dump(snapshotFilePath); err != nil {
-				panic(err)
-			}
-			return nil
+			return update(previous, OutcomeUpdated)
+		}
+		failure := truncateDiff(diff, config.MaxDiffSize)
+		actual, err := writeActual(snapshotFilePath, comparable, config)
+		if err != nil {
+			return &ErrTestErrored{err: err}
+		}
+		if actual != "" {
+			failure += fmt.Sprintf("actual output written to %s\n", actual)
+		}
+		tool, err := runDiffTool(snapshotFilePath, expectation, comparable, config, s.args.runDiffTool)
+		if err != nil {
+			return &ErrTestErrored{err: err}
 		}
-		return &ErrTestFailed{failure: diff}
+		failure += tool
+		entry.Outcome = OutcomeFailed
+		return &ErrTestFailed{failure: failure, actual: actual}
+	}
+	if err := removeActual(snapshotFilePath, config); err != nil {
+		return &ErrTestErrored{err: err}
 	}
+	entry.Outcome = OutcomeMatched
 	return nil
 }
 
 // Cleanup should be called in TestMain AFTER m.Run() to remove stale snapshots
 func Cleanup() error {
-	config, err := ReadConfig()
-	if err != nil {
-		return fmt.Errorf("cleaning up stale snapshots: %w", err)
-	}
-	return cleanup(config, false)
+	return defaultSuite.Cleanup()
 }
 
 // MustCleanup will do exactly as Cleanup but also fail if a cleanup was due and no flag was passed
 func MustCleanup() error {
-	config, err := ReadConfig()
-	if err != nil {
-		return fmt.Errorf("cleaning up stale snapshots: %w", err)
+	return defaultSuite.MustCleanup()
+}
+
+// cleanupAndReport cleans up and writes the run report, if it is enabled, even if the cleanup fails.
+func (s *Suite) cleanupAndReport(config *Config, must bool) error {
+	err := s.cleanup(config, must)
+	if reportErr := s.writeReport(config); err == nil {
+		err = reportErr
 	}
-	return cleanup(config, true)
+	return err
 }
 
-func cleanup(config *Config, must bool) error {
-	registerNameMutex.Lock()
-	defer registerNameMutex.Unlock()
+func (s *Suite) cleanup(config *Config, must bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	if currentRunArgs != nil && currentRunArgs.runInArguments {
+	if s.args.runInArguments {
 		fmt.Println("skipping cleanup because -run was used")
 		return fmt.Errorf("skipping cleanup because -run was used")
 	}
 
-	if !ran {
+	if !s.ran {
 		return fmt.Errorf("must run a test before cleaning up")
 	}
 
-	shouldCleanup := currentRunArgs != nil && currentRunArgs.shouldCleanup
-	if !must && (currentRunArgs == nil || !shouldCleanup) {
+	shouldCleanup := s.args.shouldCleanup
+	if !must && !shouldCleanup {
 		return nil
 	}
 
@@ -383,25 +760,35 @@ func cleanup(config *Config, must bool) error {
 	if err != nil {
 		return fmt.Errorf("reading snapshot directory contents: %w", err)
 	}
-	var deletable []string
+	var deletable, sidecars []string
+	// referenced holds the digests of the blobs of the snapshots that are kept.
+	referenced := map[string]bool{}
+	// kept holds the file names of the snapshots that are kept.
+	kept := map[string]bool{}
+	// names holds the expectation names of the deletable snapshots, blobs are named after their digest.
+	names := map[string]string{}
 	for _, entry := range dirContents {
-		p := filepath.Join(packageSnapshotDir, entry.Name())
-		fc, err := readFileContents(p)
-		if err != nil {
-			return fmt.Errorf("loading file contents: %w", err)
+		if entry.IsDir() {
+			continue
 		}
-		if !fc.header.considerForCleanup() {
+		p := filepath.Join(packageSnapshotDir, entry.Name())
+		header, err := readFileHeader(p)
+		// auxiliary files, like diff images, are not snapshots, they go with theirs. Snapshots named like them still
+		// have a header.
+		if err != nil && snapshots.IsSidecar(entry.Name()) {
+			sidecars = append(sidecars, entry.Name())
 			continue
 		}
-		fName := entry.Name()
-		ext := path.Ext(entry.Name())
-		if ext != fName && len(ext) > 0 {
-			fName = strings.TrimSuffix(fName, ext)
+		if err != nil {
+			return fmt.Errorf("loading file contents: %w", err)
 		}
-		if registeredName[fName] {
+		if !header.considerForCleanup() || s.files[entry.Name()] {
+			header.addBlobTo(referenced)
+			kept[entry.Name()] = true
 			continue
 		}
 		deletable = append(deletable, p)
+		names[p] = header.snapshotName(p)
 		if must && !shouldCleanup {
 			cleanName, err := url.PathUnescape(entry.Name())
 			if err != nil {
@@ -410,9 +797,29 @@ func cleanup(config *Config, must bool) error {
 			fmt.Printf("CLEANUP: There is a snapshot for expectation %q but the expectation no longer exist\n", cleanName)
 		}
 	}
+	blobs, err := unreferencedBlobs(filepath.Join(packageSnapshotDir, blobsDir), referenced)
+	if err != nil {
+		return err
+	}
+	if must && !shouldCleanup {
+		for _, b := range blobs {
+			fmt.Printf("CLEANUP: There is a blob %q that no snapshot references\n", filepath.Base(b))
+		}
+	}
+	deletable = append(deletable, blobs...)
+	staleSidecars, err := s.staleSidecars(packageSnapshotDir, sidecars, kept)
+	if err != nil {
+		return err
+	}
+	if must && !shouldCleanup {
+		for _, sc := range staleSidecars {
+			fmt.Printf("CLEANUP: There is a sidecar %q that no comparison of this run wrote\n", filepath.Base(sc))
+		}
+	}
 	if !shouldCleanup {
-		if must && len(deletable) > 0 {
-			return fmt.Errorf("we found %d expectation snapshots that need cleanup", len(deletable))
+		if must && len(deletable)+len(staleSidecars) > 0 {
+			return fmt.Errorf("we found %d expectation snapshots, blobs or sidecars that need cleanup",
+				len(deletable)+len(staleSidecars))
 		}
 		return nil
 	}
@@ -420,6 +827,51 @@ func cleanup(config *Config, must bool) error {
 		if err := os.Remove(d); err != nil {
 			return fmt.Errorf("deleting stale snapshot, %d were deleted before failure: %w", i, err)
 		}
+		name, ok := names[d]
+		if !ok {
+			name = filepath.Base(d)
+		}
+		s.report.add(newReportEntry(name, d, "", OutcomeDeleted))
+	}
+	for _, sc := range staleSidecars {
+		if err := os.Remove(sc); err != nil {
+			return fmt.Errorf("deleting stale sidecar: %w", err)
+		}
 	}
 	return nil
 }
+
+// removeSidecars removes the sidecars of the snapshot in snapshotFilePath.
+func removeSidecars(snapshotFilePath string) error {
+	entries, err := os.ReadDir(filepath.Dir(snapshotFilePath))
+	if err != nil {
+		return fmt.Errorf("reading snapshot directory contents: %w", err)
+	}
+	for _, entry := range entries {
+		p := filepath.Join(filepath.Dir(snapshotFilePath), entry.Name())
+		if entry.IsDir() || snapshots.SidecarOf(p) != snapshotFilePath {
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			return fmt.Errorf("removing sidecar of the updated snapshot: %w", err)
+		}
+	}
+	return nil
+}
+
+// staleSidecars returns the paths of the sidecars, in dir, whose snapshot is not kept or that are older than the
+// run, a comparison of this run either rewrote or removed the sidecars of its snapshot.
+func (s *Suite) staleSidecars(dir string, sidecars []string, kept map[string]bool) ([]string, error) {
+	var stale []string
+	for _, name := range sidecars {
+		p := filepath.Join(dir, name)
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("reading sidecar: %w", err)
+		}
+		if !kept[filepath.Base(snapshots.SidecarOf(p))] || info.ModTime().Before(s.started) {
+			stale = append(stale, p)
+		}
+	}
+	return stale, nil
+}
@@ -1,24 +1,32 @@
 package expect
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"sort"
+	"strings"
 	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/sourcecontextpb"
 
 	"perri.to/expect/snapshots"
 	"perri.to/expect/snapshots/comparabletypes"
 )
 
 func Test_fromSnapshot(t *testing.T) {
+	suite := NewSuite()
 	type args struct {
 		name       string
 		comparable snapshots.Comparable
 		limitOS    bool
 		config     *Config
 	}
-	// currentRunArgs = &Args{shouldUpdate: true} // handy for new tests
+	// suite := NewSuite(WithUpdate()) // handy for new tests
 	tests := []struct {
 		name    string
 		args    args
@@ -78,10 +86,23 @@ func Test_fromSnapshot(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "invalid_options_fail",
+			args: args{
+				name:       "test_from_snapshot_04",
+				comparable: comparabletypes.NewStringComparable("Hello World"),
+				limitOS:    false,
+				config: &Config{
+					Grouping: groupByPackage,
+					Options:  map[snapshots.Kind]json.RawMessage{comparabletypes.KindString: []byte(`{"diff": "sideways"}`)},
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := fromSnapshot(tt.args.name, tt.args.comparable, tt.args.limitOS, tt.args.config); (err != nil) != tt.wantErr {
+			if err := suite.fromSnapshot(tt.args.name, tt.args.comparable, tt.args.limitOS, tt.args.config); (err != nil) != tt.wantErr {
 				t.Errorf("fromSnapshot(%s) error = %v, wantErr %v", tt.args.name, err, tt.wantErr)
 			}
 		})
@@ -106,9 +127,13 @@ func Test_cleanup(t *testing.T) {
 		deletables   []string
 		conservables []string
 		osSpareAbles []string
-		runArgument  bool
-		args         args
-		wantErr      bool
+		sidecars     []string
+		// staleSidecars are expected to be deleted, oldSidecars too, they predate the run.
+		staleSidecars []string
+		oldSidecars   []string
+		runArgument   bool
+		args          args
+		wantErr       bool
 	}{
 		{
 			name:         "cleanup_snapshot_folder",
@@ -121,6 +146,53 @@ func Test_cleanup(t *testing.T) {
 			}},
 			wantErr: false,
 		},
+		{
+			name:         "cleanup_snapshot_folder_multi_dot_extensions",
+			conservables: []string{"one.schema.json", "two.pb.json"},
+			deletables:   []string{"three.schema.json"},
+			args: args{config: &Config{
+				Grouping:    "",
+				SnapShotDir: t.TempDir(),
+				Replacers:   nil,
+			}},
+			wantErr: false,
+		},
+		{
+			name:         "cleanup_snapshot_folder_with_sidecars",
+			conservables: []string{"one.png"},
+			sidecars:     []string{"one.png.sidecar.diff.png"},
+			args: args{config: &Config{
+				Grouping:    "",
+				SnapShotDir: t.TempDir(),
+				Replacers:   nil,
+			}},
+			wantErr: false,
+		},
+		{
+			name:          "cleanup_snapshot_folder_with_stale_sidecars",
+			conservables:  []string{"one.png", "two.png"},
+			deletables:    []string{"three.png"},
+			sidecars:      []string{"one.png.sidecar.diff.png"},
+			staleSidecars: []string{"three.png.sidecar.diff.png", "four.png.sidecar.diff.png"},
+			oldSidecars:   []string{"two.png.sidecar.diff.png"},
+			args: args{config: &Config{
+				Grouping:    "",
+				SnapShotDir: t.TempDir(),
+				Replacers:   nil,
+			}},
+			wantErr: false,
+		},
+		{
+			name:         "cleanup_snapshot_folder_with_snapshots_named_like_sidecars",
+			conservables: []string{"one.sidecar.kept.txt"},
+			deletables:   []string{"two.sidecar.txt", "three.sidecar.gone.txt"},
+			args: args{config: &Config{
+				Grouping:    "",
+				SnapShotDir: t.TempDir(),
+				Replacers:   nil,
+			}},
+			wantErr: false,
+		},
 		{
 			name: "cleanup_snapshot_folder_run_argument",
 			args: args{config: &Config{
@@ -134,7 +206,8 @@ func Test_cleanup(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			currentRunArgs = &Args{shouldUpdate: true, shouldCleanup: true, runInArguments: tt.runArgument}
+			suite := NewSuite(WithUpdate(), WithCleanup())
+			suite.args.runInArguments = tt.runArgument
 			for _, dc := range append(tt.deletables, tt.conservables...) {
 				_, err := os.Stat(tt.args.config.SnapShotDir)
 				if err != nil {
@@ -170,15 +243,27 @@ Hello World`)
 Hello World`, deletableOS))
 				fd.Close()
 			}
-			registeredName = map[string]bool{}
+			for _, sc := range append(append(tt.sidecars, tt.staleSidecars...), tt.oldSidecars...) {
+				if err := os.WriteFile(filepath.Join(tt.args.config.SnapShotDir, sc), []byte{0x89, 'P', 'N', 'G'},
+					snapshotFilePerm); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, sc := range tt.oldSidecars {
+				before := suite.started.Add(-time.Hour)
+				if err := os.Chtimes(filepath.Join(tt.args.config.SnapShotDir, sc), before, before); err != nil {
+					t.Fatal(err)
+				}
+			}
+			suite.files = map[string]bool{}
 			for _, c := range tt.conservables {
-				registeredName[c] = true
+				suite.files[c] = true
 			}
-			ran = true
-			if err := cleanup(tt.args.config, false); (err != nil) != tt.wantErr {
+			suite.ran = true
+			if err := suite.cleanup(tt.args.config, false); (err != nil) != tt.wantErr {
 				t.Errorf("cleanup() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			for _, c := range append(tt.conservables, tt.osSpareAbles...) {
+			for _, c := range append(append(tt.conservables, tt.osSpareAbles...), tt.sidecars...) {
 				fPath := filepath.Join(tt.args.config.SnapShotDir, c)
 				_, err := os.Stat(fPath)
 				if err != nil {
@@ -186,7 +271,7 @@ Hello World`, deletableOS))
 					t.FailNow()
 				}
 			}
-			for _, d := range tt.deletables {
+			for _, d := range append(append(tt.deletables, tt.staleSidecars...), tt.oldSidecars...) {
 				fPath := filepath.Join(tt.args.config.SnapShotDir, d)
 				_, err := os.Stat(fPath)
 				if err == nil {
@@ -194,7 +279,209 @@ Hello World`, deletableOS))
 					t.FailNow()
 				}
 			}
-			currentRunArgs = &Args{shouldUpdate: false, shouldCleanup: false}
 		})
 	}
 }
+
+func Test_fromSnapshotUpdateKeepsMarkers(t *testing.T) {
+	suite := NewSuite(WithUpdate())
+	config := &Config{SnapShotDir: t.TempDir()}
+	snapshotPath := filepath.Join(config.SnapShotDir, "test_from_snapshot_markers.txt")
+	previous := fileContents{
+		header: &fileHeader{OS: runtime.GOOS},
+		body:   []byte("took [[REGEX:\\d+ms]]\nresult: 1\n"),
+	}
+	if err := previous.dump(snapshotPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := suite.fromSnapshot("test_from_snapshot_markers", comparabletypes.NewStringComparable("took 31ms\nresult: 2\n"),
+		false, config); err != nil {
+		t.Fatal(err)
+	}
+	fc, err := readFileContents(snapshotPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "took [[REGEX:\\d+ms]]\nresult: 2\n"; string(fc.body) != want {
+		t.Errorf("updated snapshot is %q, want %q", fc.body, want)
+	}
+}
+
+func Test_fromSnapshotStream(t *testing.T) {
+	suite := NewSuite()
+	config := &Config{SnapShotDir: t.TempDir(), MaxDiffSize: 90}
+	snapshotPath := filepath.Join(config.SnapShotDir, "test_from_snapshot_stream.txt")
+	var dump strings.Builder
+	for i := 0; i < 1000; i++ {
+		dump.WriteString(fmt.Sprintf("packet %d\n", i))
+	}
+	stream := func(s string) *comparabletypes.Stream {
+		st, err := comparabletypes.NewStreamComparable(strings.NewReader(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { st.Close() })
+		// each call registers the name again.
+		delete(suite.names, "test_from_snapshot_stream")
+		return st
+	}
+
+	err := NewSuite(WithUpdate()).fromSnapshot("test_from_snapshot_stream", stream(dump.String()), false, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc, err := readFileContents(snapshotPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(fc.body) != dump.String() {
+		t.Errorf("stored snapshot is %d bytes, want %d", len(fc.body), dump.Len())
+	}
+
+	if err := suite.fromSnapshot("test_from_snapshot_stream", stream(dump.String()), false, config); err != nil {
+		t.Errorf("fromSnapshot() with the same stream: %v", err)
+	}
+	err = suite.fromSnapshot("test_from_snapshot_stream", stream(strings.ReplaceAll(dump.String(), "packet", "frame")),
+		false, config)
+	want := `Size: expected 10890 bytes but got 9890
+line 1: expected "packet 0" but got "frame 0"
+[...] difference truncated, showing 86 of 536 bytes, 10 more lines
+`
+	if failed, ok := err.(*ErrTestFailed); !ok || failed.failure != want {
+		t.Errorf("fromSnapshot() with a different stream = %v, want failure:\n%s", err, want)
+	}
+}
+
+func Test_fromSnapshotUpdateRefusesInvalidResults(t *testing.T) {
+	config := &Config{SnapShotDir: t.TempDir()}
+	err := NewSuite(WithUpdate()).fromSnapshot("test_from_snapshot_invalid",
+		comparabletypes.NewProtoComparable(nil, comparabletypes.ProtoJSON), false, config)
+	if _, ok := err.(*ErrTestErrored); !ok {
+		t.Errorf("fromSnapshot() error = %v, want the test errored", err)
+	}
+	if _, err := os.Stat(filepath.Join(config.SnapShotDir, "test_from_snapshot_invalid.pb.json")); err == nil {
+		t.Error("fromSnapshot() wrote a snapshot of an invalid result")
+	}
+}
+
+func Test_mustCleanupCountsSidecars(t *testing.T) {
+	suite := NewSuite()
+	config := &Config{SnapShotDir: t.TempDir()}
+	writeTestFiles(t, config.SnapShotDir, map[string]string{
+		"gone.txt":                  "{\n  \"os\": \"linux\",\n  \"limit_to_os\": false\n}\n\nbody",
+		"gone.txt.sidecar.diff.png": "png",
+		"lost.png.sidecar.diff.png": "png",
+	})
+	suite.ran = true
+	err := suite.cleanup(config, true)
+	if want := "we found 3 expectation snapshots, blobs or sidecars that need cleanup"; err == nil || err.Error() != want {
+		t.Errorf("cleanup() error = %v, want %q", err, want)
+	}
+}
+
+func Test_fromSnapshotUpdateRemovesSidecars(t *testing.T) {
+	config := &Config{SnapShotDir: t.TempDir()}
+	suite := NewSuite(WithUpdate())
+	if err := suite.fromSnapshot("updated", comparabletypes.NewStringComparable("before"), false, config); err != nil {
+		t.Fatal(err)
+	}
+	sidecar := filepath.Join(config.SnapShotDir, "updated.txt.sidecar.diff.png")
+	if err := os.WriteFile(sidecar, []byte("png"), snapshotFilePerm); err != nil {
+		t.Fatal(err)
+	}
+	delete(suite.names, "updated")
+	if err := suite.fromSnapshot("updated", comparabletypes.NewStringComparable("after"), false, config); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(sidecar); err == nil {
+		t.Error("fromSnapshot() left the sidecar of the previous snapshot")
+	}
+}
+
+func Test_truncateDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		diff    string
+		maxSize int
+		want    string
+	}{
+		{
+			name: "no_limit",
+			diff: "a\nb\nc\n",
+			want: "a\nb\nc\n",
+		},
+		{
+			name:    "within_limit",
+			diff:    "a\nb\nc\n",
+			maxSize: 6,
+			want:    "a\nb\nc\n",
+		},
+		{
+			name:    "cut_at_line_break",
+			diff:    "first\nsecond\nthird\n",
+			maxSize: 10,
+			want:    "first\n[...] difference truncated, showing 6 of 19 bytes, 2 more lines\n",
+		},
+		{
+			name:    "single_long_line",
+			diff:    "abcdefghij",
+			maxSize: 4,
+			want:    "abcd[...] difference truncated, showing 4 of 10 bytes, 1 more lines\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateDiff(tt.diff, tt.maxSize); got != tt.want {
+				t.Errorf("truncateDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_cleanupKeepsComparedSnapshots(t *testing.T) {
+	suite := NewSuite(WithUpdate())
+	config := &Config{SnapShotDir: t.TempDir()}
+	schema := comparabletypes.NewJSONSchemaFromString(`{"type": "object"}`)
+	if err := suite.fromSnapshot("schema", schema, false, config); err != nil {
+		t.Fatal(err)
+	}
+	message := comparabletypes.NewProtoComparable(&sourcecontextpb.SourceContext{FileName: "a.proto"},
+		comparabletypes.ProtoJSON)
+	if err := suite.fromSnapshot("message", message, false, config); err != nil {
+		t.Fatal(err)
+	}
+	// a must cleanup fails if any snapshot would be deleted.
+	if err := suite.cleanup(config, true); err != nil {
+		t.Errorf("cleanup() error = %v, the compared snapshot was taken for stale", err)
+	}
+}
+
+func Test_cleanupReportsDeletedNames(t *testing.T) {
+	suite := NewSuite(WithCleanup())
+	config := &Config{SnapShotDir: t.TempDir()}
+	files := map[string]*fileHeader{
+		"three.schema.json": {OS: runtime.GOOS, Extension: "schema.json"},
+		"one%20two.pb.txt":  {OS: runtime.GOOS, Extension: "pb.txt"},
+		// written before the extension was recorded.
+		"four.txt": {OS: runtime.GOOS},
+	}
+	for name, header := range files {
+		fc := fileContents{header: header, body: []byte("Hello World")}
+		if err := fc.dump(filepath.Join(config.SnapShotDir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	suite.ran = true
+	if err := suite.cleanup(config, false); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range suite.report.Snapshots {
+		got = append(got, e.Name)
+	}
+	sort.Strings(got)
+	want := []string{"four", "one two", "three"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("deleted names = %q, want %q", got, want)
+	}
+}
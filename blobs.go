@@ -0,0 +1,182 @@
+package expect
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// blobsDir is the directory, within the snapshot directory, where the bodies stored apart are kept, each in a file
+// named after the sha256 digest of its content so equal bodies are stored once.
+const blobsDir = "blobs"
+
+// DefaultBlobThreshold is the size, in bytes, above which bodies are stored as blobs when no threshold is set.
+const DefaultBlobThreshold = 4 << 20
+
+// BlobConfig holds how large snapshot bodies are stored apart from the snapshot files.
+type BlobConfig struct {
+	// Threshold is the size, in bytes and before compression, above which bodies are stored as blobs,
+	// DefaultBlobThreshold if not set.
+	Threshold int `json:"threshold,omitempty"`
+}
+
+// threshold returns the size above which bodies are stored as blobs, -1 if they are not.
+func (c *BlobConfig) threshold() int {
+	switch {
+	case c == nil:
+		return -1
+	case c.Threshold <= 0:
+		return DefaultBlobThreshold
+	}
+	return c.Threshold
+}
+
+// blobRef is how a snapshot header references its body when it is stored as a blob.
+type blobRef struct {
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// blobDir returns the directory where the blobs of the snapshot in snapshotFilePath are.
+func blobDir(snapshotFilePath string) string {
+	return filepath.Join(filepath.Dir(snapshotFilePath), blobsDir)
+}
+
+// blobWriter writes a blob to a temporary file while hashing it, it is stored under its digest once committed.
+type blobWriter struct {
+	dir  string
+	fd   *os.File
+	hash hash.Hash
+	size int64
+}
+
+func newBlobWriter(dir string) (*blobWriter, error) {
+	if err := os.MkdirAll(dir, snapshotFilePerm); err != nil {
+		return nil, fmt.Errorf("creating blob folder %w", err)
+	}
+	fd, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating blob file %w", err)
+	}
+	return &blobWriter{dir: dir, fd: fd, hash: sha256.New()}, nil
+}
+
+func (b *blobWriter) Write(p []byte) (int, error) {
+	n, err := b.fd.Write(p)
+	b.hash.Write(p[:n])
+	b.size += int64(n)
+	return n, err
+}
+
+// commit stores the blob under its digest, if there was one already with that content the new one is dropped.
+func (b *blobWriter) commit() (*blobRef, error) {
+	if err := b.fd.Close(); err != nil {
+		os.Remove(b.fd.Name())
+		return nil, fmt.Errorf("writing blob file %w", err)
+	}
+	ref := &blobRef{SHA256: hex.EncodeToString(b.hash.Sum(nil)), Size: b.size}
+	p := filepath.Join(b.dir, ref.SHA256)
+	if _, err := os.Stat(p); err == nil {
+		return ref, os.Remove(b.fd.Name())
+	}
+	if err := os.Chmod(b.fd.Name(), snapshotFilePerm); err != nil {
+		return nil, fmt.Errorf("setting blob permissions %w", err)
+	}
+	if err := os.Rename(b.fd.Name(), p); err != nil {
+		return nil, fmt.Errorf("storing blob %w", err)
+	}
+	return ref, nil
+}
+
+// abort drops the blob.
+func (b *blobWriter) abort() error {
+	b.fd.Close()
+	return os.Remove(b.fd.Name())
+}
+
+// writeBlob stores body as a blob in dir.
+func writeBlob(dir string, body []byte) (*blobRef, error) {
+	b, err := newBlobWriter(dir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := b.Write(body); err != nil {
+		b.abort()
+		return nil, fmt.Errorf("writing blob file %w", err)
+	}
+	return b.commit()
+}
+
+// ErrCorruptedBlob is returned when a blob does not have the size or digest its snapshot header records.
+var ErrCorruptedBlob = errors.New("corrupted blob")
+
+// openBlob returns a reader of the blob ref in dir, reading it fails at the end if its content does not match the
+// reference.
+func openBlob(dir string, ref *blobRef) (io.ReadCloser, error) {
+	fd, err := os.Open(filepath.Join(dir, ref.SHA256))
+	if err != nil {
+		return nil, fmt.Errorf("opening blob %s: %w", ref.SHA256, err)
+	}
+	return &verifyingReader{fd: fd, ref: ref, hash: sha256.New()}, nil
+}
+
+// readBlob returns the content of the blob ref in dir.
+func readBlob(dir string, ref *blobRef) ([]byte, error) {
+	r, err := openBlob(dir, ref)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var b bytes.Buffer
+	if _, err := io.Copy(&b, r); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// verifyingReader reads a blob and checks, once it is read to the end, that it matches its reference.
+type verifyingReader struct {
+	fd   *os.File
+	ref  *blobRef
+	hash hash.Hash
+	size int64
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.fd.Read(p)
+	v.hash.Write(p[:n])
+	v.size += int64(n)
+	if err == io.EOF && (v.size != v.ref.Size || hex.EncodeToString(v.hash.Sum(nil)) != v.ref.SHA256) {
+		return n, fmt.Errorf("%w: %s does not match its digest or size", ErrCorruptedBlob, v.ref.SHA256)
+	}
+	return n, err
+}
+
+func (v *verifyingReader) Close() error {
+	return v.fd.Close()
+}
+
+// unreferencedBlobs returns the paths of the blobs in dir that are not in referenced, by digest.
+func unreferencedBlobs(dir string, referenced map[string]bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading blob directory contents: %w", err)
+	}
+	var unreferenced []string
+	for _, entry := range entries {
+		if entry.IsDir() || referenced[entry.Name()] {
+			continue
+		}
+		unreferenced = append(unreferenced, filepath.Join(dir, entry.Name()))
+	}
+	return unreferenced, nil
+}
@@ -0,0 +1,163 @@
+package expect
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"perri.to/expect/snapshots"
+	"perri.to/expect/snapshots/comparabletypes"
+)
+
+func Test_writeSnapshotBlobs(t *testing.T) {
+	large := strings.Repeat("a line of a protocol dump\n", 100)
+	tests := []struct {
+		name        string
+		body        string
+		stream      bool
+		compression *CompressionConfig
+		wantBlob    bool
+	}{
+		{
+			name: "below_threshold",
+			body: "small",
+		},
+		{
+			name:     "blob",
+			body:     large,
+			wantBlob: true,
+		},
+		{
+			name:        "compressed_blob",
+			body:        large,
+			compression: &CompressionConfig{Codec: CodecZstd, Threshold: 100},
+			wantBlob:    true,
+		},
+		{
+			name:   "stream_below_threshold",
+			body:   "small",
+			stream: true,
+		},
+		{
+			name:     "stream_blob",
+			body:     large,
+			stream:   true,
+			wantBlob: true,
+		},
+		{
+			name:        "stream_compressed_blob",
+			body:        large,
+			stream:      true,
+			compression: &CompressionConfig{Codec: CodecGzip, Threshold: 100},
+			wantBlob:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			config := &Config{Compression: tt.compression, Blobs: &BlobConfig{Threshold: 1000}}
+			// two snapshots with the same body share the blob.
+			for _, name := range []string{"one.txt", "two.txt"} {
+				var comparable snapshots.Comparable = comparabletypes.NewStringComparable(tt.body)
+				if tt.stream {
+					s, err := comparabletypes.NewStreamComparable(strings.NewReader(tt.body))
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer s.Close()
+					comparable = s
+				}
+				writeSnapshot(filepath.Join(dir, name), false, comparable, nil, config)
+			}
+
+			blobs, _ := os.ReadDir(filepath.Join(dir, blobsDir))
+			if tt.wantBlob && len(blobs) != 1 || !tt.wantBlob && len(blobs) != 0 {
+				t.Errorf("there are %d blobs stored, want blob: %v", len(blobs), tt.wantBlob)
+			}
+			for _, name := range []string{"one.txt", "two.txt"} {
+				snapshotPath := filepath.Join(dir, name)
+				fc, err := readFileContents(snapshotPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if (fc.header.Blob != nil) != tt.wantBlob {
+					t.Errorf("%s: stored blob is %v, want blob: %v", name, fc.header.Blob, tt.wantBlob)
+				}
+				if string(fc.body) != tt.body {
+					t.Errorf("%s: loaded body is %q, want %q", name, fc.body, tt.body)
+				}
+				body, err := OpenSnapshot(snapshotPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				streamed, err := io.ReadAll(body)
+				body.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(streamed) != tt.body {
+					t.Errorf("%s: streamed body is %q, want %q", name, streamed, tt.body)
+				}
+			}
+		})
+	}
+}
+
+func Test_readBlobCorrupted(t *testing.T) {
+	dir := t.TempDir()
+	ref, err := writeBlob(dir, []byte("the original content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ref.SHA256), []byte("the altered content!"), snapshotFilePerm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readBlob(dir, ref); !errors.Is(err, ErrCorruptedBlob) {
+		t.Errorf("readBlob() error = %v, want %v", err, ErrCorruptedBlob)
+	}
+}
+
+func Test_cleanupBlobs(t *testing.T) {
+	suite := NewSuite(WithCleanup())
+	config := &Config{SnapShotDir: t.TempDir(), Blobs: &BlobConfig{Threshold: 1}}
+	for name, body := range map[string]string{
+		"kept.txt":   "shared body",
+		"stale.txt":  "shared body",
+		"stale2.txt": "only referenced by a stale snapshot",
+	} {
+		writeSnapshot(filepath.Join(config.SnapShotDir, name), false, comparabletypes.NewStringComparable(body), nil,
+			config)
+	}
+	orphan, err := writeBlob(filepath.Join(config.SnapShotDir, blobsDir), []byte("orphan"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := readFileHeader(filepath.Join(config.SnapShotDir, "stale2.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := readFileHeader(filepath.Join(config.SnapShotDir, "kept.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suite.files = map[string]bool{"kept.txt": true}
+	suite.ran = true
+	if err := suite.cleanup(config, false); err != nil {
+		t.Fatalf("cleanup() error = %v", err)
+	}
+	if _, err := readFileContents(filepath.Join(config.SnapShotDir, "kept.txt")); err != nil {
+		t.Errorf("kept snapshot can't be read after cleanup: %v", err)
+	}
+	for _, digest := range []string{stale.Blob.SHA256, orphan.SHA256} {
+		if _, err := os.Stat(filepath.Join(config.SnapShotDir, blobsDir, digest)); err == nil {
+			t.Errorf("unreferenced blob %s was not removed", digest)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(config.SnapShotDir, blobsDir, kept.Blob.SHA256)); err != nil {
+		t.Errorf("referenced blob %s was removed", kept.Blob.SHA256)
+	}
+}
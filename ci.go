@@ -0,0 +1,154 @@
+package expect
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// annotationsEnv, if set, holds the format of the annotations printed for failures, it takes precedence over the
+// configuration.
+const annotationsEnv = "EXPECT_ANNOTATIONS"
+
+// AnnotationsGitHub prints the failures as GitHub Actions workflow commands, which GitHub shows in the code.
+const AnnotationsGitHub = "github"
+
+// reportMessageSize is the size, in bytes, of the excerpt of the difference kept in the reports.
+const reportMessageSize = 4096
+
+// ansiEscape matches the ANSI escape sequences used to color differences.
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// plainExcerpt returns the start of message, without colors, to be added to the reports.
+func plainExcerpt(message string) string {
+	return truncateDiff(ansiEscape.ReplaceAllString(message, ""), reportMessageSize)
+}
+
+// testCaller returns the file and line of the test that called the assertion, the first _test.go file in the
+// stack.
+func testCaller() (string, int) {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if strings.HasSuffix(frame.File, "_test.go") {
+			return frame.File, frame.Line
+		}
+		if !more {
+			return "", 0
+		}
+	}
+}
+
+// annotations returns the configured annotations format, if any.
+func (c *Config) annotations() string {
+	if format := os.Getenv(annotationsEnv); format != "" {
+		return format
+	}
+	return c.Annotations
+}
+
+// escapeWorkflowData escapes the message of a GitHub workflow command.
+func escapeWorkflowData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeWorkflowProperty escapes a property of a GitHub workflow command.
+func escapeWorkflowProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// annotate prints, in the configured format, an annotation of the failure of the snapshot name at the file and
+// line of its test.
+func annotate(w io.Writer, config *Config, file string, line int, title, message string) {
+	if config.annotations() != AnnotationsGitHub {
+		return
+	}
+	// GitHub wants paths relative to the repository.
+	if workspace := os.Getenv("GITHUB_WORKSPACE"); workspace != "" && file != "" {
+		if rel, err := filepath.Rel(workspace, file); err == nil {
+			file = filepath.ToSlash(rel)
+		}
+	}
+	var properties []string
+	if file != "" {
+		properties = append(properties, "file="+escapeWorkflowProperty(file), fmt.Sprintf("line=%d", line))
+	}
+	properties = append(properties, "title="+escapeWorkflowProperty(title))
+	fmt.Fprintf(w, "::error %s::%s\n", strings.Join(properties, ","), escapeWorkflowData(plainExcerpt(message)))
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junit returns the report as JUnit XML, with a test case per snapshot compared.
+func (r *Report) junit() ([]byte, error) {
+	suite := junitTestSuite{Name: filepath.Base(r.PackageDir)}
+	for _, e := range r.Snapshots {
+		if e.Outcome == OutcomeDeleted {
+			continue
+		}
+		name := e.Name
+		if e.Test != "" {
+			name = e.Test + "/" + e.Name
+		}
+		c := junitTestCase{
+			Name:      name,
+			Classname: suite.Name,
+			File:      e.File,
+			Line:      e.Line,
+			SystemOut: fmt.Sprintf("snapshot: %s\noutcome: %s\n", e.Path, e.Outcome),
+		}
+		switch e.Outcome {
+		case OutcomeFailed:
+			suite.Failures++
+			c.Failure = &junitProblem{
+				Message: fmt.Sprintf("snapshot %s differs from the result", e.Name),
+				Text:    fmt.Sprintf("snapshot: %s\n%s", e.Path, e.Message),
+			}
+		case OutcomeErrored:
+			suite.Errors++
+			c.Error = &junitProblem{
+				Message: fmt.Sprintf("snapshot %s could not be compared", e.Name),
+				Text:    fmt.Sprintf("snapshot: %s\n%s", e.Path, e.Message),
+			}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	suite.Tests = len(suite.Cases)
+	b, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(b, '\n')...), nil
+}
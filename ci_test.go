@@ -0,0 +1,93 @@
+package expect
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_annotate(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations string
+		workspace   string
+		file        string
+		message     string
+		want        string
+	}{
+		{
+			name:    "not_configured",
+			file:    "/src/repo/foo_test.go",
+			message: "a difference",
+		},
+		{
+			name:        "github",
+			annotations: AnnotationsGitHub,
+			file:        "/src/repo/foo_test.go",
+			message:     "\x1b[31mline 1\x1b[0m\n100%",
+			want:        "::error file=/src/repo/foo_test.go,line=12,title=snapshot a%2C b::line 1%0A100%25\n",
+		},
+		{
+			name:        "github_relative_to_workspace",
+			annotations: AnnotationsGitHub,
+			workspace:   "/src/repo",
+			file:        "/src/repo/pkg/foo_test.go",
+			message:     "a difference",
+			want:        "::error file=pkg/foo_test.go,line=12,title=snapshot a%2C b::a difference\n",
+		},
+		{
+			name:        "github_without_file",
+			annotations: AnnotationsGitHub,
+			message:     "a difference",
+			want:        "::error title=snapshot a%2C b::a difference\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(annotationsEnv, "")
+			t.Setenv("GITHUB_WORKSPACE", tt.workspace)
+			var b strings.Builder
+			annotate(&b, &Config{Annotations: tt.annotations}, tt.file, 12, "snapshot a, b", tt.message)
+			if b.String() != tt.want {
+				t.Errorf("annotate() printed %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_testCaller(t *testing.T) {
+	file, line := testCaller()
+	if !strings.HasSuffix(file, "ci_test.go") || line == 0 {
+		t.Errorf("testCaller() = %s:%d, want this file", file, line)
+	}
+}
+
+func TestReport_junit(t *testing.T) {
+	r := &Report{
+		PackageDir: "/src/repo/pkg",
+		Snapshots: []ReportEntry{
+			{Test: "TestA", Name: "matched", Path: "/s/matched.txt", Outcome: OutcomeMatched, File: "/src/a_test.go",
+				Line: 3},
+			{Test: "TestA", Name: "failed", Path: "/s/failed.txt", Outcome: OutcomeFailed, Message: "a < b"},
+			{Test: "TestB", Name: "errored", Path: "/s/errored.txt", Outcome: OutcomeErrored, Message: "broken"},
+			{Name: "stale", Path: "/s/stale.txt", Outcome: OutcomeDeleted},
+		},
+	}
+	b, err := r.junit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	for _, want := range []string{
+		`<testsuite name="pkg" tests="3" failures="1" errors="1">`,
+		`<testcase name="TestA/matched" classname="pkg" file="/src/a_test.go" line="3">`,
+		`<failure message="snapshot failed differs from the result">snapshot: /s/failed.txt&#xA;a &lt; b</failure>`,
+		`<error message="snapshot errored could not be compared">snapshot: /s/errored.txt&#xA;broken</error>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("junit() = %s\nwant it to contain %s", got, want)
+		}
+	}
+	if strings.Contains(got, "stale") {
+		t.Errorf("junit() = %s\nwant deleted snapshots left out", got)
+	}
+}
@@ -0,0 +1,138 @@
+// Command expect works with the snapshots stored by perri.to/expect.
+//
+// Usage:
+//
+//	expect cat FILE...
+//	expect obsolete [-reports DIR] [-delete] [ROOT]
+//
+// cat prints the body of each snapshot, decompressed if it was stored compressed.
+//
+// obsolete lists the snapshot files within ROOT, the current directory by default, that no test uses anymore, and
+// deletes them with -delete. It runs all the tests in ROOT to know which snapshots are used, unless -reports points
+// to the run reports of a previous run of all of them. Snapshot directories without a report, because their package
+// does not write one or did not run, are listed as unknown and never deleted.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"perri.to/expect"
+)
+
+const usage = `usage: expect <command> [arguments]
+
+commands:
+  cat FILE...                                  print the body of the snapshots, decompressed if they are
+  obsolete [-reports DIR] [-delete] [ROOT]     list, or delete, the snapshots no test uses
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "cat":
+		err = cat(os.Stdout, args)
+	case "obsolete":
+		err = obsolete(os.Stdout, args)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, usage)
+	default:
+		fmt.Fprintf(os.Stderr, "expect: unknown command %q\n%s", command, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "expect: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// cat writes the body of the snapshots in files to w.
+func cat(w io.Writer, files []string) error {
+	if len(files) == 0 {
+		return fmt.Errorf("cat: no snapshot files passed")
+	}
+	for _, f := range files {
+		body, err := expect.OpenSnapshot(f)
+		if err != nil {
+			return fmt.Errorf("opening %s: %w", f, err)
+		}
+		_, err = io.Copy(w, body)
+		body.Close()
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", f, err)
+		}
+	}
+	return nil
+}
+
+// obsolete lists, or deletes, the snapshots that no package references.
+func obsolete(w io.Writer, args []string) error {
+	flags := flag.NewFlagSet("obsolete", flag.ContinueOnError)
+	reportsDir := flags.String("reports", "", "directory with the run reports of all the packages, "+
+		"if not set all the tests are run to produce them")
+	del := flags.Bool("delete", false, "delete the obsolete snapshots instead of listing them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	root := "."
+	if flags.NArg() > 0 {
+		root = flags.Arg(0)
+	}
+	if *reportsDir == "" {
+		dir, err := os.MkdirTemp("", "expect-reports-*")
+		if err != nil {
+			return fmt.Errorf("creating reports directory: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		if err := runTests(root, dir); err != nil {
+			// failed tests still report their snapshots but packages that do not build report none.
+			if *del {
+				return fmt.Errorf("not deleting, the tests did not pass: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "expect: the tests did not pass, some snapshots might be wrongly listed: %v\n", err)
+		}
+		*reportsDir = dir
+	}
+	reports, err := expect.LoadReports(*reportsDir)
+	if err != nil {
+		return err
+	}
+	if len(reports) == 0 {
+		return fmt.Errorf("there are no reports in %s, the tests must call expect.Cleanup or expect.Finish", *reportsDir)
+	}
+	found, err := expect.FindObsolete(root, reports)
+	if err != nil {
+		return err
+	}
+	for _, dir := range found.Unknown {
+		// without a report we can not tell which of its snapshots are used.
+		fmt.Fprintf(w, "unknown: %s/\n", dir)
+	}
+	for _, f := range found.Files {
+		fmt.Fprintln(w, f)
+		if *del {
+			if err := os.Remove(f); err != nil {
+				return fmt.Errorf("deleting obsolete snapshot: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// runTests runs all the tests in root writing their run reports to reportsDir.
+func runTests(root, reportsDir string) error {
+	// the report is enabled through the environment, test binaries reject flags they do not define. -count=1 runs
+	// them even if cached, cached runs write no reports.
+	cmd := exec.Command("go", "test", "-count=1", "./...")
+	cmd.Env = append(os.Environ(), "EXPECT_REPORT_DIR="+reportsDir)
+	cmd.Dir = root
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_cat(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.txt")
+	if err := os.WriteFile(plain, []byte("{\n  \"os\": \"linux\",\n  \"limit_to_os\": false\n}\n\nhello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		files   []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "plain",
+			files: []string{plain, plain},
+			want:  "hello\nhello\n",
+		},
+		{
+			name:    "missing",
+			files:   []string{filepath.Join(dir, "missing.txt")},
+			wantErr: true,
+		},
+		{
+			name:    "no_files",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			err := cat(&b, tt.files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if b.String() != tt.want {
+				t.Errorf("cat() = %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_obsolete(t *testing.T) {
+	snapshot := "{\n  \"os\": \"linux\",\n  \"limit_to_os\": false\n}\n\nhello\n"
+	root := t.TempDir()
+	used := filepath.Join(root, "a", "TestExpectationsSnapshots", "used.txt")
+	stale := filepath.Join(root, "a", "TestExpectationsSnapshots", "stale.txt")
+	gone := filepath.Join(root, "gone", "TestExpectationsSnapshots")
+	for _, f := range []string{used, stale, filepath.Join(gone, "x.txt")} {
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte(snapshot), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reports := t.TempDir()
+	report := fmt.Sprintf(`{"package_dir": %q, "snapshot_dir": %q, "snapshots": [{"name": "used", "path": %q, "outcome": "matched"}]}`,
+		filepath.Join(root, "a"), filepath.Dir(used), used)
+	if err := os.WriteFile(filepath.Join(reports, "a.json"), []byte(report), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := obsolete(&b, []string{"-reports", reports, "-delete", root}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "unknown: " + gone + "/\n" + stale + "\n"; b.String() != want {
+		t.Errorf("obsolete() = %q, want %q", b.String(), want)
+	}
+	// gone has no report, it might belong to a package that does not report its snapshots.
+	for f, exists := range map[string]bool{used: true, stale: false, gone: true} {
+		if _, err := os.Stat(f); (err == nil) != exists {
+			t.Errorf("%s exists = %v, want %v", f, err == nil, exists)
+		}
+	}
+}
+
+func Test_obsoleteRunsTests(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not available to run the tests of the fixture")
+	}
+	module, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	goSum, err := os.ReadFile(filepath.Join(module, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := "{\n  \"os\": \"linux\",\n  \"limit_to_os\": false\n}\n\nhello"
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod": fmt.Sprintf("module fixture\n\ngo 1.18\n\nrequire perri.to/expect v0.0.0\n\nreplace perri.to/expect => %s\n",
+			module),
+		"go.sum": string(goSum),
+		"a/a_test.go": `package a
+
+import (
+	"os"
+	"testing"
+
+	"perri.to/expect"
+	"perri.to/expect/snapshots/comparabletypes"
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if err := expect.Finish(); err != nil {
+		code = 1
+	}
+	os.Exit(code)
+}
+
+func TestUsed(t *testing.T) {
+	expect.FromSnapshot(t, "used", comparabletypes.NewStringComparable("hello"))
+}
+`,
+		// b uses the default suite, which writes no report.
+		"b/b_test.go": `package b
+
+import (
+	"testing"
+
+	"perri.to/expect"
+	"perri.to/expect/snapshots/comparabletypes"
+)
+
+func TestUsed(t *testing.T) {
+	expect.FromSnapshot(t, "used", comparabletypes.NewStringComparable("hello"))
+}
+`,
+		"a/TestExpectationsSnapshots/used.txt":  snapshot,
+		"a/TestExpectationsSnapshots/stale.txt": snapshot,
+		"b/TestExpectationsSnapshots/used.txt":  snapshot,
+		"gone/TestExpectationsSnapshots/x.txt":  snapshot,
+	}
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the fixture requires what expect does, which is taken from the module cache.
+	t.Setenv("GOFLAGS", "-mod=mod")
+
+	var b strings.Builder
+	if err := obsolete(&b, []string{"-delete", root}); err != nil {
+		t.Fatal(err)
+	}
+	want := "unknown: " + filepath.Join(root, "b", "TestExpectationsSnapshots") + "/\n" +
+		"unknown: " + filepath.Join(root, "gone", "TestExpectationsSnapshots") + "/\n" +
+		filepath.Join(root, "a", "TestExpectationsSnapshots", "stale.txt") + "\n"
+	if b.String() != want {
+		t.Errorf("obsolete() = %q, want %q", b.String(), want)
+	}
+	for name, exists := range map[string]bool{
+		"a/TestExpectationsSnapshots/used.txt":  true,
+		"a/TestExpectationsSnapshots/stale.txt": false,
+		"b/TestExpectationsSnapshots/used.txt":  true,
+		"gone/TestExpectationsSnapshots/x.txt":  true,
+	} {
+		if _, err := os.Stat(filepath.Join(root, name)); (err == nil) != exists {
+			t.Errorf("%s exists = %v, want %v", name, err == nil, exists)
+		}
+	}
+}
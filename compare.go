@@ -0,0 +1,99 @@
+package expect
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"perri.to/expect/snapshots"
+)
+
+// DefaultCompareTimeout is how long a comparison can take, if no compare_timeout is configured, before the
+// difference is summarized.
+const DefaultCompareTimeout = time.Minute
+
+// compareTimeout returns how long a comparison can take, 0 is no limit.
+func (c *Config) compareTimeout() (time.Duration, error) {
+	if c.CompareTimeout == "" {
+		return DefaultCompareTimeout, nil
+	}
+	timeout, err := time.ParseDuration(c.CompareTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid compare_timeout: %w", err)
+	}
+	return timeout, nil
+}
+
+// compareTo compares expectation to comparable, passing ctx to the comparables that take it.
+func compareTo(ctx context.Context, expectation, comparable snapshots.Comparable) (string, error) {
+	if comparer, ok := expectation.(snapshots.ContextComparer); ok {
+		return comparer.CompareToContext(ctx, comparable)
+	}
+	return expectation.CompareTo(comparable)
+}
+
+// timedOutNote follows the summary of a comparison that took too long.
+const timedOutNote = "the comparison is still running in the background; the summary compares the stored forms byte " +
+	"by byte, so it can show differences the comparison ignores, ie: the order of JSON keys\n"
+
+// compare compares expectation to comparable, if it takes longer than timeout the difference is summarized, see
+// snapshots.DiffSummary, so a slow comparison does not hang the tests.
+func compare(expectation, comparable snapshots.Comparable, timeout time.Duration) (string, error) {
+	if timeout <= 0 {
+		return compareTo(context.Background(), expectation, comparable)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if _, ok := expectation.(snapshots.ContextComparer); ok {
+		// they stop, and summarize, by themselves.
+		return compareTo(ctx, expectation, comparable)
+	}
+	type result struct {
+		diff string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		diff, err := expectation.CompareTo(comparable)
+		done <- result{diff: diff, err: err}
+	}()
+	select {
+	case r := <-done:
+		return r.diff, r.err
+	case <-ctx.Done():
+	}
+	// the comparison is left running, summarizing only reads the comparables, as comparing does.
+	summary, err := diffSummary(expectation, comparable)
+	if err != nil || summary == "" {
+		return "", err
+	}
+	return summary + timedOutNote, nil
+}
+
+// diffSummary returns the snapshots.DiffSummary of expectation and comparable, comparables that stream their dump
+// are summarized as they are read instead of being held in memory.
+func diffSummary(expectation, comparable snapshots.Comparable) (string, error) {
+	_, expectedStreams := expectation.(snapshots.StreamDumper)
+	_, gotStreams := comparable.(snapshots.StreamDumper)
+	if !expectedStreams && !gotStreams {
+		return snapshots.DiffSummary(expectation.Dump(), comparable.Dump()), nil
+	}
+	expected, got := dumpReader(expectation), dumpReader(comparable)
+	defer expected.Close()
+	defer got.Close()
+	summary, err := snapshots.StreamDiffSummary(expected, got)
+	if err != nil {
+		return "", fmt.Errorf("summarizing the difference: %w", err)
+	}
+	return summary, nil
+}
+
+// dumpReader returns a reader of what comparable dumps, which is streamed if the comparable supports it.
+func dumpReader(comparable snapshots.Comparable) io.ReadCloser {
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(dumpTo(w, comparable))
+	}()
+	return r
+}
@@ -0,0 +1,161 @@
+package expect
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"perri.to/expect/snapshots"
+	"perri.to/expect/snapshots/comparabletypes"
+)
+
+// slowComparable takes delay to compare and does not take a context.
+type slowComparable struct {
+	snapshots.Comparable
+	delay time.Duration
+}
+
+func (s slowComparable) CompareTo(c snapshots.Comparable) (string, error) {
+	time.Sleep(s.delay)
+	return s.Comparable.CompareTo(c)
+}
+
+func Test_compare(t *testing.T) {
+	summary := "differs at byte 6, sizes 11 vs 12 (the comparison took too long to show the whole difference)\n" +
+		timedOutNote
+	tests := []struct {
+		name     string
+		delay    time.Duration
+		timeout  time.Duration
+		expected string
+		got      string
+		want     string
+	}{
+		{
+			name:     "in_time",
+			timeout:  time.Minute,
+			expected: "hello world",
+			got:      "hello world!",
+			want:     "hello world{+!+}",
+		},
+		{
+			name:     "timed_out",
+			delay:    time.Second,
+			timeout:  10 * time.Millisecond,
+			expected: "hello world",
+			got:      "hello there!",
+			want:     summary,
+		},
+		{
+			name:     "timed_out_equal",
+			delay:    time.Second,
+			timeout:  10 * time.Millisecond,
+			expected: "hello world",
+			got:      "hello world",
+		},
+		{
+			name:     "no_timeout",
+			delay:    50 * time.Millisecond,
+			expected: "hello world",
+			got:      "hello world!",
+			want:     "hello world{+!+}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectation := slowComparable{Comparable: comparabletypes.NewStringComparable(tt.expected), delay: tt.delay}
+			got, err := compare(expectation, comparabletypes.NewStringComparable(tt.got), tt.timeout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("compare() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// dumpCounter counts how many times it is dumped.
+type dumpCounter struct {
+	snapshots.Comparable
+	dumps *int
+}
+
+func (d dumpCounter) Dump() []byte {
+	*d.dumps++
+	return d.Comparable.Dump()
+}
+
+func Test_compareDumpsOnlyWhenTimedOut(t *testing.T) {
+	dumps := 0
+	expectation := dumpCounter{Comparable: comparabletypes.NewStringComparable("hello world"), dumps: &dumps}
+	got := dumpCounter{Comparable: comparabletypes.NewStringComparable("hello world!"), dumps: &dumps}
+	if _, err := compare(slowComparable{Comparable: expectation}, got, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if dumps != 0 {
+		t.Errorf("compare() dumped %d times, the comparison finished in time", dumps)
+	}
+}
+
+func TestConfig_compareTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "default", want: DefaultCompareTimeout},
+		{name: "configured", timeout: "30s", want: 30 * time.Second},
+		{name: "no_limit", timeout: "0", want: 0},
+		{name: "invalid", timeout: "soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&Config{CompareTimeout: tt.timeout}).compareTimeout()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compareTimeout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("compareTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// slowStream is a slowComparable that streams its dump.
+type slowStream struct {
+	slowComparable
+	stream *comparabletypes.Stream
+}
+
+func (s slowStream) DumpTo(w io.Writer) error {
+	return s.stream.DumpTo(w)
+}
+
+func (s slowStream) Dump() []byte {
+	panic("streamed comparables are summarized from DumpTo")
+}
+
+func Test_compareStreamDumper(t *testing.T) {
+	stream := func(s string) *comparabletypes.Stream {
+		st, err := comparabletypes.NewStreamComparable(strings.NewReader(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { st.Close() })
+		return st
+	}
+	expected := stream("hello world")
+	expectation := slowStream{slowComparable: slowComparable{Comparable: expected, delay: time.Second}, stream: expected}
+	got, err := compare(expectation, stream("hello there!"), 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "differs at byte 6, sizes 11 vs 12 (the comparison took too long to show the whole difference)\n" +
+		timedOutNote
+	if got != want {
+		t.Errorf("compare() = %q, want %q", got, want)
+	}
+}
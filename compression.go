@@ -0,0 +1,200 @@
+package expect
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec is how the body of a snapshot is compressed, it is recorded in the snapshot header.
+type Codec string
+
+const (
+	// CodecNone stores the body as it is.
+	CodecNone Codec = ""
+	// CodecGzip compresses the body with gzip.
+	CodecGzip Codec = "gzip"
+	// CodecZstd compresses the body with zstandard.
+	CodecZstd Codec = "zstd"
+)
+
+// DefaultCompressionThreshold is the size, in bytes, above which bodies are compressed when no threshold is set.
+const DefaultCompressionThreshold = 1 << 20
+
+// CompressionConfig holds how large snapshot bodies are compressed.
+type CompressionConfig struct {
+	// Codec is gzip or zstd.
+	Codec Codec `json:"codec"`
+	// Threshold is the size, in bytes, above which bodies are compressed, DefaultCompressionThreshold if not set.
+	Threshold int `json:"threshold,omitempty"`
+}
+
+func (c *CompressionConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	switch c.Codec {
+	case CodecGzip, CodecZstd:
+		return nil
+	}
+	return fmt.Errorf("unknown compression codec %q, expected gzip or zstd", c.Codec)
+}
+
+// threshold returns the size above which bodies are compressed, -1 if they are not.
+func (c *CompressionConfig) threshold() int {
+	switch {
+	case c == nil || c.Codec == CodecNone:
+		return -1
+	case c.Threshold <= 0:
+		return DefaultCompressionThreshold
+	}
+	return c.Threshold
+}
+
+// codecFor returns the codec a body of size bytes is stored with.
+func (c *CompressionConfig) codecFor(size int) Codec {
+	if threshold := c.threshold(); threshold >= 0 && size > threshold {
+		return c.Codec
+	}
+	return CodecNone
+}
+
+// compress returns a writer that compresses what is written to it into w, it must be closed to flush it.
+func (c Codec) compress(w io.Writer) (io.WriteCloser, error) {
+	switch c {
+	case CodecGzip:
+		return gzip.NewWriter(w), nil
+	case CodecZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	}
+	return nil, fmt.Errorf("unknown compression codec %q", c)
+}
+
+// decompress returns a reader of the decompressed content of r, it must be closed.
+func (c Codec) decompress(r io.Reader) (io.ReadCloser, error) {
+	switch c {
+	case CodecNone:
+		return io.NopCloser(r), nil
+	case CodecGzip:
+		return gzip.NewReader(r)
+	case CodecZstd:
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("unknown compression codec %q", c)
+}
+
+// encode returns body compressed with the codec.
+func (c Codec) encode(body []byte) ([]byte, error) {
+	if c == CodecNone {
+		return body, nil
+	}
+	var b bytes.Buffer
+	w, err := c.compress(&b)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// decode returns body decompressed with the codec.
+func (c Codec) decode(body []byte) ([]byte, error) {
+	if c == CodecNone {
+		return body, nil
+	}
+	r, err := c.decompress(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// snapshotWriter writes the header and then the body of a snapshot whose size is not known beforehand, the body is
+// held in memory until it grows above the compression threshold, then the header is written with the codec and
+// the body is compressed as it is written.
+type snapshotWriter struct {
+	// headerOut is where the header is written, it is out unless the body is stored as a blob.
+	headerOut   io.Writer
+	out         io.Writer
+	header      *fileHeader
+	compression *CompressionConfig
+	pending     bytes.Buffer
+	// body is where the body is written once the header is, nil until then.
+	body       io.Writer
+	compressor io.WriteCloser
+	// size is how much of the body was written, before compressing it.
+	size int64
+}
+
+func newSnapshotWriter(headerOut, out io.Writer, header *fileHeader,
+	compression *CompressionConfig) (*snapshotWriter, error) {
+	w := &snapshotWriter{headerOut: headerOut, out: out, header: header, compression: compression}
+	if compression.threshold() < 0 {
+		if err := w.start(CodecNone); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+func (w *snapshotWriter) Write(p []byte) (int, error) {
+	w.size += int64(len(p))
+	if w.body != nil {
+		return w.body.Write(p)
+	}
+	w.pending.Write(p)
+	if w.pending.Len() > w.compression.threshold() {
+		if err := w.start(w.compression.Codec); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// start writes the header, with codec, and what is pending of the body.
+func (w *snapshotWriter) start(codec Codec) error {
+	w.header.Codec = codec
+	h, err := w.header.dump()
+	if err != nil {
+		return fmt.Errorf("dumping header %w", err)
+	}
+	if _, err := w.headerOut.Write(append(h, headerSep...)); err != nil {
+		return err
+	}
+	w.body = w.out
+	if codec != CodecNone {
+		if w.compressor, err = codec.compress(w.out); err != nil {
+			return err
+		}
+		w.body = w.compressor
+	}
+	_, err = w.body.Write(w.pending.Bytes())
+	w.pending.Reset()
+	return err
+}
+
+// Close writes what is pending and flushes the compressor, it does not close the underlying writer.
+func (w *snapshotWriter) Close() error {
+	if w.body == nil {
+		if err := w.start(CodecNone); err != nil {
+			return err
+		}
+	}
+	if w.compressor != nil {
+		return w.compressor.Close()
+	}
+	return nil
+}
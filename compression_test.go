@@ -0,0 +1,148 @@
+package expect
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"perri.to/expect/snapshots"
+	"perri.to/expect/snapshots/comparabletypes"
+)
+
+func Test_writeSnapshotCompressed(t *testing.T) {
+	large := strings.Repeat("a line of a protocol dump\n", 100)
+	tests := []struct {
+		name        string
+		body        string
+		stream      bool
+		compression *CompressionConfig
+		wantCodec   Codec
+	}{
+		{
+			name:      "not_configured",
+			body:      large,
+			wantCodec: CodecNone,
+		},
+		{
+			name:        "below_threshold",
+			body:        "small",
+			compression: &CompressionConfig{Codec: CodecGzip, Threshold: 100},
+			wantCodec:   CodecNone,
+		},
+		{
+			name:        "gzip",
+			body:        large,
+			compression: &CompressionConfig{Codec: CodecGzip, Threshold: 100},
+			wantCodec:   CodecGzip,
+		},
+		{
+			name:        "zstd",
+			body:        large,
+			compression: &CompressionConfig{Codec: CodecZstd, Threshold: 100},
+			wantCodec:   CodecZstd,
+		},
+		{
+			name:        "default_threshold",
+			body:        large,
+			compression: &CompressionConfig{Codec: CodecZstd},
+			wantCodec:   CodecNone,
+		},
+		{
+			name:        "stream_below_threshold",
+			body:        "small",
+			stream:      true,
+			compression: &CompressionConfig{Codec: CodecZstd, Threshold: 100},
+			wantCodec:   CodecNone,
+		},
+		{
+			name:        "stream_gzip",
+			body:        large,
+			stream:      true,
+			compression: &CompressionConfig{Codec: CodecGzip, Threshold: 100},
+			wantCodec:   CodecGzip,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshotPath := filepath.Join(t.TempDir(), "compressed.txt")
+			var comparable snapshots.Comparable = comparabletypes.NewStringComparable(tt.body)
+			if tt.stream {
+				s, err := comparabletypes.NewStreamComparable(strings.NewReader(tt.body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer s.Close()
+				comparable = s
+			}
+			writeSnapshot(snapshotPath, false, comparable, nil, &Config{Compression: tt.compression})
+
+			fc, err := readFileContents(snapshotPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fc.header.Codec != tt.wantCodec {
+				t.Errorf("stored codec is %q, want %q", fc.header.Codec, tt.wantCodec)
+			}
+			if string(fc.body) != tt.body {
+				t.Errorf("loaded body is %q, want %q", fc.body, tt.body)
+			}
+
+			body, err := OpenSnapshot(snapshotPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer body.Close()
+			streamed, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(streamed) != tt.body {
+				t.Errorf("streamed body is %q, want %q", streamed, tt.body)
+			}
+		})
+	}
+}
+
+func Test_fromSnapshotCompressed(t *testing.T) {
+	suite := NewSuite()
+	config := &Config{SnapShotDir: t.TempDir(), Compression: &CompressionConfig{Codec: CodecZstd, Threshold: 10}}
+	body := strings.Repeat("0123456789\n", 10)
+	err := NewSuite(WithUpdate()).fromSnapshot("test_from_snapshot_compressed", comparabletypes.NewStringComparable(body),
+		false, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := suite.fromSnapshot("test_from_snapshot_compressed", comparabletypes.NewStringComparable(body), false,
+		config); err != nil {
+		t.Errorf("fromSnapshot() with the same body: %v", err)
+	}
+
+	config.Compression.Codec = "lz4"
+	delete(suite.names, "test_from_snapshot_compressed")
+	err = suite.fromSnapshot("test_from_snapshot_compressed", comparabletypes.NewStringComparable(body), false, config)
+	if _, ok := err.(*ErrTestErrored); !ok {
+		t.Errorf("fromSnapshot() with an unknown codec = %v, want it to error", err)
+	}
+}
+
+func TestCodec_encode(t *testing.T) {
+	body := bytes.Repeat([]byte("abc"), 1000)
+	for _, codec := range []Codec{CodecNone, CodecGzip, CodecZstd} {
+		encoded, err := codec.encode(body)
+		if err != nil {
+			t.Fatalf("%q: encode() error = %v", codec, err)
+		}
+		if codec != CodecNone && len(encoded) >= len(body) {
+			t.Errorf("%q: encoded %d bytes into %d", codec, len(body), len(encoded))
+		}
+		decoded, err := codec.decode(encoded)
+		if err != nil {
+			t.Fatalf("%q: decode() error = %v", codec, err)
+		}
+		if !bytes.Equal(decoded, body) {
+			t.Errorf("%q: decoded body differs from the encoded one", codec)
+		}
+	}
+}
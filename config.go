@@ -28,14 +28,55 @@ type Config struct {
 	SnapShotDir string   `json:"snapshot_dir,omitempty"`
 	// Replacers holds possible kw replacement as map[comparabletypes.Kind]map[from]to
 	Replacers map[snapshots.Kind]map[string]string `json:"replacers,omitempty"`
+	// Options holds the options for the comparables of each kind that implement snapshots.Configurable, as
+	// map[comparabletypes.Kind]options, the format of the options is up to the comparable.
+	Options map[snapshots.Kind]json.RawMessage `json:"options,omitempty"`
+	// Render holds how the differences are printed.
+	Render *RenderConfig `json:"render,omitempty"`
+	// MaxDiffSize is the size, in bytes, above which differences are truncated, 0 means no limit.
+	MaxDiffSize int `json:"max_diff_size,omitempty"`
+	// Compression, if set, compresses the snapshot bodies above a size.
+	Compression *CompressionConfig `json:"compression,omitempty"`
+	// Blobs, if set, stores the snapshot bodies above a size apart, in a blobs directory, referenced by digest.
+	Blobs *BlobConfig `json:"blobs,omitempty"`
+	// Artifacts, if set, writes the actual output of the failed comparisons to files, see ArtifactsConfig.
+	Artifacts *ArtifactsConfig `json:"artifacts,omitempty"`
+	// DiffTool is a command to inspect failed comparisons with, ie: "meld {expected} {actual}", it is split in
+	// arguments as a POSIX shell would, quotes included, but not expanded. EXPECT_DIFFTOOL takes precedence over it.
+	DiffTool string `json:"difftool,omitempty"`
+	// ReportDir, if set, is where the run report is written, see Finish.
+	ReportDir string `json:"report_dir,omitempty"`
+	// JUnitDir, if set, is where the JUnit XML report is written, next to the run report if not set.
+	JUnitDir string `json:"junit_dir,omitempty"`
+	// Annotations, if set to github, prints the failures as GitHub Actions annotations, EXPECT_ANNOTATIONS takes
+	// precedence over it.
+	Annotations string `json:"annotations,omitempty"`
+	// CompareTimeout is how long a comparison can take, ie: "30s", before the difference is summarized, "0" is no
+	// limit, DefaultCompareTimeout if not set.
+	CompareTimeout string `json:"compare_timeout,omitempty"`
+
+	// present holds the keys that are set even if empty, see Override.
+	present map[string]bool
+}
+
+// RenderConfig holds how the differences are printed by the comparables that support it.
+type RenderConfig struct {
+	// Theme is one of ansi, plain or html, if not set ansi or plain are chosen by the color mode.
+	Theme string `json:"theme,omitempty"`
+	// Layout is inline, the default, or side_by_side.
+	Layout snapshots.Layout `json:"layout,omitempty"`
+	// Width is the total width of side by side differences, at least snapshots.MinWidth.
+	Width int `json:"width,omitempty"`
 }
 
+// configFileName is the config file, see configYAMLFileName for the alternative.
 const configFileName = "expectations.json"
 
-// ReadConfig will try to read a config file from cwd and return that or a sane default.
+// ReadConfig will try to read the config files from the directory of the calling test up to the module root and
+// return those merged or a sane default.
 func ReadConfig() (*Config, error) {
-	for i := 2; i < 5; i++ {
-		_, fileCalling, _, ok := runtime.Caller(1)
+	for i := 1; i < 5; i++ {
+		_, fileCalling, _, ok := runtime.Caller(i)
 		if ok && strings.HasSuffix(fileCalling, "_test.go") {
 			return readConfig(func() (string, error) { return path.Dir(fileCalling), nil })
 		}
@@ -47,30 +88,130 @@ func ReadConfig() (*Config, error) {
 	return readConfig(os.Getwd)
 }
 
+// configDirs returns the directories where config files are looked for, from the module root, the first directory
+// up from wd holding a go.mod file, down to wd. Outside of a module only wd is.
+func configDirs(wd string) []string {
+	dirs := []string{wd}
+	for dir := wd; ; {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			// no module, parent directories have nothing to do with the tests.
+			return []string{wd}
+		}
+		dir = parent
+		dirs = append(dirs, dir)
+	}
+	for i, j := 0, len(dirs)-1; i < j; i, j = i+1, j-1 {
+		dirs[i], dirs[j] = dirs[j], dirs[i]
+	}
+	return dirs
+}
+
 func readConfig(getcwd func() (string, error)) (*Config, error) {
 	wd, err := getcwd()
 	if err != nil {
 		// I have no clue why Getwd() would fail in this context
 		return nil, fmt.Errorf("determining test working directory: %w", err)
 	}
-	cFilePath := filepath.Join(wd, configFileName)
-	if _, err := os.Stat(cFilePath); err != nil {
-		return &Config{
-			Grouping:    "",
-			SnapShotDir: "",
-			Replacers:   map[snapshots.Kind]map[string]string{},
-		}, nil
+	config := &Config{
+		Grouping:    "",
+		SnapShotDir: "",
+		Replacers:   map[snapshots.Kind]map[string]string{},
 	}
-	fd, err := os.Open(cFilePath)
-	if err != nil {
-		return nil, fmt.Errorf("opening expectations configuration file: %w", err)
+	for _, dir := range configDirs(wd) {
+		cFilePath, err := configFilePath(dir)
+		if err != nil {
+			return nil, err
+		}
+		if cFilePath == "" {
+			continue
+		}
+		child, err := readConfigFile(cFilePath)
+		if err != nil {
+			return nil, err
+		}
+		config.merge(child)
+	}
+	return config, nil
+}
+
+// merge overrides c with what is set in child, the replacers and options are merged by kind, the replacers of a
+// kind by value. Fields are set when they are not empty or their key is present, see Override.
+func (c *Config) merge(child *Config) {
+	if child.has("grouping", child.Grouping != "") {
+		c.Grouping = child.Grouping
+	}
+	if child.has("snapshot_dir", child.SnapShotDir != "") {
+		c.SnapShotDir = child.SnapShotDir
+	}
+	for kind, replacers := range child.Replacers {
+		if c.Replacers == nil {
+			c.Replacers = map[snapshots.Kind]map[string]string{}
+		}
+		if c.Replacers[kind] == nil {
+			c.Replacers[kind] = map[string]string{}
+		}
+		for from, to := range replacers {
+			c.Replacers[kind][from] = to
+		}
+	}
+	for kind, options := range child.Options {
+		if c.Options == nil {
+			c.Options = map[snapshots.Kind]json.RawMessage{}
+		}
+		c.Options[kind] = options
+	}
+	if child.has("render", child.Render != nil) {
+		c.Render = child.Render
 	}
-	defer fd.Close()
-	var config Config
-	if err = json.NewDecoder(fd).Decode(&config); err != nil {
-		return nil, fmt.Errorf("unmarshaling expectations configuration file: %w", err)
+	if child.has("max_diff_size", child.MaxDiffSize != 0) {
+		c.MaxDiffSize = child.MaxDiffSize
 	}
-	return &config, nil
+	if child.has("compression", child.Compression != nil) {
+		c.Compression = child.Compression
+	}
+	if child.has("blobs", child.Blobs != nil) {
+		c.Blobs = child.Blobs
+	}
+	if child.has("artifacts", child.Artifacts != nil) {
+		c.Artifacts = child.Artifacts
+	}
+	if child.has("difftool", child.DiffTool != "") {
+		c.DiffTool = child.DiffTool
+	}
+	if child.has("report_dir", child.ReportDir != "") {
+		c.ReportDir = child.ReportDir
+	}
+	if child.has("junit_dir", child.JUnitDir != "") {
+		c.JUnitDir = child.JUnitDir
+	}
+	if child.has("annotations", child.Annotations != "") {
+		c.Annotations = child.Annotations
+	}
+	if child.has("compare_timeout", child.CompareTimeout != "") {
+		c.CompareTimeout = child.CompareTimeout
+	}
+}
+
+// has returns true if the field of key is set, because it is not empty or because its key is present.
+func (c *Config) has(key string, notEmpty bool) bool {
+	return notEmpty || c.present[key]
+}
+
+// Override marks the fields, by their configuration key, ie: "compression", that are set even when they are empty,
+// so a config passed to WithConfig can turn off what the config files turned on. Keys present in a config file
+// are marked when it is read, ie: "compression": null turns compression off for a directory.
+func (c *Config) Override(keys ...string) *Config {
+	if c.present == nil {
+		c.present = make(map[string]bool, len(keys))
+	}
+	for _, k := range keys {
+		c.present[k] = true
+	}
+	return c
 }
 
 // GroupBy returns the configured (or default) grouping
@@ -97,3 +238,37 @@ func (c *Config) SnapshotDir(fileName string) string {
 	}
 	return snapShotDir
 }
+
+// Renderer returns the configured renderer, colors are decided by mode unless a theme is configured and mode is
+// auto.
+func (c *Config) Renderer(mode snapshots.ColorMode) (snapshots.Renderer, error) {
+	if mode == "" {
+		mode = snapshots.ColorAuto
+	}
+	mode, err := snapshots.ParseColorMode(string(mode))
+	if err != nil {
+		return snapshots.Renderer{}, err
+	}
+	r := snapshots.Renderer{Theme: mode.Theme(), Layout: snapshots.LayoutInline}
+	if c.Render == nil {
+		return r, nil
+	}
+	if c.Render.Theme != "" && mode == snapshots.ColorAuto {
+		if r.Theme, err = snapshots.ThemeByName(c.Render.Theme); err != nil {
+			return snapshots.Renderer{}, err
+		}
+	}
+	switch c.Render.Layout {
+	case "":
+	case snapshots.LayoutInline, snapshots.LayoutSideBySide:
+		r.Layout = c.Render.Layout
+	default:
+		return snapshots.Renderer{}, fmt.Errorf("unknown layout %q", c.Render.Layout)
+	}
+	if c.Render.Width != 0 && c.Render.Width < snapshots.MinWidth {
+		return snapshots.Renderer{}, fmt.Errorf("width %d is too narrow, it must be at least %d", c.Render.Width,
+			snapshots.MinWidth)
+	}
+	r.Width = c.Render.Width
+	return r, nil
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"perri.to/expect/snapshots"
@@ -88,3 +89,234 @@ func TestConfig_SnapshotDir(t *testing.T) {
 		t.Logf("expected %q but got %q", exp, f)
 	}
 }
+
+func TestConfig_Renderer(t *testing.T) {
+	tests := []struct {
+		name    string
+		render  *RenderConfig
+		mode    snapshots.ColorMode
+		want    snapshots.Renderer
+		wantErr bool
+	}{
+		{
+			name: "colors_always",
+			mode: snapshots.ColorAlways,
+			want: snapshots.Renderer{Theme: snapshots.ThemeANSI, Layout: snapshots.LayoutInline},
+		},
+		{
+			name: "colors_never",
+			mode: snapshots.ColorNever,
+			want: snapshots.Renderer{Theme: snapshots.ThemePlain, Layout: snapshots.LayoutInline},
+		},
+		{
+			name:   "configured_theme_and_layout",
+			render: &RenderConfig{Theme: "html", Layout: snapshots.LayoutSideBySide, Width: 120},
+			mode:   snapshots.ColorAuto,
+			want:   snapshots.Renderer{Theme: snapshots.ThemeHTML, Layout: snapshots.LayoutSideBySide, Width: 120},
+		},
+		{
+			name:   "flag_overrides_theme",
+			render: &RenderConfig{Theme: "ansi"},
+			mode:   snapshots.ColorNever,
+			want:   snapshots.Renderer{Theme: snapshots.ThemePlain, Layout: snapshots.LayoutInline},
+		},
+		{
+			name:    "unknown_theme",
+			render:  &RenderConfig{Theme: "neon"},
+			wantErr: true,
+		},
+		{
+			name:    "too_narrow",
+			render:  &RenderConfig{Layout: snapshots.LayoutSideBySide, Width: 4},
+			wantErr: true,
+		},
+		{
+			name:    "negative_width",
+			render:  &RenderConfig{Width: -1},
+			wantErr: true,
+		},
+		{
+			name:    "unknown_mode",
+			mode:    "sometimes",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Render: tt.render}
+			got, err := c.Renderer(tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Renderer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Theme.Name != tt.want.Theme.Name || got.Layout != tt.want.Layout || got.Width != tt.want.Width {
+				t.Errorf("Renderer() = %s %s %d, want %s %s %d", got.Theme.Name, got.Layout, got.Width,
+					tt.want.Theme.Name, tt.want.Layout, tt.want.Width)
+			}
+		})
+	}
+}
+
+func TestReadConfigInherited(t *testing.T) {
+	d := t.TempDir()
+	module := filepath.Join(d, "module")
+	pkg := filepath.Join(module, "some", "pkg")
+	files := map[string]string{
+		// outside of the module, ignored.
+		filepath.Join(d, configFileName):      `{"difftool": "meld"}`,
+		filepath.Join(module, "go.mod"):       "module example.com/module\n",
+		filepath.Join(module, configFileName): `{"grouping": "by_test_file", "max_diff_size": 10, "replacers": {"json": {"A": "a", "B": "b"}}, "options": {"string": {"diff": "unified"}}}`,
+		filepath.Join(pkg, configFileName):    `{"max_diff_size": 20, "replacers": {"json": {"B": "child"}, "string": {"C": "c"}}}`,
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(name), snapshotFilePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), snapshotFilePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := readConfig(func() (string, error) { return pkg, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Config{
+		Grouping:    groupByTestFile,
+		MaxDiffSize: 20,
+		Replacers: map[snapshots.Kind]map[string]string{
+			comparabletypes.KindJSON:   {"A": "a", "B": "child"},
+			comparabletypes.KindString: {"C": "c"},
+		},
+		Options: map[snapshots.Kind]json.RawMessage{
+			comparabletypes.KindString: json.RawMessage(`{"diff": "unified"}`),
+		},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("readConfig() = %#v, want %#v", c, want)
+	}
+}
+
+func TestReadConfigInheritedEmptyValues(t *testing.T) {
+	module := t.TempDir()
+	pkg := filepath.Join(module, "pkg")
+	files := map[string]string{
+		filepath.Join(module, "go.mod"):        "module example.com/module\n",
+		filepath.Join(module, configFileName):  `{"max_diff_size": 10, "compression": {"codec": "gzip"}, "difftool": "meld"}`,
+		filepath.Join(pkg, configYAMLFileName): "max_diff_size: 0\ncompression: null\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(name), snapshotFilePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), snapshotFilePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := readConfig(func() (string, error) { return pkg, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Config{DiffTool: "meld", Replacers: map[snapshots.Kind]map[string]string{}}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("readConfig() = %#v, want %#v", c, want)
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    *Config
+		wantErr string
+	}{
+		{
+			name: "json",
+			files: map[string]string{configFileName: `{
+  "grouping": "by_package",
+  "render": {"width": 80}
+}`},
+			want: &Config{Grouping: groupByPackage, Replacers: map[snapshots.Kind]map[string]string{},
+				Render: &RenderConfig{Width: 80}},
+		},
+		{
+			name: "yaml",
+			files: map[string]string{configYAMLFileName: `grouping: by_test_file
+replacers:
+  json:
+    Date: replaced-date
+options:
+  string:
+    diff: unified
+`},
+			want: &Config{
+				Grouping:  groupByTestFile,
+				Replacers: map[snapshots.Kind]map[string]string{comparabletypes.KindJSON: {"Date": "replaced-date"}},
+				Options:   map[snapshots.Kind]json.RawMessage{comparabletypes.KindString: json.RawMessage(`{"diff":"unified"}`)},
+			},
+		},
+		{
+			name: "json_unknown_keys",
+			files: map[string]string{configFileName: `{
+  "snapshotdir": "snaps",
+  "render": {
+    "widht": 80
+  }
+}`},
+			wantErr: configFileName + `:2:3: unknown key "snapshotdir"` + "\n" + configFileName + `:4:5: unknown key "widht"`,
+		},
+		{
+			name:    "json_invalid_grouping",
+			files:   map[string]string{configFileName: `{"grouping": "by_file"}`},
+			wantErr: configFileName + `:1:14: invalid grouping "by_file", must be by_test_file or by_package`,
+		},
+		{
+			name:    "json_syntax_error",
+			files:   map[string]string{configFileName: "{\n  \"grouping\": by_file\n}"},
+			wantErr: configFileName + `:2:15: invalid character 'b' looking for beginning of value`,
+		},
+		{
+			name:    "yaml_invalid_grouping",
+			files:   map[string]string{configYAMLFileName: "render:\n  width: 80\ngrouping: by_file\n"},
+			wantErr: configYAMLFileName + `:3:11: invalid grouping "by_file", must be by_test_file or by_package`,
+		},
+		{
+			name:    "yaml_unknown_key",
+			files:   map[string]string{configYAMLFileName: "blobs:\n  size: 10\n"},
+			wantErr: configYAMLFileName + `:2:3: unknown key "size"`,
+		},
+		{
+			name: "both_formats",
+			files: map[string]string{
+				configFileName:     `{}`,
+				configYAMLFileName: "grouping: by_package\n",
+			},
+			wantErr: "expectations.json and expectations.yaml are both in",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(d, name), []byte(content), snapshotFilePerm); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c, err := readConfig(func() (string, error) { return d, nil })
+			if tt.wantErr != "" {
+				// positions are reported in the files, named relative to d to compare them.
+				if err == nil || !strings.Contains(strings.ReplaceAll(err.Error(), d+string(filepath.Separator), ""), tt.wantErr) {
+					t.Fatalf("readConfig() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(c, tt.want) {
+				t.Errorf("readConfig() = %#v, want %#v", c, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package expect
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configYAMLFileName is the alternative, YAML, config file, a directory can not have both.
+const configYAMLFileName = "expectations.yaml"
+
+// ConfigError is a problem found in a config file, at a position of it.
+type ConfigError struct {
+	File   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+}
+
+// configErrors are all the problems found in a config file.
+type configErrors []*ConfigError
+
+func (e configErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// configFilePath returns the config file in dir, if any.
+func configFilePath(dir string) (string, error) {
+	var found []string
+	for _, name := range []string{configFileName, configYAMLFileName} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			found = append(found, filepath.Join(dir, name))
+		}
+	}
+	if len(found) > 1 {
+		return "", fmt.Errorf("%s and %s are both in %s, only one is read", configFileName, configYAMLFileName, dir)
+	}
+	if len(found) == 0 {
+		return "", nil
+	}
+	return found[0], nil
+}
+
+// configNode is a value of a config file, in any format, with its position.
+type configNode struct {
+	line, column int
+	// fields are set for objects, items for arrays and value for the rest.
+	fields []configField
+	items  []*configNode
+	value  interface{}
+	object bool
+}
+
+type configField struct {
+	name         string
+	line, column int
+	value        *configNode
+}
+
+// readConfigFile reads the config file in cFilePath, which is strictly decoded: unknown keys and invalid values are
+// reported with their position.
+func readConfigFile(cFilePath string) (*Config, error) {
+	data, err := os.ReadFile(cFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("opening expectations configuration file: %w", err)
+	}
+	var root *configNode
+	var decode func(*Config) error
+	if filepath.Ext(cFilePath) == ".yaml" {
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("unmarshaling expectations configuration file %s: %w", cFilePath, err)
+		}
+		root = yamlConfigNode(&doc)
+		decode = func(config *Config) error {
+			// the config is decoded as JSON, which it can be converted to, to honor the json.RawMessage options.
+			var v interface{}
+			if err := doc.Decode(&v); err != nil {
+				return err
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal(b, config)
+		}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if root, err = jsonConfigNode(dec, data); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				line, column := jsonPosition(data, syntaxErr.Offset-1)
+				return nil, &ConfigError{File: cFilePath, Line: line, Column: column, Msg: syntaxErr.Error()}
+			}
+			return nil, fmt.Errorf("unmarshaling expectations configuration file %s: %w", cFilePath, err)
+		}
+		decode = func(config *Config) error { return json.Unmarshal(data, config) }
+	}
+	var config Config
+	if root != nil {
+		var problems configErrors
+		validateConfigNode(cFilePath, root, reflect.TypeOf(config), &problems)
+		if len(problems) > 0 {
+			return nil, problems
+		}
+	}
+	if err := decode(&config); err != nil {
+		return nil, fmt.Errorf("unmarshaling expectations configuration file %s: %w", cFilePath, err)
+	}
+	if root != nil {
+		// keys set to empty values, ie: null, still override the ones of the parent directories.
+		for _, f := range root.fields {
+			config.Override(f.name)
+		}
+	}
+	return &config, nil
+}
+
+// jsonConfigNode reads the next value from dec, which decodes data, the position is found from the offsets.
+func jsonConfigNode(dec *json.Decoder, data []byte) (*configNode, error) {
+	line, column := jsonPosition(data, dec.InputOffset())
+	tok, err := dec.Token()
+	if errors.Is(err, io.EOF) {
+		// an empty file, nothing is configured.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	n := &configNode{line: line, column: column}
+	switch tok {
+	case json.Delim('{'):
+		n.object = true
+		for dec.More() {
+			line, column := jsonPosition(data, dec.InputOffset())
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := jsonConfigNode(dec, data)
+			if err != nil {
+				return nil, err
+			}
+			n.fields = append(n.fields, configField{name: key.(string), line: line, column: column, value: value})
+		}
+		_, err = dec.Token()
+	case json.Delim('['):
+		for dec.More() {
+			item, err := jsonConfigNode(dec, data)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+		}
+		_, err = dec.Token()
+	default:
+		n.value = tok
+	}
+	return n, err
+}
+
+// jsonPosition returns the line and column of the first token after offset in data.
+func jsonPosition(data []byte, offset int64) (int, int) {
+	for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[offset]) != -1 {
+		offset++
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	return line, int(offset) - bytes.LastIndexByte(before, '\n')
+}
+
+// yamlConfigNode converts n to a configNode.
+func yamlConfigNode(n *yaml.Node) *configNode {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil
+		}
+		return yamlConfigNode(n.Content[0])
+	case yaml.AliasNode:
+		return yamlConfigNode(n.Alias)
+	}
+	c := &configNode{line: n.Line, column: n.Column}
+	switch n.Kind {
+	case yaml.MappingNode:
+		c.object = true
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i]
+			c.fields = append(c.fields, configField{
+				name: key.Value, line: key.Line, column: key.Column, value: yamlConfigNode(n.Content[i+1]),
+			})
+		}
+	case yaml.SequenceNode:
+		for _, item := range n.Content {
+			c.items = append(c.items, yamlConfigNode(item))
+		}
+	default:
+		c.value = n
+		if n.Tag == "!!str" {
+			c.value = n.Value
+		}
+	}
+	return c
+}
+
+var (
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	groupingType   = reflect.TypeOf(Grouping(""))
+)
+
+// validateConfigNode adds to problems the keys of n that are not fields of t, and of its fields, and the invalid
+// groupings. Values of the wrong type are left for the decoding to report.
+func validateConfigNode(file string, n *configNode, t reflect.Type, problems *configErrors) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case n == nil || t == rawMessageType:
+		// options are decoded by the comparables.
+	case t == groupingType:
+		if s, ok := n.value.(string); ok && s != "" && Grouping(s) != groupByTestFile && Grouping(s) != groupByPackage {
+			*problems = append(*problems, &ConfigError{File: file, Line: n.line, Column: n.column,
+				Msg: fmt.Sprintf("invalid grouping %q, must be %s or %s", s, groupByTestFile, groupByPackage)})
+		}
+	case t.Kind() == reflect.Struct && n.object:
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if name := strings.Split(f.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+				fields[name] = f.Type
+			}
+		}
+		for _, f := range n.fields {
+			ft, ok := fields[f.name]
+			if !ok {
+				*problems = append(*problems, &ConfigError{File: file, Line: f.line, Column: f.column,
+					Msg: fmt.Sprintf("unknown key %q", f.name)})
+				continue
+			}
+			validateConfigNode(file, f.value, ft, problems)
+		}
+	case t.Kind() == reflect.Map && n.object:
+		for _, f := range n.fields {
+			validateConfigNode(file, f.value, t.Elem(), problems)
+		}
+	case t.Kind() == reflect.Slice:
+		for _, item := range n.items {
+			validateConfigNode(file, item, t.Elem(), problems)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package expect
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"perri.to/expect/snapshots"
+)
+
+// diffToolEnv, if set, holds the diff tool command and takes precedence over the configured one.
+const diffToolEnv = "EXPECT_DIFFTOOL"
+
+// Placeholders of the diff tool command, replaced by the files holding each side of the comparison.
+const (
+	DiffToolExpected = "{expected}"
+	DiffToolActual   = "{actual}"
+)
+
+// diffTool returns the configured diff tool command, if any.
+func (c *Config) diffTool() string {
+	if tool := os.Getenv(diffToolEnv); tool != "" {
+		return tool
+	}
+	return c.DiffTool
+}
+
+// diffToolCommand returns the arguments of the diff tool command with the placeholders replaced by the passed
+// files, if the command has no placeholders the files are appended to it. The command is split as a POSIX shell
+// would, see splitCommand.
+func diffToolCommand(tool, expected, actual string) ([]string, error) {
+	fields, err := splitCommand(tool)
+	if err != nil {
+		return nil, fmt.Errorf("invalid diff tool command: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("invalid diff tool command: it is blank")
+	}
+	placeholders := false
+	for i, f := range fields {
+		replaced := strings.NewReplacer(DiffToolExpected, expected, DiffToolActual, actual).Replace(f)
+		placeholders = placeholders || replaced != f
+		fields[i] = replaced
+	}
+	if !placeholders {
+		fields = append(fields, expected, actual)
+	}
+	return fields, nil
+}
+
+// splitCommand splits command in arguments by whitespace, text in single quotes is taken as is, in double quotes
+// a backslash escapes the next ", \, $ or ` and, outside of quotes, a backslash escapes the next character. There
+// are no expansions, ie: of variables.
+func splitCommand(command string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	// inArg tells empty arguments, ie: '', apart from no argument.
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range command {
+		switch {
+		case escaped:
+			if quote == '"' && !strings.ContainsRune("\"\\$`", r) {
+				arg.WriteRune('\\')
+			}
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case r == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("%q ends in an escape", command)
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("%q has an unterminated %c", command, quote)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// shellQuote quotes s for a POSIX shell, if it needs it.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=+,@%", r))
+	}) == -1 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// dumpTemp writes what comparable dumps to a temporary file, named after the snapshot and side, and returns its
+// path.
+func dumpTemp(snapshotFilePath, side string, comparable snapshots.Comparable) (string, error) {
+	name := filepath.Base(snapshotFilePath)
+	ext := filepath.Ext(name)
+	fd, err := os.CreateTemp("", fmt.Sprintf("%s.%s.*%s", strings.TrimSuffix(name, ext), side, ext))
+	if err != nil {
+		return "", fmt.Errorf("creating %s file for the diff tool: %w", side, err)
+	}
+	defer fd.Close()
+	if err := dumpTo(fd, comparable); err != nil {
+		os.Remove(fd.Name())
+		return "", fmt.Errorf("writing %s file for the diff tool: %w", side, err)
+	}
+	return fd.Name(), nil
+}
+
+// runDiffTool writes both sides of a failed comparison to temporary files for the configured diff tool, which is
+// run if run is set, by the -expect.difftool flag. It returns what to add to the failure: the command line to run the
+// tool or, if it was run and failed, why.
+func runDiffTool(snapshotFilePath string, expectation, comparable snapshots.Comparable, config *Config,
+	run bool) (string, error) {
+	tool := config.diffTool()
+	if tool == "" {
+		return "", nil
+	}
+	// the files are removed unless they are left for the printed command to inspect.
+	keep := false
+	expected, err := dumpTemp(snapshotFilePath, "expected", expectation)
+	if err != nil {
+		return "", err
+	}
+	defer removeUnlessKept(expected, &keep)
+	actual, err := dumpTemp(snapshotFilePath, "actual", comparable)
+	if err != nil {
+		return "", err
+	}
+	defer removeUnlessKept(actual, &keep)
+	command, err := diffToolCommand(tool, expected, actual)
+	if err != nil {
+		return "", err
+	}
+	if !run {
+		keep = true
+		quoted := make([]string, len(command))
+		for i, arg := range command {
+			quoted[i] = shellQuote(arg)
+		}
+		return fmt.Sprintf("inspect the difference with: %s\n", strings.Join(quoted, " ")), nil
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		// diff tools usually exit with an error when the files differ, which they do.
+		if _, exited := err.(*exec.ExitError); !exited {
+			return fmt.Sprintf("running the diff tool %s: %v\n", command[0], err), nil
+		}
+	}
+	return "", nil
+}
+
+// removeUnlessKept removes the file in path unless keep is set by then.
+func removeUnlessKept(path string, keep *bool) {
+	if !*keep {
+		os.Remove(path)
+	}
+}
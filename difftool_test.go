@@ -0,0 +1,187 @@
+package expect
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"perri.to/expect/snapshots/comparabletypes"
+)
+
+func Test_diffToolCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		tool    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "placeholders",
+			tool: "meld {expected} {actual}",
+			want: []string{"meld", "/tmp/e.txt", "/tmp/a.txt"},
+		},
+		{
+			name: "placeholders_within_arguments",
+			tool: "code --diff --left={expected} {actual}",
+			want: []string{"code", "--diff", "--left=/tmp/e.txt", "/tmp/a.txt"},
+		},
+		{
+			name: "no_placeholders",
+			tool: "diff -u",
+			want: []string{"diff", "-u", "/tmp/e.txt", "/tmp/a.txt"},
+		},
+		{
+			name: "quoted",
+			tool: `"/opt/My Diff/diff" --label 'it'\''s' --title="a \"b\" \c" My\ Side {expected} '' {actual}`,
+			want: []string{"/opt/My Diff/diff", "--label", "it's", `--title=a "b" \c`, "My Side", "/tmp/e.txt", "",
+				"/tmp/a.txt"},
+		},
+		{
+			name:    "unterminated_quote",
+			tool:    `meld "{expected} {actual}`,
+			wantErr: true,
+		},
+		{
+			name:    "blank",
+			tool:    "  ",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := diffToolCommand(tt.tool, "/tmp/e.txt", "/tmp/a.txt")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("diffToolCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffToolCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_shellQuote(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "/tmp/snapshot.expected.123.txt", want: "/tmp/snapshot.expected.123.txt"},
+		{s: "/tmp/my snapshot.txt", want: "'/tmp/my snapshot.txt'"},
+		{s: "it's", want: `'it'\''s'`},
+		{s: "", want: "''"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			if got := shellQuote(tt.s); got != tt.want {
+				t.Errorf("shellQuote() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fromSnapshotDiffTool(t *testing.T) {
+	if _, err := exec.LookPath("cp"); err != nil {
+		t.Skip("cp is needed to stand in for a diff tool")
+	}
+	copied := filepath.Join(t.TempDir(), "copied.txt")
+	tests := []struct {
+		name        string
+		tool        string
+		env         bool
+		run         bool
+		wantFailure string
+		wantErrored bool
+		wantCopied  bool
+		// wantTempFiles is how many files are left for the diff tool.
+		wantTempFiles int
+	}{
+		{
+			name:          "printed",
+			tool:          "cp {actual} " + copied,
+			wantFailure:   "inspect the difference with: cp ",
+			wantTempFiles: 2,
+		},
+		{
+			name:          "from_environment",
+			tool:          "cp {actual} " + copied,
+			env:           true,
+			wantFailure:   "inspect the difference with: cp ",
+			wantTempFiles: 2,
+		},
+		{
+			name:       "run",
+			tool:       "cp {actual} " + copied,
+			run:        true,
+			wantCopied: true,
+		},
+		{
+			name:        "invalid_command",
+			tool:        "cp '{actual} " + copied,
+			run:         true,
+			wantErrored: true,
+		},
+		{
+			name:        "not_started",
+			tool:        "expect-no-such-diff-tool {actual}",
+			run:         true,
+			wantFailure: "running the diff tool expect-no-such-diff-tool",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(copied)
+			tmp := t.TempDir()
+			t.Setenv("TMPDIR", tmp)
+			suite := NewSuite()
+			// the diff tool is run with the -expect.difftool flag.
+			suite.args.runDiffTool = tt.run
+			config := &Config{SnapShotDir: t.TempDir()}
+			if tt.env {
+				t.Setenv(diffToolEnv, tt.tool)
+				config.DiffTool = "false"
+			} else {
+				config.DiffTool = tt.tool
+			}
+			compare := func(s string) error {
+				delete(suite.names, "test_difftool")
+				return suite.fromSnapshot("test_difftool", comparabletypes.NewStringComparable(s), false, config)
+			}
+			err := NewSuite(WithUpdate()).fromSnapshot("test_difftool",
+				comparabletypes.NewStringComparable("expected output\n"), false, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var failed *ErrTestFailed
+			var errored *ErrTestErrored
+			err = compare("actual output\n")
+			switch {
+			case tt.wantErrored && !errors.As(err, &errored):
+				t.Fatalf("fromSnapshot() = %v, want it to error", err)
+			case !tt.wantErrored && !errors.As(err, &failed):
+				t.Fatalf("fromSnapshot() = %v, want it to fail", err)
+			}
+			if tt.wantFailure != "" && !strings.Contains(failed.failure, tt.wantFailure) {
+				t.Errorf("failure %q does not contain %q", failed.failure, tt.wantFailure)
+			}
+			got, err := os.ReadFile(copied)
+			if tt.wantCopied != (err == nil) {
+				t.Fatalf("the diff tool ran: %v, want %v", err == nil, tt.wantCopied)
+			}
+			if tt.wantCopied && string(got) != "actual output\n" {
+				t.Errorf("the diff tool was passed %q as actual, want %q", got, "actual output\n")
+			}
+			left, err := os.ReadDir(tmp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(left) != tt.wantTempFiles {
+				t.Errorf("%d temporary files were left, want %d", len(left), tt.wantTempFiles)
+			}
+		})
+	}
+}
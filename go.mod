@@ -3,13 +3,18 @@ module perri.to/expect
 go 1.18
 
 require (
+	github.com/klauspost/compress v1.15.15
 	github.com/nsf/jsondiff v0.0.0-20210926074059-1e845ec5d249
 	github.com/sergi/go-diff v1.2.0
+	github.com/tidwall/sjson v1.2.4
+	golang.org/x/net v0.11.0
+	golang.org/x/text v0.10.0
+	google.golang.org/protobuf v1.30.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/tidwall/gjson v1.14.1 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
-	github.com/tidwall/sjson v1.2.4 // indirect
 )
@@ -0,0 +1,178 @@
+package expect
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"perri.to/expect/snapshots"
+)
+
+// LoadReports reads the run reports written to dir, see Finish.
+func LoadReports(dir string) ([]*Report, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("listing reports: %w", err)
+	}
+	reports := make([]*Report, 0, len(paths))
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("reading report: %w", err)
+		}
+		var r Report
+		if err := json.Unmarshal(b, &r); err != nil {
+			return nil, fmt.Errorf("decoding report %s: %w", p, err)
+		}
+		reports = append(reports, &r)
+	}
+	return reports, nil
+}
+
+// Obsolete holds the snapshots that no package references.
+type Obsolete struct {
+	// Unknown are snapshot directories that no report covers, which does not make them obsolete: their package
+	// might not write a report, not calling Cleanup or Finish, or might not have run, ie: for its build tags. They
+	// are never deleted.
+	Unknown []string
+	// Files are snapshots, and their sidecars, in the directories that are used, that no test uses.
+	Files []string
+}
+
+// FindObsolete looks, within root, for the snapshot files that are not referenced by reports, which must be the
+// reports of a run of all the packages in root. Snapshot directories are recognized by the default names and the
+// snapshot_dir of the configuration files found, only the files in those covered by a report can be obsolete, the
+// rest are Unknown. Snapshots limited to another OS and the directories of the packages whose reports come from
+// runs of some of their tests, with -run, are not taken for obsolete.
+func FindObsolete(root string, reports []*Report) (*Obsolete, error) {
+	used := map[string]bool{}
+	complete := map[string]bool{}
+	referenced := map[string]bool{}
+	for _, r := range reports {
+		if !used[r.SnapshotDir] {
+			used[r.SnapshotDir] = true
+			complete[r.SnapshotDir] = true
+		}
+		// partial runs leave snapshots out, which does not make them obsolete.
+		if r.Partial {
+			complete[r.SnapshotDir] = false
+		}
+		for _, e := range r.Snapshots {
+			if e.Outcome != OutcomeDeleted {
+				referenced[e.Path] = true
+			}
+		}
+	}
+
+	dirs, err := snapshotDirs(root)
+	if err != nil {
+		return nil, err
+	}
+	obsolete := &Obsolete{}
+	for _, dir := range dirs {
+		if !used[dir] {
+			obsolete.Unknown = append(obsolete.Unknown, dir)
+			continue
+		}
+		if !complete[dir] {
+			continue
+		}
+		files, err := obsoleteFiles(dir, referenced)
+		if err != nil {
+			return nil, err
+		}
+		obsolete.Files = append(obsolete.Files, files...)
+	}
+	return obsolete, nil
+}
+
+// snapshotDirs returns the absolute paths of the snapshot directories within root.
+func snapshotDirs(root string) ([]string, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("determining root directory: %w", err)
+	}
+	found := map[string]bool{}
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if !d.IsDir() {
+			return nil
+		}
+		if p != root && (strings.HasPrefix(name, ".") || name == "vendor" || name == "testdata") {
+			return filepath.SkipDir
+		}
+		if name == snapShotDir || strings.HasSuffix(name, ".expectations") {
+			found[p] = true
+			return filepath.SkipDir
+		}
+		// the snapshot_dir can be inherited from the config files of parent directories.
+		config, err := readConfig(func() (string, error) { return p, nil })
+		if err != nil {
+			return err
+		}
+		if config.SnapShotDir == "" {
+			return nil
+		}
+		// go test runs in the directory of the package, relative snapshot directories start there.
+		dir := config.SnapShotDir
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(p, dir)
+		}
+		if _, err := os.Stat(dir); err == nil {
+			found[filepath.Clean(dir)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("looking for snapshot directories: %w", err)
+	}
+	dirs := make([]string, 0, len(found))
+	for dir := range found {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// obsoleteFiles returns the snapshots in dir that are not referenced and the sidecars of those.
+func obsoleteFiles(dir string, referenced map[string]bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshot directory contents: %w", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		p := filepath.Join(dir, entry.Name())
+		if snapshot := snapshots.SidecarOf(p); snapshot != "" {
+			if _, err := os.Stat(snapshot); err != nil || !referenced[snapshot] && obsoleteSnapshot(snapshot) {
+				files = append(files, p)
+			}
+			continue
+		}
+		if !referenced[p] && obsoleteSnapshot(p) {
+			files = append(files, p)
+		}
+	}
+	return files, nil
+}
+
+// obsoleteSnapshot tells if an unreferenced snapshot is obsolete, which is not the case for the ones limited to
+// another OS.
+func obsoleteSnapshot(p string) bool {
+	header, err := readFileHeader(p)
+	if err != nil {
+		// not a snapshot, it is better left alone.
+		return false
+	}
+	return header.considerForCleanup()
+}
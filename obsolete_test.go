@@ -0,0 +1,80 @@
+package expect
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// writeTestFiles writes files, by path relative to root, with the passed contents.
+func writeTestFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), snapshotFilePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), snapshotFilePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindObsolete(t *testing.T) {
+	otherOS := "windows"
+	if runtime.GOOS == "windows" {
+		otherOS = "linux"
+	}
+	snapshot := "{\n  \"os\": \"linux\",\n  \"limit_to_os\": false\n}\n\nbody"
+	root := t.TempDir()
+	writeTestFiles(t, root, map[string]string{
+		"a/TestExpectationsSnapshots/used.txt":                      snapshot,
+		"a/TestExpectationsSnapshots/used.txt.sidecar.actual.txt":   "body",
+		"a/TestExpectationsSnapshots/stale.txt":                     snapshot,
+		"a/TestExpectationsSnapshots/stale.txt.sidecar.diff.png":    "png",
+		"a/TestExpectationsSnapshots/orphan.txt.sidecar.actual.txt": "body",
+		"a/TestExpectationsSnapshots/blobs/0123":                    "blob",
+		"a/TestExpectationsSnapshots/other_os.txt":                  fmt.Sprintf("{\n  \"os\": %q,\n  \"limit_to_os\": true\n}\n\nbody", otherOS),
+		"b/expectations.json":                                       `{"snapshot_dir": "custom"}`,
+		"b/custom/unreferenced_in_partial_run.txt":                  snapshot,
+		"gone/TestExpectationsSnapshots/x.txt":                      snapshot,
+		"gone_too/a_test.expectations/x.txt":                        snapshot,
+		".git/TestExpectationsSnapshots/x.txt":                      snapshot,
+	})
+	reports := []*Report{
+		{
+			PackageDir:  filepath.Join(root, "a"),
+			SnapshotDir: filepath.Join(root, "a", "TestExpectationsSnapshots"),
+			Snapshots: []ReportEntry{
+				{Name: "used", Path: filepath.Join(root, "a", "TestExpectationsSnapshots", "used.txt"),
+					Outcome: OutcomeMatched},
+			},
+		},
+		{
+			PackageDir:  filepath.Join(root, "b"),
+			SnapshotDir: filepath.Join(root, "b", "custom"),
+			Partial:     true,
+		},
+	}
+	got, err := FindObsolete(root, reports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Obsolete{
+		Unknown: []string{
+			filepath.Join(root, "gone", "TestExpectationsSnapshots"),
+			filepath.Join(root, "gone_too", "a_test.expectations"),
+		},
+		Files: []string{
+			filepath.Join(root, "a", "TestExpectationsSnapshots", "orphan.txt.sidecar.actual.txt"),
+			filepath.Join(root, "a", "TestExpectationsSnapshots", "stale.txt"),
+			filepath.Join(root, "a", "TestExpectationsSnapshots", "stale.txt.sidecar.diff.png"),
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindObsolete() = %#v, want %#v", got, want)
+	}
+}
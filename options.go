@@ -0,0 +1,92 @@
+package expect
+
+import (
+	"fmt"
+	"time"
+
+	"perri.to/expect/snapshots"
+)
+
+// Option changes how a single assertion is made, on top of the configuration read from the config files.
+type Option func(*assertion)
+
+// assertion holds how an assertion is made.
+type assertion struct {
+	config  *Config
+	limitOS bool
+	// tolerance is nil unless set, so comparables that are not Tolerant can tell it apart from 0.
+	tolerance *float64
+	// update and cleanup set the mode of a suite, see NewSuite.
+	update, cleanup bool
+	// err is the error of an invalid option, if any.
+	err error
+}
+
+// newAssertion returns the assertion resulting of applying opts on top of config.
+func newAssertion(config *Config, opts ...Option) *assertion {
+	a := &assertion{config: config}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// WithConfig merges config on top of the configuration, as a config file in a child directory would be.
+func WithConfig(config *Config) Option {
+	return func(a *assertion) {
+		a.config.merge(config)
+	}
+}
+
+// WithReplacers adds replacers, from to, for the comparables of kind, the ones configured for the same values are
+// overridden.
+func WithReplacers(kind snapshots.Kind, replacers map[string]string) Option {
+	return WithConfig(&Config{Replacers: map[snapshots.Kind]map[string]string{kind: replacers}})
+}
+
+// WithSnapshotDir stores the snapshot in dir instead of the configured snapshot directory.
+func WithSnapshotDir(dir string) Option {
+	return WithConfig(&Config{SnapShotDir: dir})
+}
+
+// WithTimeout sets how long the comparison can take before the difference is summarized, 0 is no limit.
+func WithTimeout(timeout time.Duration) Option {
+	return WithConfig(&Config{CompareTimeout: timeout.String()})
+}
+
+// WithUpdate makes a suite write its snapshots instead of comparing them, as the -u flag does, it is only taken by
+// NewSuite.
+func WithUpdate() Option {
+	return func(a *assertion) {
+		a.update = true
+	}
+}
+
+// WithCleanup makes a suite remove its stale snapshots when cleaned up, as the -cleanup flag does, it is only taken
+// by NewSuite.
+func WithCleanup() Option {
+	return func(a *assertion) {
+		a.cleanup = true
+	}
+}
+
+// OSDependent only compares the snapshot if it was taken in the same OS, this should prevent weird side effects of
+// snapshotting in different machines.
+func OSDependent() Option {
+	return func(a *assertion) {
+		a.limitOS = true
+	}
+}
+
+// Tolerance sets how much, as a fraction between 0 and 1, the result can differ from the snapshot, only comparables
+// that implement snapshots.Tolerant take it, see each for what it means.
+func Tolerance(tolerance float64) Option {
+	return func(a *assertion) {
+		// NaN is not in range either.
+		if !(tolerance >= 0 && tolerance <= 1) {
+			a.err = fmt.Errorf("invalid tolerance %v, it must be between 0 and 1", tolerance)
+			return
+		}
+		a.tolerance = &tolerance
+	}
+}
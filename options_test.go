@@ -0,0 +1,92 @@
+package expect
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"perri.to/expect/snapshots"
+	"perri.to/expect/snapshots/comparabletypes"
+)
+
+func Test_newAssertion(t *testing.T) {
+	tolerance := 0.1
+	tests := []struct {
+		name string
+		opts []Option
+		want *assertion
+	}{
+		{
+			name: "no_options",
+			want: &assertion{config: &Config{
+				Grouping:  groupByTestFile,
+				Replacers: map[snapshots.Kind]map[string]string{comparabletypes.KindJSON: {"A": "a", "B": "b"}},
+			}},
+		},
+		{
+			name: "replacers_are_merged",
+			opts: []Option{
+				WithReplacers(comparabletypes.KindJSON, map[string]string{"B": "option"}),
+				WithReplacers(comparabletypes.KindString, map[string]string{"C": "c"}),
+			},
+			want: &assertion{config: &Config{
+				Grouping: groupByTestFile,
+				Replacers: map[snapshots.Kind]map[string]string{
+					comparabletypes.KindJSON:   {"A": "a", "B": "option"},
+					comparabletypes.KindString: {"C": "c"},
+				},
+			}},
+		},
+		{
+			name: "all_options",
+			opts: []Option{
+				WithSnapshotDir("elsewhere"),
+				WithConfig(&Config{Grouping: groupByPackage, MaxDiffSize: 10}),
+				OSDependent(),
+				Tolerance(tolerance),
+				WithTimeout(time.Second),
+			},
+			want: &assertion{
+				config: &Config{
+					Grouping:       groupByPackage,
+					SnapShotDir:    "elsewhere",
+					MaxDiffSize:    10,
+					CompareTimeout: "1s",
+					Replacers:      map[snapshots.Kind]map[string]string{comparabletypes.KindJSON: {"A": "a", "B": "b"}},
+				},
+				limitOS:   true,
+				tolerance: &tolerance,
+			},
+		},
+		{
+			name: "config_overrides_with_empty_values",
+			opts: []Option{WithConfig((&Config{}).Override("grouping"))},
+			want: &assertion{config: &Config{
+				Replacers: map[snapshots.Kind]map[string]string{comparabletypes.KindJSON: {"A": "a", "B": "b"}},
+			}},
+		},
+		{
+			name: "invalid_tolerance",
+			opts: []Option{Tolerance(-0.1)},
+			want: &assertion{
+				config: &Config{
+					Grouping:  groupByTestFile,
+					Replacers: map[snapshots.Kind]map[string]string{comparabletypes.KindJSON: {"A": "a", "B": "b"}},
+				},
+				err: fmt.Errorf("invalid tolerance -0.1, it must be between 0 and 1"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Grouping:  groupByTestFile,
+				Replacers: map[snapshots.Kind]map[string]string{comparabletypes.KindJSON: {"A": "a", "B": "b"}},
+			}
+			if got := newAssertion(config, tt.opts...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newAssertion() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package expect
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"perri.to/expect/snapshots"
+)
+
+// reportDirEnv, if set, enables the run report and holds the directory where it is written.
+const reportDirEnv = "EXPECT_REPORT_DIR"
+
+// junitDirEnv, if set, enables the JUnit XML report and holds the directory where it is written.
+const junitDirEnv = "EXPECT_JUNIT_DIR"
+
+// Outcome is what happened to a snapshot during a run.
+type Outcome string
+
+const (
+	// OutcomeMatched is a snapshot that was equal to the result.
+	OutcomeMatched Outcome = "matched"
+	// OutcomeFailed is a snapshot that differed from the result.
+	OutcomeFailed Outcome = "failed"
+	// OutcomeErrored is a snapshot that could not be compared.
+	OutcomeErrored Outcome = "errored"
+	// OutcomeCreated is a snapshot that did not exist and was created.
+	OutcomeCreated Outcome = "created"
+	// OutcomeUpdated is a snapshot that differed from the result and was updated.
+	OutcomeUpdated Outcome = "updated"
+	// OutcomeDeleted is a stale snapshot removed by the cleanup.
+	OutcomeDeleted Outcome = "deleted"
+)
+
+// outcomes holds the outcomes in the order they are summarized.
+var outcomes = []Outcome{OutcomeMatched, OutcomeFailed, OutcomeErrored, OutcomeCreated, OutcomeUpdated, OutcomeDeleted}
+
+// ReportEntry is what happened to a snapshot during a run.
+type ReportEntry struct {
+	// Test is the name of the test that used the snapshot, it is empty for the deleted ones.
+	Test    string         `json:"test,omitempty"`
+	Name    string         `json:"name"`
+	Path    string         `json:"path"`
+	Kind    snapshots.Kind `json:"kind,omitempty"`
+	Outcome Outcome        `json:"outcome"`
+	// DiffSize is the size, in bytes, of the difference found, before truncating it.
+	DiffSize int `json:"diff_size,omitempty"`
+	// File and Line are where the test made the assertion.
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	// Message is the start of the difference, for the failed snapshots, or the error, for the errored ones.
+	Message string `json:"message,omitempty"`
+}
+
+// Report is what happened to the snapshots of a package during a run.
+type Report struct {
+	// PackageDir is the directory of the package the tests ran for.
+	PackageDir string `json:"package_dir"`
+	// SnapshotDir is the directory where the package keeps its snapshots.
+	SnapshotDir string          `json:"snapshot_dir"`
+	Snapshots   []ReportEntry   `json:"snapshots"`
+	Counts      map[Outcome]int `json:"counts"`
+	Summary     string          `json:"summary"`
+	// Partial is set when only some tests ran, with -run.
+	Partial bool `json:"partial,omitempty"`
+	mutex   sync.Mutex
+}
+
+// newReportEntry returns an entry for the snapshot name stored in snapshotFilePath, which is made absolute.
+func newReportEntry(name, snapshotFilePath string, kind snapshots.Kind, outcome Outcome) ReportEntry {
+	if abs, err := filepath.Abs(snapshotFilePath); err == nil {
+		snapshotFilePath = abs
+	}
+	return ReportEntry{Name: name, Path: snapshotFilePath, Kind: kind, Outcome: outcome}
+}
+
+func (r *Report) add(e ReportEntry) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Snapshots = append(r.Snapshots, e)
+}
+
+// setTest sets the test that used the snapshot name, which is unique during a run, where it did and the message
+// of the failure, if any.
+func (r *Report) setTest(name, test, file string, line int, message string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for i := range r.Snapshots {
+		if e := &r.Snapshots[i]; e.Name == name && e.Outcome != OutcomeDeleted {
+			e.Test, e.File, e.Line = test, file, line
+			if message != "" {
+				e.Message = plainExcerpt(message)
+			}
+		}
+	}
+}
+
+// summarize counts the outcomes and fills the human summary.
+func (r *Report) summarize() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Counts = map[Outcome]int{}
+	for _, e := range r.Snapshots {
+		r.Counts[e.Outcome]++
+	}
+	parts := make([]string, 0, len(outcomes))
+	for _, o := range outcomes {
+		parts = append(parts, fmt.Sprintf("%d %s", r.Counts[o], o))
+	}
+	r.Summary = fmt.Sprintf("expect: %d snapshots, %s", len(r.Snapshots), strings.Join(parts, ", "))
+	sort.SliceStable(r.Snapshots, func(i, j int) bool { return r.Snapshots[i].Name < r.Snapshots[j].Name })
+}
+
+// reportDir returns where the report is written or "" if it is not, the -expect.report flag, in args, takes
+// precedence over EXPECT_REPORT_DIR and that over the configuration.
+func (c *Config) reportDir(args *Args) string {
+	if args.reportDir != "" {
+		return args.reportDir
+	}
+	if dir := os.Getenv(reportDirEnv); dir != "" {
+		return dir
+	}
+	return c.ReportDir
+}
+
+// junitDir returns where the JUnit XML report is written or "" if it is not, the -expect.junit flag, in args, takes
+// precedence over EXPECT_JUNIT_DIR and that over the configuration, if none is set it goes with the run report.
+func (c *Config) junitDir(args *Args) string {
+	if args.junitDir != "" {
+		return args.junitDir
+	}
+	if dir := os.Getenv(junitDirEnv); dir != "" {
+		return dir
+	}
+	if c.JUnitDir != "" {
+		return c.JUnitDir
+	}
+	return c.reportDir(args)
+}
+
+// reportFileName returns the name of the report of the snapshots in snapshotDir of the package in packageDir,
+// packages with the same name, and suites of the same package, are told apart by a digest of both directories.
+func reportFileName(packageDir, snapshotDir string) string {
+	h := sha256.Sum256([]byte(packageDir + string(filepath.ListSeparator) + snapshotDir))
+	return fmt.Sprintf("%s-%s.json", filepath.Base(packageDir), hex.EncodeToString(h[:4]))
+}
+
+// writeReport writes the report of the run of the suite, as JSON and JUnit XML, each where it is enabled, and prints
+// its summary.
+func (s *Suite) writeReport(config *Config) error {
+	dir, junitDir := config.reportDir(s.args), config.junitDir(s.args)
+	if dir == "" && junitDir == "" {
+		return nil
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("determining test working directory: %w", err)
+	}
+	_, fileName, _, _ := runtime.Caller(0)
+	s.report.PackageDir = wd
+	s.report.Partial = s.args.runInArguments
+	s.report.SnapshotDir, err = filepath.Abs(config.SnapshotDir(fileName))
+	if err != nil {
+		return fmt.Errorf("determining snapshot directory: %w", err)
+	}
+	s.report.summarize()
+	fmt.Println(s.report.Summary)
+	reportName := reportFileName(wd, s.report.SnapshotDir)
+	if dir != "" {
+		b, err := json.MarshalIndent(s.report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding report: %w", err)
+		}
+		if err := os.MkdirAll(dir, snapshotFilePerm); err != nil {
+			return fmt.Errorf("creating report folder: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, reportName), b, snapshotFilePerm); err != nil {
+			return fmt.Errorf("writing report: %w", err)
+		}
+	}
+	if junitDir != "" {
+		b, err := s.report.junit()
+		if err != nil {
+			return fmt.Errorf("encoding JUnit report: %w", err)
+		}
+		if err := os.MkdirAll(junitDir, snapshotFilePerm); err != nil {
+			return fmt.Errorf("creating JUnit report folder: %w", err)
+		}
+		junitPath := filepath.Join(junitDir, strings.TrimSuffix(reportName, ".json")+".xml")
+		if err := os.WriteFile(junitPath, b, snapshotFilePerm); err != nil {
+			return fmt.Errorf("writing JUnit report: %w", err)
+		}
+	}
+	return nil
+}
+
+// Finish should be called in TestMain AFTER m.Run(), by the packages that do not call Cleanup, to write the run
+// report if it is enabled.
+func Finish() error {
+	return defaultSuite.Finish()
+}
@@ -0,0 +1,118 @@
+package expect
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"perri.to/expect/snapshots/comparabletypes"
+)
+
+func Test_writeReport(t *testing.T) {
+	updating, comparing := NewSuite(WithUpdate(), WithCleanup()), NewSuite()
+	// both suites make a single report, as a suite whose mode changed would.
+	comparing.report = updating.report
+	config := &Config{SnapShotDir: t.TempDir(), ReportDir: t.TempDir()}
+	compare := func(name, s string, update bool) {
+		suite := comparing
+		if update {
+			suite = updating
+		}
+		delete(suite.names, name)
+		suite.fromSnapshot(name, comparabletypes.NewStringComparable(s), false, config)
+		suite.report.setTest(name, "TestSomething", "", 0, "")
+	}
+	compare("created", "a", true)
+	compare("matched", "a", true)
+	compare("matched", "a", false)
+	compare("failed", "a", true)
+	compare("failed", "b", false)
+	compare("updated", "a", true)
+	compare("updated", "b", true)
+	if err := os.WriteFile(filepath.Join(config.SnapShotDir, "stale.txt"), []byte("{}\n\nstale"),
+		snapshotFilePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := updating.cleanupAndReport(config, false); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(config.ReportDir, reportFileName(wd, config.SnapShotDir)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(config.ReportDir, strings.TrimSuffix(reportFileName(wd, config.SnapShotDir), ".json")+".xml")); err != nil {
+		t.Errorf("JUnit report was not written: %v", err)
+	}
+	var report Report
+	if err := json.Unmarshal(b, &report); err != nil {
+		t.Fatal(err)
+	}
+	wantSummary := "expect: 8 snapshots, 1 matched, 1 failed, 0 errored, 4 created, 1 updated, 1 deleted"
+	if report.Summary != wantSummary {
+		t.Errorf("summary is %q, want %q", report.Summary, wantSummary)
+	}
+	last := map[string]ReportEntry{}
+	for _, e := range report.Snapshots {
+		last[e.Name] = e
+	}
+	for name, want := range map[string]Outcome{
+		"created": OutcomeCreated,
+		"matched": OutcomeMatched,
+		"failed":  OutcomeFailed,
+		"updated": OutcomeUpdated,
+		"stale":   OutcomeDeleted,
+	} {
+		e := last[name]
+		if e.Outcome != want {
+			t.Errorf("%s: outcome is %q, want %q", name, e.Outcome, want)
+		}
+		if want != OutcomeDeleted && (e.Test != "TestSomething" || e.Kind != comparabletypes.KindString) {
+			t.Errorf("%s: test is %q and kind %q", name, e.Test, e.Kind)
+		}
+		if want == OutcomeFailed && e.DiffSize == 0 {
+			t.Errorf("%s: diff size is not reported", name)
+		}
+		if !filepath.IsAbs(e.Path) {
+			t.Errorf("%s: path %q is not absolute", name, e.Path)
+		}
+	}
+}
+
+func Test_writeReportJUnitOnly(t *testing.T) {
+	t.Setenv(reportDirEnv, "")
+	t.Setenv(junitDirEnv, "")
+	suite := NewSuite(WithUpdate())
+	config := &Config{SnapShotDir: t.TempDir(), JUnitDir: t.TempDir()}
+	if err := suite.fromSnapshot("created", comparabletypes.NewStringComparable("a"), false, config); err != nil {
+		t.Fatal(err)
+	}
+	if err := suite.writeReport(config); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := strings.TrimSuffix(reportFileName(wd, config.SnapShotDir), ".json") + ".xml"
+	b, err := os.ReadFile(filepath.Join(config.JUnitDir, name))
+	if err != nil {
+		t.Fatalf("JUnit report was not written: %v", err)
+	}
+	if !strings.Contains(string(b), `<testcase name="created"`) {
+		t.Errorf("JUnit report does not have the snapshot:\n%s", b)
+	}
+	entries, err := os.ReadDir(config.JUnitDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("only the JUnit report should be written, found %v", entries)
+	}
+}
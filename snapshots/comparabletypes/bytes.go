@@ -0,0 +1,166 @@
+package comparabletypes
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"perri.to/expect/snapshots"
+)
+
+var _ snapshots.Comparable = (*Bytes)(nil)
+
+const (
+	// bytesPerRow is the width of the hex dump rows.
+	bytesPerRow = 16
+	// maxBytesDifferences is how many differing regions we dump before giving up.
+	maxBytesDifferences = 3
+)
+
+// Bytes holds raw binary data, ie: protobuf blobs or generated files, its differences are presented as a
+// hex dump of the regions that differ.
+type Bytes struct {
+	withRenderer
+	data []byte
+}
+
+// NewBytesComparable constructs a Bytes comparable from a byte slice.
+func NewBytesComparable(b []byte) snapshots.Comparable {
+	bc := Bytes{data: b}
+	return &bc
+}
+
+func (b *Bytes) Subtypes() bool {
+	return false
+}
+
+func (b *Bytes) ReplaceSubtypes(_ map[snapshots.Kind]map[string]string) {
+	return
+}
+
+func (b *Bytes) CompareTo(c snapshots.Comparable) (string, error) {
+	other, isBytes := c.(*Bytes)
+	if !isBytes {
+		return "", snapshots.CantCompare(fmt.Sprintf("%T", b), fmt.Sprintf("%T", c))
+	}
+	if bytes.Equal(b.data, other.data) {
+		return "", nil
+	}
+
+	var result strings.Builder
+	if len(b.data) != len(other.data) {
+		result.WriteString(fmt.Sprintf("Size: expected %d bytes but got %d\n", len(b.data), len(other.data)))
+	}
+	regions := differingRegions(b.data, other.data, maxBytesDifferences+1)
+	for i, r := range regions {
+		if i == maxBytesDifferences {
+			result.WriteString("[...] there are more differences\n")
+			break
+		}
+		result.WriteString(fmt.Sprintf("Difference at offset 0x%08x (%d):\n", r.start, r.start))
+		// dump whole rows around the region.
+		from := r.start - r.start%bytesPerRow
+		to := r.end + bytesPerRow - r.end%bytesPerRow
+		writeHexRows(&result, "-", b.data, from, to)
+		writeHexRows(&result, "+", other.data, from, to)
+	}
+	return b.escape(result.String()), nil
+}
+
+type byteRegion struct {
+	start int
+	end   int
+}
+
+// differingRegions returns up to limit regions where a and b differ, a region ends when a full row of bytes
+// is equal in both.
+func differingRegions(a, b []byte, limit int) []byteRegion {
+	longest := len(a)
+	if len(b) > longest {
+		longest = len(b)
+	}
+	differs := func(i int) bool {
+		return i >= len(a) || i >= len(b) || a[i] != b[i]
+	}
+	var regions []byteRegion
+	for i := 0; i < longest && len(regions) < limit; i++ {
+		if !differs(i) {
+			continue
+		}
+		r := byteRegion{start: i, end: i}
+		for equal := 0; i < longest && equal < bytesPerRow; i++ {
+			if differs(i) {
+				r.end = i
+				equal = 0
+				continue
+			}
+			equal++
+		}
+		regions = append(regions, r)
+	}
+	return regions
+}
+
+// writeHexRows writes the rows of data between from and to, in the classic hexdump format, prefixed by marker.
+func writeHexRows(w *strings.Builder, marker string, data []byte, from, to int) {
+	if from >= len(data) {
+		w.WriteString(fmt.Sprintf("%s %08x  (no data)\n", marker, from))
+		return
+	}
+	if to > len(data) {
+		to = len(data)
+	}
+	for row := from; row < to; row += bytesPerRow {
+		end := row + bytesPerRow
+		if end > to {
+			end = to
+		}
+		w.WriteString(fmt.Sprintf("%s %08x  ", marker, row))
+		for i := row; i < row+bytesPerRow; i++ {
+			if i < end {
+				w.WriteString(fmt.Sprintf("%02x ", data[i]))
+			} else {
+				w.WriteString("   ")
+			}
+			if i == row+bytesPerRow/2-1 {
+				w.WriteString(" ")
+			}
+		}
+		w.WriteString(" |")
+		for _, ch := range data[row:end] {
+			if ch < 32 || ch > 126 {
+				ch = '.'
+			}
+			w.WriteByte(ch)
+		}
+		w.WriteString("|\n")
+	}
+}
+
+func (b *Bytes) String() string {
+	return hex.Dump(b.data)
+}
+
+const KindBytes snapshots.Kind = "bytes"
+
+func (b *Bytes) Kind() snapshots.Kind {
+	return KindBytes
+}
+
+func (b *Bytes) Dump() []byte {
+	return b.data
+}
+
+func (b *Bytes) Load(data []byte) snapshots.Comparable {
+	return &Bytes{withRenderer: b.withRenderer, data: data}
+}
+
+// Replace does nothing, there is no sensible way to replace parts of arbitrary binary data.
+func (b *Bytes) Replace(_ map[string]string) {
+	return
+}
+
+func (b *Bytes) Extension() string {
+	return "bin"
+}
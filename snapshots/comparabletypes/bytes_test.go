@@ -0,0 +1,52 @@
+package comparabletypes
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBytes_CompareTo(t *testing.T) {
+	base := []byte("the quick brown fox jumps over the lazy dog, the quick brown fox jumps over the lazy cat")
+	changed := bytes.Replace(base, []byte("brown"), []byte("BROWN"), 1)
+	tests := []struct {
+		name  string
+		other []byte
+		want  string
+	}{
+		{
+			name:  "equal",
+			other: base,
+		},
+		{
+			name:  "one region",
+			other: changed,
+			want: `Difference at offset 0x0000000a (10):
+- 00000000  74 68 65 20 71 75 69 63  6b 20 62 72 6f 77 6e 20  |the quick brown |
++ 00000000  74 68 65 20 71 75 69 63  6b 20 42 52 4f 57 4e 20  |the quick BROWN |
+`,
+		},
+		{
+			name:  "shorter",
+			other: base[:40],
+			want: `Size: expected 88 bytes but got 40
+Difference at offset 0x00000028 (40):
+- 00000020  68 65 20 6c 61 7a 79 20  64 6f 67 2c 20 74 68 65  |he lazy dog, the|
+- 00000030  20 71 75 69 63 6b 20 62  72 6f 77 6e 20 66 6f 78  | quick brown fox|
+- 00000040  20 6a 75 6d 70 73 20 6f  76 65 72 20 74 68 65 20  | jumps over the |
+- 00000050  6c 61 7a 79 20 63 61 74                           |lazy cat|
++ 00000020  68 65 20 6c 61 7a 79 20                           |he lazy |
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewBytesComparable(base).CompareTo(NewBytesComparable(tt.other))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("CompareTo() got = \n%s\n, want \n%s", got, tt.want)
+			}
+		})
+	}
+}
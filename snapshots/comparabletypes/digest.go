@@ -0,0 +1,164 @@
+package comparabletypes
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"perri.to/expect/snapshots"
+)
+
+var _ snapshots.Comparable = (*Digest)(nil)
+
+// Digest wraps another comparable and stores only the SHA-256 digest and size of its Dump, it is meant for
+// outputs that only need to be unchanged, ie: generated artifacts, where a readable difference is not needed.
+type Digest struct {
+	// inner is the wrapped comparable, it is nil for the Digests loaded from a snapshot.
+	inner      snapshots.Comparable
+	sha256     string
+	size       int
+	keepActual bool
+	loadErr    error
+}
+
+// NewDigestComparable constructs a Digest comparable of c.
+func NewDigestComparable(c snapshots.Comparable) *Digest {
+	return &Digest{inner: c}
+}
+
+// KeepActual makes the comparison write the whole output of the wrapped comparable to a temporary file, which is
+// named in the failure, when the digests differ.
+func (d *Digest) KeepActual() {
+	d.keepActual = true
+}
+
+// sum returns the digest and size of the wrapped comparable or the ones loaded.
+func (d *Digest) sum() (string, int) {
+	if d.inner == nil {
+		return d.sha256, d.size
+	}
+	dump := d.inner.Dump()
+	h := sha256.Sum256(dump)
+	return hex.EncodeToString(h[:]), len(dump)
+}
+
+// Subtypes is true so the replacers of the kind of the wrapped comparable are passed to it.
+func (d *Digest) Subtypes() bool {
+	return d.inner != nil
+}
+
+func (d *Digest) ReplaceSubtypes(rs map[snapshots.Kind]map[string]string) {
+	if d.inner == nil {
+		return
+	}
+	if r, ok := rs[d.inner.Kind()]; ok {
+		d.inner.Replace(r)
+	}
+	if d.inner.Subtypes() {
+		d.inner.ReplaceSubtypes(rs)
+	}
+}
+
+func (d *Digest) CompareTo(c snapshots.Comparable) (string, error) {
+	other, isDigest := c.(*Digest)
+	if !isDigest {
+		return "", snapshots.CantCompare(fmt.Sprintf("%T", d), fmt.Sprintf("%T", c))
+	}
+	switch {
+	case d.loadErr != nil && other.loadErr != nil:
+		return "", snapshots.BothPartsInvalid(fmt.Sprintf("%T", d), fmt.Sprintf("%T", c), d.Kind())
+	case d.loadErr != nil:
+		return "", snapshots.InvalidSource(fmt.Sprintf("%T", d), d.Kind())
+	case other.loadErr != nil:
+		return "", snapshots.InvalidTarget(fmt.Sprintf("%T", c), c.Kind())
+	}
+	expectedSum, expectedSize := d.sum()
+	gotSum, gotSize := other.sum()
+	if expectedSum == gotSum {
+		return "", nil
+	}
+	result := fmt.Sprintf("content changed: expected sha256 %s (%d bytes) but got sha256 %s (%d bytes)\n",
+		expectedSum, expectedSize, gotSum, gotSize)
+	if (d.keepActual || other.keepActual) && other.inner != nil {
+		p, err := writeActual(other.inner)
+		if err != nil {
+			return "", err
+		}
+		result += fmt.Sprintf("actual output written to %s\n", p)
+	}
+	return result, nil
+}
+
+// writeActual writes the Dump of c to a temporary file and returns its path.
+func writeActual(c snapshots.Comparable) (string, error) {
+	pattern := "expect-actual-*"
+	if ext := c.Extension(); ext != "" {
+		pattern += "." + ext
+	}
+	fd, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("creating actual output file: %w", err)
+	}
+	defer fd.Close()
+	if _, err := fd.Write(c.Dump()); err != nil {
+		return "", fmt.Errorf("writing actual output file: %w", err)
+	}
+	return fd.Name(), nil
+}
+
+func (d *Digest) String() string {
+	return string(d.Dump())
+}
+
+const KindDigest snapshots.Kind = "digest"
+
+func (d *Digest) Kind() snapshots.Kind {
+	return KindDigest
+}
+
+// Dump returns the digest and size, one per line, as sha256:<hex> and size:<bytes>.
+func (d *Digest) Dump() []byte {
+	sum, size := d.sum()
+	return []byte(fmt.Sprintf("sha256:%s\nsize:%d\n", sum, size))
+}
+
+func (d *Digest) Load(raw []byte) snapshots.Comparable {
+	loaded := &Digest{keepActual: d.keepActual}
+	s := bufio.NewScanner(bytes.NewReader(raw))
+	for s.Scan() {
+		key, value, found := strings.Cut(s.Text(), ":")
+		if !found {
+			continue
+		}
+		switch key {
+		case "sha256":
+			loaded.sha256 = value
+		case "size":
+			size, err := strconv.Atoi(value)
+			if err != nil {
+				loaded.loadErr = fmt.Errorf("invalid size %q: %w", value, err)
+			}
+			loaded.size = size
+		}
+	}
+	if loaded.loadErr == nil && len(loaded.sha256) != sha256.Size*2 {
+		loaded.loadErr = fmt.Errorf("missing or invalid sha256 digest")
+	}
+	return loaded
+}
+
+// Replace passes the replacers to the wrapped comparable.
+func (d *Digest) Replace(rs map[string]string) {
+	if d.inner != nil {
+		d.inner.Replace(rs)
+	}
+}
+
+func (d *Digest) Extension() string {
+	return "sha256"
+}
@@ -0,0 +1,117 @@
+package comparabletypes
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDigest_CompareTo(t *testing.T) {
+	tests := []struct {
+		name       string
+		expected   string
+		got        string
+		replacer   map[string]string
+		keepActual bool
+		want       string
+	}{
+		{
+			name:     "unchanged",
+			expected: "generated artifact",
+			got:      "generated artifact",
+		},
+		{
+			name:     "changed",
+			expected: "generated artifact",
+			got:      "generated artifact v2",
+			want: "content changed: expected sha256 " +
+				"8448d19153bbcdb6a11167494969de08127b40c77f59da7ef5cc321a55df66b9 (18 bytes) but got sha256 " +
+				"a4e175998b44b8624b7c99cd438fe82b47383bb22aef003cd1632c36274d32a7 (21 bytes)\n",
+		},
+		{
+			name:     "replaced",
+			expected: "built at 10:00",
+			got:      "built at 11:30",
+			replacer: map[string]string{"10:00": "<time>", "11:30": "<time>"},
+		},
+		{
+			name:       "changed_keeping_actual",
+			expected:   "generated artifact",
+			got:        "generated artifact v2",
+			keepActual: true,
+			want:       "actual output written to ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDigestComparable(NewStringComparable(tt.expected))
+			if tt.replacer != nil {
+				d.Replace(tt.replacer)
+			}
+			loaded := d.Load(d.Dump())
+			other := NewDigestComparable(NewStringComparable(tt.got))
+			if tt.replacer != nil {
+				other.Replace(tt.replacer)
+			}
+			if tt.keepActual {
+				other.KeepActual()
+			}
+			got, err := loaded.CompareTo(other)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if got != "" {
+					t.Errorf("CompareTo() = %q, want no differences", got)
+				}
+				return
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("CompareTo() = %q, want it to contain %q", got, tt.want)
+			}
+			if tt.keepActual {
+				p := strings.TrimSpace(got[strings.Index(got, tt.want)+len(tt.want):])
+				defer os.Remove(p)
+				actual, err := os.ReadFile(p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(actual) != tt.got {
+					t.Errorf("actual output file holds %q, want %q", actual, tt.got)
+				}
+			}
+		})
+	}
+}
+
+func TestDigest_Load(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			raw:  "sha256:" + strings.Repeat("ab", 32) + "\nsize:12\n",
+		},
+		{
+			name:    "missing_digest",
+			raw:     "size:12\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid_size",
+			raw:     "sha256:" + strings.Repeat("ab", 32) + "\nsize:twelve\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDigestComparable(NewStringComparable("")).Load([]byte(tt.raw))
+			_, err := d.CompareTo(NewDigestComparable(NewStringComparable("")))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CompareTo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
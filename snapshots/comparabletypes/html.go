@@ -0,0 +1,411 @@
+package comparabletypes
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"perri.to/expect/snapshots"
+)
+
+var _ snapshots.Comparable = (*HTML)(nil)
+
+// HTML holds an HTML document which is compared structurally, ignoring insignificant whitespace, comments
+// and the order of attributes.
+type HTML struct {
+	withRenderer
+	rawHTML []byte
+}
+
+// NewHTMLFromString constructs an HTML comparable from a string.
+func NewHTMLFromString(s string) snapshots.Comparable {
+	h := HTML{rawHTML: []byte(s)}
+	return &h
+}
+
+// NewHTMLFromBytes constructs an HTML comparable from a byte slice.
+func NewHTMLFromBytes(b []byte) snapshots.Comparable {
+	h := HTML{rawHTML: b}
+	return &h
+}
+
+func (h *HTML) Subtypes() bool {
+	return false
+}
+
+func (h *HTML) ReplaceSubtypes(_ map[snapshots.Kind]map[string]string) {
+	return
+}
+
+func (h *HTML) CompareTo(c snapshots.Comparable) (string, error) {
+	newHTML, isHTML := c.(*HTML)
+	if !isHTML {
+		return "", snapshots.CantCompare(fmt.Sprintf("%T", h), fmt.Sprintf("%T", c))
+	}
+	if bytes.Equal(h.rawHTML, newHTML.rawHTML) {
+		return "", nil
+	}
+	expected, sourceErr := parseHTML(h.rawHTML)
+	got, targetErr := parseHTML(newHTML.rawHTML)
+	switch {
+	case sourceErr != nil && targetErr != nil:
+		return "", snapshots.BothPartsInvalid(fmt.Sprintf("%T", h), fmt.Sprintf("%T", c), h.Kind())
+	case sourceErr != nil:
+		return "", snapshots.InvalidSource(fmt.Sprintf("%T", h), h.Kind())
+	case targetErr != nil:
+		return "", snapshots.InvalidTarget(fmt.Sprintf("%T", c), c.Kind())
+	}
+	return h.escape(compareMarkup(expected, got)), nil
+}
+
+func (h *HTML) String() string {
+	return string(h.rawHTML)
+}
+
+const KindHTML snapshots.Kind = "html"
+
+func (h *HTML) Kind() snapshots.Kind {
+	return KindHTML
+}
+
+// Dump returns the document in a normalized form, if it can't be parsed it is returned as is.
+func (h *HTML) Dump() []byte {
+	doc, err := parseHTML(h.rawHTML)
+	if err != nil {
+		return h.rawHTML
+	}
+	return []byte(htmlRenderer.render(doc))
+}
+
+func (h *HTML) Load(rawHTML []byte) snapshots.Comparable {
+	return &HTML{withRenderer: h.withRenderer, rawHTML: rawHTML}
+}
+
+// Replace takes CSS selectors as keys and sets the text of the selected elements to the corresponding
+// values, a selector ending in ::attr(name) sets the value of the name attribute instead. The document is
+// left in its normalized form.
+func (h *HTML) Replace(rs map[string]string) {
+	if len(rs) == 0 {
+		return
+	}
+	doc, err := parseHTML(h.rawHTML)
+	if err != nil {
+		// we will complain about this when comparing.
+		return
+	}
+	if err := applyMarkupReplacers(doc, rs, selectCSS); err != nil {
+		panic(err)
+	}
+	h.rawHTML = []byte(htmlRenderer.render(doc))
+}
+
+func (h *HTML) Extension() string {
+	return "html"
+}
+
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// htmlRawTextElements hold text in which whitespace is significant or that must not be escaped.
+var htmlRawTextElements = map[string]bool{
+	"pre": true, "textarea": true, "script": true, "style": true,
+}
+
+var htmlRenderer = &markupRenderer{
+	escapeText: html.EscapeString,
+	escapeAttr: html.EscapeString,
+	selfClosing: func(n *markupNode) string {
+		if htmlVoidElements[n.name] {
+			return ">"
+		}
+		return fmt.Sprintf("></%s>", n.name)
+	},
+	raw: func(n *markupNode) bool {
+		return n.name == "script" || n.name == "style"
+	},
+	preserve: func(n *markupNode) bool {
+		return htmlRawTextElements[n.name]
+	},
+}
+
+// parseHTML builds a markup tree from an HTML document, as a browser would the missing html, head and body
+// elements are added.
+func parseHTML(raw []byte) (*markupNode, error) {
+	root, err := html.Parse(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("parsing html: %w", err)
+	}
+	doc := newMarkupDocument()
+	convertHTMLNode(root, doc, false)
+	return doc, nil
+}
+
+func convertHTMLNode(n *html.Node, parent *markupNode, preserveWhitespace bool) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case html.ElementNode:
+			m := &markupNode{name: c.Data}
+			for _, a := range c.Attr {
+				name := a.Key
+				if a.Namespace != "" {
+					name = a.Namespace + ":" + a.Key
+				}
+				m.attrs = append(m.attrs, markupAttr{name: name, value: a.Val})
+			}
+			m.sortAttrs()
+			parent.appendChild(m)
+			convertHTMLNode(c, m, preserveWhitespace || htmlRawTextElements[c.Data])
+		case html.TextNode:
+			if preserveWhitespace {
+				parent.appendChild(&markupNode{isText: true, text: c.Data})
+				continue
+			}
+			parent.appendText(collapseWhitespace(c.Data))
+		}
+	}
+}
+
+// cssCompound is a sequence of simple selectors, ie: div#main.wide[data-x]
+type cssCompound struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   []xpathPredicate
+}
+
+func (c cssCompound) matches(n *markupNode) bool {
+	if n.isText || n.name == "" {
+		return false
+	}
+	if c.tag != "" && c.tag != n.name {
+		return false
+	}
+	if c.id != "" {
+		if id, _ := n.attr("id"); id != c.id {
+			return false
+		}
+	}
+	if len(c.classes) > 0 {
+		class, _ := n.attr("class")
+		present := map[string]bool{}
+		for _, cl := range strings.Fields(class) {
+			present[cl] = true
+		}
+		for _, cl := range c.classes {
+			if !present[cl] {
+				return false
+			}
+		}
+	}
+	for _, a := range c.attrs {
+		if len(a.filter([]*markupNode{n})) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// cssSelector is a complex selector, compounds joined by descendant (' ') or child ('>') combinators.
+type cssSelector struct {
+	compounds   []cssCompound
+	combinators []byte
+	// attr is set when the selector ended with ::attr(name).
+	attr string
+}
+
+func (s cssSelector) matches(n *markupNode) bool {
+	return s.matchFrom(n, len(s.compounds)-1)
+}
+
+func (s cssSelector) matchFrom(n *markupNode, k int) bool {
+	if !s.compounds[k].matches(n) {
+		return false
+	}
+	if k == 0 {
+		return true
+	}
+	if s.combinators[k-1] == '>' {
+		return n.parent != nil && s.matchFrom(n.parent, k-1)
+	}
+	for p := n.parent; p != nil; p = p.parent {
+		if s.matchFrom(p, k-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCSS parses the CSS subset we support: type, universal, #id, .class, [attr] and [attr=value] selectors
+// joined by descendant or child combinators, in comma separated groups. A trailing ::attr(name) selects
+// an attribute of the matched elements.
+func parseCSS(selector string) ([]cssSelector, error) {
+	var selectors []cssSelector
+	for _, group := range splitCSSGroups(selector) {
+		s, err := parseCSSSelector(strings.TrimSpace(group))
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, s)
+	}
+	return selectors, nil
+}
+
+func splitCSSGroups(selector string) []string {
+	var groups []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(selector); i++ {
+		ch := selector[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '[' || ch == '(':
+			depth++
+		case ch == ']' || ch == ')':
+			depth--
+		case ch == ',' && depth == 0:
+			groups = append(groups, selector[start:i])
+			start = i + 1
+		}
+	}
+	return append(groups, selector[start:])
+}
+
+func isCSSIdentChar(ch byte) bool {
+	return ch == '-' || ch == '_' || ch == ':' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') ||
+		(ch >= '0' && ch <= '9') || ch >= 0x80
+}
+
+func readCSSIdent(s string) string {
+	i := 0
+	for i < len(s) && isCSSIdentChar(s[i]) {
+		// a : is only part of an identifier if it is not the start of a pseudo element.
+		if s[i] == ':' && i+1 < len(s) && s[i+1] == ':' {
+			break
+		}
+		i++
+	}
+	return s[:i]
+}
+
+func parseCSSSelector(s string) (cssSelector, error) {
+	sel := cssSelector{}
+	if p := strings.Index(s, "::attr("); p != -1 {
+		if !strings.HasSuffix(s, ")") {
+			return sel, fmt.Errorf("malformed ::attr() in %q", s)
+		}
+		sel.attr = strings.TrimSpace(s[p+len("::attr(") : len(s)-1])
+		s = strings.TrimSpace(s[:p])
+	}
+	if s == "" {
+		return sel, fmt.Errorf("empty selector")
+	}
+	var current *cssCompound
+	var pending byte
+	finish := func() {
+		if current != nil {
+			sel.compounds = append(sel.compounds, *current)
+			current = nil
+		}
+	}
+	for i := 0; i < len(s); {
+		ch := s[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if current != nil {
+				finish()
+				pending = ' '
+			}
+			i++
+			continue
+		case ch == '>':
+			finish()
+			if len(sel.compounds) == 0 {
+				return sel, fmt.Errorf("selector %q starts with a combinator", s)
+			}
+			pending = '>'
+			i++
+			continue
+		}
+		if current == nil {
+			if len(sel.compounds) > 0 {
+				sel.combinators = append(sel.combinators, pending)
+			}
+			pending = 0
+			current = &cssCompound{}
+		}
+		switch ch {
+		case '*':
+			i++
+		case '#', '.':
+			name := readCSSIdent(s[i+1:])
+			if name == "" {
+				return sel, fmt.Errorf("expected a name after %c in %q", ch, s)
+			}
+			if ch == '#' {
+				current.id = name
+			} else {
+				current.classes = append(current.classes, name)
+			}
+			i += 1 + len(name)
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end == -1 {
+				return sel, fmt.Errorf("unclosed attribute selector in %q", s)
+			}
+			predicates, err := parseXPathPredicates("[@" + s[i+1:i+end] + "]")
+			if err != nil {
+				return sel, err
+			}
+			current.attrs = append(current.attrs, predicates...)
+			i += end + 1
+		default:
+			name := readCSSIdent(s[i:])
+			if name == "" {
+				return sel, fmt.Errorf("unexpected %q in %q", ch, s)
+			}
+			current.tag = strings.ToLower(name)
+			i += len(name)
+		}
+	}
+	finish()
+	if pending != 0 {
+		return sel, fmt.Errorf("selector %q ends with a combinator", s)
+	}
+	return sel, nil
+}
+
+// selectCSS returns the elements selected by selector and, if it selects attributes, the attribute name.
+func selectCSS(doc *markupNode, selector string) ([]*markupNode, string, error) {
+	selectors, err := parseCSS(selector)
+	if err != nil {
+		return nil, "", err
+	}
+	attr := selectors[0].attr
+	for _, s := range selectors[1:] {
+		if s.attr != attr {
+			return nil, "", fmt.Errorf("all the groups of %q must select the same attribute", selector)
+		}
+	}
+	var selected []*markupNode
+	walkMarkup(doc, func(n *markupNode) {
+		for _, s := range selectors {
+			if !s.matches(n) {
+				continue
+			}
+			if _, ok := n.attr(attr); attr == "" || ok {
+				selected = append(selected, n)
+			}
+			return
+		}
+	})
+	return selected, attr, nil
+}
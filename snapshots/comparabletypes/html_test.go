@@ -0,0 +1,128 @@
+package comparabletypes
+
+import (
+	"strings"
+	"testing"
+)
+
+const pageHTML = `<!DOCTYPE html>
+<html>
+<head><title>Orders</title></head>
+<body>
+  <!-- rendered at 22:19 -->
+  <div id="main" class="wide content">
+    <p class="greeting">Hello,
+       <b>Janet</b></p>
+    <span class="timestamp">2022-05-02 22:19:45</span>
+    <a href="/orders?session=abc" class="link">Orders</a>
+  </div>
+</body>
+</html>`
+
+func TestHTML_CompareTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		other    string
+		replacer map[string]string
+		want     string
+	}{
+		{
+			name: "whitespace and attribute order",
+			other: `<html><head><title>Orders</title></head><body><div class="wide content" id="main">
+<p class="greeting">Hello, <b>Janet</b></p><span class="timestamp">2022-05-02 22:19:45</span>
+<a class="link" href="/orders?session=abc">Orders</a></div></body></html>`,
+		},
+		{
+			name: "replaced",
+			other: `<html><head><title>Orders</title></head><body><div class="wide content" id="main">
+<p class="greeting">Hello, <b>Janet</b></p><span class="timestamp">2022-06-01 00:00:00</span>
+<a class="link" href="/orders?session=xyz">Orders</a></div></body></html>`,
+			replacer: map[string]string{"div#main > span.timestamp": "now", "a.link::attr(href)": "/orders"},
+		},
+		{
+			name: "different",
+			other: `<html><head><title>Orders</title></head><body><div class="wide" id="main">
+<p class="greeting">Hello, <b>John</b></p><span class="timestamp">2022-05-02 22:19:45</span>
+</div></body></html>`,
+			want: `/html/body/div: attribute class has value "wide" but we expected "wide content"
+/html/body/div/p/b/text(): expected text "Janet" but got "John"
+/html/body/div/a: element <a> is expected but not present
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHTMLFromString(pageHTML)
+			other := NewHTMLFromString(tt.other)
+			if tt.replacer != nil {
+				h.Replace(tt.replacer)
+				other.Replace(tt.replacer)
+			}
+			got, err := h.CompareTo(other)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("CompareTo() got = \n%s\n, want \n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTML_Dump(t *testing.T) {
+	const want = `<html>
+  <head>
+    <title>Orders</title>
+  </head>
+  <body>
+    <div class="wide content" id="main">
+      <p class="greeting">
+        Hello,
+        <b>Janet</b>
+      </p>
+      <span class="timestamp">2022-05-02 22:19:45</span>
+      <a class="link" href="/orders?session=abc">Orders</a>
+    </div>
+  </body>
+</html>
+`
+	if got := string(NewHTMLFromString(pageHTML).Dump()); got != want {
+		t.Errorf("Dump() got = \n%s\n, want \n%s", got, want)
+	}
+}
+
+func TestHTML_DumpPreservesWhitespace(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+		want string
+	}{
+		{
+			name: "inline elements",
+			page: `<pre>a <b>x</b> c</pre>`,
+			want: "    <pre>a <b>x</b> c</pre>\n",
+		},
+		{
+			name: "leading line break",
+			page: "<pre>\n\nfirst\n  <i>second</i></pre>",
+			want: "    <pre>\n\nfirst\n  <i>second</i></pre>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHTMLFromString(tt.page)
+			dump := h.Dump()
+			if !strings.Contains(string(dump), tt.want) {
+				t.Errorf("Dump() got = \n%s\n, want it to contain \n%s", dump, tt.want)
+			}
+			// go through a dump and load, as a snapshot would.
+			got, err := h.Load(dump).CompareTo(h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != "" {
+				t.Errorf("CompareTo() got = \n%s\n, want no difference", got)
+			}
+		})
+	}
+}
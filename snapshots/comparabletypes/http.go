@@ -16,6 +16,7 @@ var _ snapshots.Comparable = (*Response)(nil)
 
 // Response holds comparable information of a http response.
 type Response struct {
+	withRenderer
 	pretty           bool
 	handlers         map[string]func(string) snapshots.Comparable
 	body             []byte
@@ -31,14 +32,22 @@ type dumpResponse struct {
 	Status  int                 `json:"Status"`
 }
 
-// NewResponse returns a new instance of Response
+// NewResponse returns a new instance of Response, the body is read whole and held in memory: it is handed to the
+// comparable of its content type, which parses it, and most formats can only be compared once complete. Bodies too
+// large for that are better compared with NewStreamedResponse.
 func NewResponse(r *http.Response, pretty bool) (*Response, error) {
 	rq := Response{
 		pretty: pretty,
 		status: r.StatusCode,
 		handlers: map[string]func(string) snapshots.Comparable{
-			"text/plain":       NewPrettyStringComparable,
-			"application/json": NewJSONFromString,
+			"text/plain":         NewPrettyStringComparable,
+			"application/json":   NewJSONFromString,
+			"application/yaml":   NewYAMLFromString,
+			"application/x-yaml": NewYAMLFromString,
+			"text/yaml":          NewYAMLFromString,
+			"application/xml":    NewXMLFromString,
+			"text/xml":           NewXMLFromString,
+			"text/html":          NewHTMLFromString,
 		},
 	}
 	b, err := io.ReadAll(r.Body)
@@ -46,22 +55,26 @@ func NewResponse(r *http.Response, pretty bool) (*Response, error) {
 		return nil, fmt.Errorf("reading body: %w", err)
 	}
 	rq.body = b
-	rq.headers = make(map[string][]string, len(r.Header))
-	rq.headerKeys = make([]string, 0, len(r.Header))
-	for k, v := range r.Header {
+	rq.setHeaders(r.Header)
+	return &rq, nil
+}
+
+// setHeaders holds the headers with lowercase keys and, all nice and tidy for comparisons, sorted keys and values.
+func (r *Response) setHeaders(h http.Header) {
+	r.headers = make(map[string][]string, len(h))
+	r.headerKeys = make([]string, 0, len(h))
+	for k, v := range h {
 		k = strings.ToLower(k)
-		_, ok := rq.headers[k]
-		rq.headers[k] = append(rq.headers[k], v...)
+		_, ok := r.headers[k]
+		r.headers[k] = append(r.headers[k], v...)
 		if !ok {
-			rq.headerKeys = append(rq.headerKeys, k)
+			r.headerKeys = append(r.headerKeys, k)
 		}
 	}
-	// all nice and tidy for comparisons
-	sort.Strings(rq.headerKeys)
-	for _, k := range rq.headerKeys {
-		sort.Strings(rq.headers[k])
+	sort.Strings(r.headerKeys)
+	for _, k := range r.headerKeys {
+		sort.Strings(r.headers[k])
 	}
-	return &rq, nil
 }
 
 func (r *Response) Subtypes() bool {
@@ -100,14 +113,46 @@ func (r *Response) CompareTo(c snapshots.Comparable) (string, error) {
 }
 
 func (r *Response) compareToString(c snapshots.Comparable) (string, error) {
+	expected, got := NewStringComparable(r.String()), NewStringComparable(c.String())
 	if r.pretty {
-		return NewPrettyStringComparable(r.String()).CompareTo(NewPrettyStringComparable(c.String()))
+		expected, got = NewPrettyStringComparable(r.String()), NewPrettyStringComparable(c.String())
 	}
-	return NewStringComparable(r.String()).CompareTo(NewStringComparable(c.String()))
+	r.passRenderer(expected)
+	return expected.CompareTo(got)
 }
 
 func (r *Response) compareToOtherResponse(cr *Response) (string, error) {
 	var result strings.Builder
+	r.compareHead(cr, &result)
+
+	ect := r.contentType()
+	ct := cr.contentType()
+	handler, hasHandler := r.handlers[ect]
+	if ect != ct || ect == "" || !hasHandler {
+		if !reflect.DeepEqual(r.body, cr.body) {
+			result.WriteString("BODY: bodies are different, please inspect them\n")
+		}
+		return result.String(), nil
+	}
+
+	rb := handler(string(r.body))
+	crb := handler(string(cr.body))
+	r.passRenderer(rb)
+	replacer := r.replacerFor(rb.Kind())
+	rb.Replace(replacer)
+	crb.Replace(replacer)
+	bdiff, err := rb.CompareTo(crb)
+	if err != nil {
+		return "", fmt.Errorf("comparing bodies")
+	}
+	if bdiff != "" {
+		result.WriteString(bdiff)
+	}
+	return result.String(), nil
+}
+
+// compareHead writes the differences of status and headers between r and cr to result.
+func (r *Response) compareHead(cr *Response, result *strings.Builder) {
 	// Compare Status
 	if r.status != cr.status {
 		result.WriteString(fmt.Sprintf("Status: expected %d but got %d\n", r.status, cr.status))
@@ -136,34 +181,17 @@ func (r *Response) compareToOtherResponse(cr *Response) (string, error) {
 			result.WriteString(fmt.Sprintf("Headers: key %s is not expected but present, with value %s\n", k, v))
 		}
 	}
-
-	ect := r.contentType()
-	ct := cr.contentType()
-	handler, hasHandler := r.handlers[ect]
-	if ect != ct || ect == "" || !hasHandler {
-		if !reflect.DeepEqual(r.body, cr.body) {
-			result.WriteString("BODY: bodies are different, please inspect them\n")
-		}
-		return result.String(), nil
-	}
-
-	rb := handler(string(r.body))
-	crb := handler(string(cr.body))
-	replacer := r.replacerFor(rb.Kind())
-	rb.Replace(replacer)
-	crb.Replace(replacer)
-	bdiff, err := rb.CompareTo(crb)
-	if err != nil {
-		return "", fmt.Errorf("comparing bodies")
-	}
-	if bdiff != "" {
-		result.WriteString(bdiff)
-	}
-	return result.String(), nil
 }
 
 func (r *Response) String() string {
 	s := strings.Builder{}
+	r.writeHead(&s)
+	s.Write(r.body)
+	return s.String()
+}
+
+// writeHead writes the status and headers, followed by a blank line, to s.
+func (r *Response) writeHead(s *strings.Builder) {
 	s.WriteString(fmt.Sprintf("STATUS: %d\n", r.status))
 	for _, k := range r.headerKeys {
 		var v string
@@ -175,8 +203,6 @@ func (r *Response) String() string {
 		s.WriteString(fmt.Sprintf("%s: %s\n", k, v))
 	}
 	s.WriteString("\n")
-	s.Write(r.body)
-	return s.String()
 }
 
 func (r *Response) Kind() snapshots.Kind {
@@ -184,11 +210,7 @@ func (r *Response) Kind() snapshots.Kind {
 }
 
 func (r *Response) Dump() []byte {
-	dumpable := dumpResponse{
-		Headers: r.headers,
-		Status:  r.status,
-	}
-	m, err := json.MarshalIndent(&dumpable, "", "  ")
+	m, err := r.dumpHead()
 	if err != nil {
 		panic(err)
 	}
@@ -207,6 +229,15 @@ func (r *Response) Dump() []byte {
 	return append(m, append([]byte(headerSep), r.body...)...)
 }
 
+// dumpHead returns the status and headers as they are stored before the body, separated from it by headerSep.
+func (r *Response) dumpHead() ([]byte, error) {
+	dumpable := dumpResponse{
+		Headers: r.headers,
+		Status:  r.status,
+	}
+	return json.MarshalIndent(&dumpable, "", "  ")
+}
+
 const headerSep = "\n\n"
 
 func (r *Response) Load(req []byte) snapshots.Comparable {
@@ -217,10 +248,22 @@ func (r *Response) Load(req []byte) snapshots.Comparable {
 	if splitLine == -1 {
 		panic(fmt.Errorf("cannot read a request in this file"))
 	}
-	dumped := &dumpResponse{}
-	err := json.Unmarshal(req[:splitLine], dumped)
+	newR, err := loadHead(req[:splitLine])
 	if err != nil {
-		panic(fmt.Errorf("unmarshaling Headers: %w", err))
+		panic(err)
+	}
+	newR.withRenderer = r.withRenderer
+	newR.body = req[splitLine+len(headerSep):]
+	// we want handler parity, plus user originally will modify r
+	newR.handlers = r.handlers
+	return newR
+}
+
+// loadHead returns a Response with the status and headers stored in head and no body.
+func loadHead(head []byte) (*Response, error) {
+	dumped := &dumpResponse{}
+	if err := json.Unmarshal(head, dumped); err != nil {
+		return nil, fmt.Errorf("unmarshaling Headers: %w", err)
 	}
 	headerKeys := make([]string, 0, len(dumped.Headers))
 	for k := range dumped.Headers {
@@ -230,15 +273,11 @@ func (r *Response) Load(req []byte) snapshots.Comparable {
 	for _, k := range headerKeys {
 		sort.Strings(dumped.Headers[k])
 	}
-	newR := Response{
+	return &Response{
 		headerKeys: headerKeys,
 		status:     dumped.Status,
 		headers:    dumped.Headers,
-		body:       req[splitLine+len(headerSep):],
-	}
-	// we want handler parity, plus user originally will modify r
-	newR.handlers = r.handlers
-	return &newR
+	}, nil
 }
 
 func (r *Response) Replace(m map[string]string) {
@@ -0,0 +1,170 @@
+package comparabletypes
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"perri.to/expect/snapshots"
+)
+
+var (
+	_ snapshots.Comparable      = (*StreamedResponse)(nil)
+	_ snapshots.StreamLoader    = (*StreamedResponse)(nil)
+	_ snapshots.StreamDumper    = (*StreamedResponse)(nil)
+	_ snapshots.ContextComparer = (*StreamedResponse)(nil)
+)
+
+// StreamedResponse holds a http response whose body is too large to be kept in memory, the status and headers are
+// compared as Response does and the body is spooled and compared line by line, as a Stream. Its snapshots have the
+// same format as the ones of Response. StreamedResponses must be closed to remove the temporary file of the body.
+type StreamedResponse struct {
+	head *Response
+	body *Stream
+	// loadErr holds the error of loading a snapshot, if any, it is reported when comparing.
+	loadErr error
+}
+
+// NewStreamedResponse returns a new instance of StreamedResponse, the body is read and closed.
+func NewStreamedResponse(r *http.Response) (*StreamedResponse, error) {
+	defer r.Body.Close()
+	body, err := NewStreamComparable(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading body: %w", err)
+	}
+	head := &Response{status: r.StatusCode}
+	head.setHeaders(r.Header)
+	return &StreamedResponse{head: head, body: body}, nil
+}
+
+// SetMaxDifferences sets how many differing lines of the body are reported before the comparison stops.
+func (r *StreamedResponse) SetMaxDifferences(n int) {
+	r.body.SetMaxDifferences(n)
+}
+
+// Close removes the temporary file holding the body.
+func (r *StreamedResponse) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	return r.body.Close()
+}
+
+func (r *StreamedResponse) Subtypes() bool {
+	return true
+}
+
+// ReplaceSubtypes applies the stream replacers to the body.
+func (r *StreamedResponse) ReplaceSubtypes(replacers map[snapshots.Kind]map[string]string) {
+	r.body.Replace(replacers[KindStream])
+}
+
+func (r *StreamedResponse) CompareTo(c snapshots.Comparable) (string, error) {
+	return r.CompareToContext(context.Background(), c)
+}
+
+// CompareToContext implements snapshots.ContextComparer, the body comparison stops as the one of Stream does.
+func (r *StreamedResponse) CompareToContext(ctx context.Context, c snapshots.Comparable) (string, error) {
+	other, ok := c.(*StreamedResponse)
+	if !ok {
+		return "", snapshots.CantCompare(fmt.Sprintf("%T", r), fmt.Sprintf("%T", c))
+	}
+	switch {
+	case r.loadErr != nil && other.loadErr != nil:
+		return "", snapshots.BothPartsInvalid(fmt.Sprintf("%T", r), fmt.Sprintf("%T", c), r.Kind())
+	case r.loadErr != nil:
+		return "", snapshots.InvalidSource(fmt.Sprintf("%T", r), r.Kind())
+	case other.loadErr != nil:
+		return "", snapshots.InvalidTarget(fmt.Sprintf("%T", c), c.Kind())
+	}
+	var result strings.Builder
+	r.head.compareHead(other.head, &result)
+	bdiff, err := r.body.CompareToContext(ctx, other.body)
+	if err != nil {
+		return "", fmt.Errorf("comparing bodies: %w", err)
+	}
+	result.WriteString(bdiff)
+	return result.String(), nil
+}
+
+// String returns the whole response, which defeats its purpose.
+func (r *StreamedResponse) String() string {
+	var s strings.Builder
+	r.head.writeHead(&s)
+	s.WriteString(r.body.String())
+	return s.String()
+}
+
+func (r *StreamedResponse) Kind() snapshots.Kind {
+	return r.head.Kind()
+}
+
+// Dump returns the whole response, which defeats its purpose, snapshots are written with DumpTo.
+func (r *StreamedResponse) Dump() []byte {
+	var b bytes.Buffer
+	if err := r.DumpTo(&b); err != nil {
+		panic(err)
+	}
+	return b.Bytes()
+}
+
+// DumpTo writes the status and headers and then the body, with the replacements applied, to w.
+func (r *StreamedResponse) DumpTo(w io.Writer) error {
+	head, err := r.head.dumpHead()
+	if err != nil {
+		return fmt.Errorf("marshaling response head: %w", err)
+	}
+	if _, err := w.Write(append(head, headerSep...)); err != nil {
+		return err
+	}
+	return r.body.DumpTo(w)
+}
+
+// Load reads the snapshot in raw, StreamedResponses are loaded with LoadReader when run through the helpers.
+func (r *StreamedResponse) Load(raw []byte) snapshots.Comparable {
+	loaded, err := r.LoadReader(bytes.NewReader(raw))
+	if err != nil {
+		return &StreamedResponse{head: &Response{}, body: &Stream{}, loadErr: err}
+	}
+	return loaded
+}
+
+// LoadReader reads the status and headers from r and spools the body into a new StreamedResponse, which must be
+// closed.
+func (r *StreamedResponse) LoadReader(rd io.Reader) (snapshots.Comparable, error) {
+	br := bufio.NewReader(rd)
+	// the head is indented json, it has no blank lines, so it ends at the first one.
+	var head []byte
+	for {
+		line, err := br.ReadBytes('\n')
+		if err != nil {
+			return nil, fmt.Errorf("cannot read a response in this snapshot")
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			break
+		}
+		head = append(head, line...)
+	}
+	loadedHead, err := loadHead(head)
+	if err != nil {
+		return nil, err
+	}
+	body, err := r.body.LoadReader(br)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamedResponse{head: loadedHead, body: body.(*Stream)}, nil
+}
+
+// Replace takes header keys, whose values will match whatever they are, as Response does.
+func (r *StreamedResponse) Replace(m map[string]string) {
+	r.head.Replace(m)
+}
+
+func (r *StreamedResponse) Extension() string {
+	return r.head.Extension()
+}
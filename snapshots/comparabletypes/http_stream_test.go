@@ -0,0 +1,74 @@
+package comparabletypes
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStreamedResponse_CompareTo(t *testing.T) {
+	var status int
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = []string{"text/plain"}
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+	get := func(t *testing.T, s int, b string) *StreamedResponse {
+		t.Helper()
+		status, body = s, b
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewStreamedResponse(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { r.Close() })
+		r.Replace(map[string]string{"date": "<date>"})
+		return r
+	}
+
+	expected := get(t, http.StatusOK, "packet 1\npacket 2\n")
+	var b bytes.Buffer
+	if err := expected.DumpTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := expected.LoadReader(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.(*StreamedResponse).Close()
+	loaded.Replace(map[string]string{"date": "<date>"})
+
+	got, err := loaded.CompareTo(get(t, http.StatusOK, "packet 1\npacket 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "" {
+		t.Errorf("CompareTo() = %q, want no differences", got)
+	}
+
+	got, err = loaded.CompareTo(get(t, http.StatusAccepted, "packet 1\npacket 3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `Status: expected 200 but got 202
+line 2: expected "packet 2" but got "packet 3"
+`
+	if got != want {
+		t.Errorf("CompareTo() = \n%s\n, want \n%s", got, want)
+	}
+}
+
+func TestStreamedResponse_LoadInvalid(t *testing.T) {
+	r := &StreamedResponse{head: &Response{}, body: &Stream{}}
+	loaded := r.Load([]byte("not a response"))
+	if _, err := loaded.CompareTo(loaded); err == nil {
+		t.Error("CompareTo() expected an error for an invalid snapshot")
+	}
+}
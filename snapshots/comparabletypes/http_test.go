@@ -2,8 +2,11 @@ package comparabletypes
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -71,3 +74,76 @@ func TestHTTPResponse(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestHTTPResponse_LargeBodyAsStream(t *testing.T) {
+	body := func(changedLine int) string {
+		var b strings.Builder
+		for i := 1; i <= 100000; i++ {
+			if i == changedLine {
+				b.WriteString("changed\n")
+				continue
+			}
+			b.WriteString(fmt.Sprintf("record %d\n", i))
+		}
+		return b.String()
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "text/plain")
+		io.WriteString(w, body(50000))
+	}))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	// the body is spooled as it is read, instead of being held in memory as NewResponse does.
+	got, err := NewStreamComparable(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer got.Close()
+	diff, err := newTestStream(t, body(0)).CompareTo(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "Size: expected 1288895 bytes but got 1288890\nline 50000: expected \"record 50000\" but got \"changed\"\n"
+	if diff != want {
+		t.Errorf("CompareTo() got = %q, want %q", diff, want)
+	}
+}
+
+func TestHTTPResponse_YAMLContentTypes(t *testing.T) {
+	for _, contentType := range []string{"application/yaml", "application/x-yaml", "text/yaml"} {
+		t.Run(contentType, func(t *testing.T) {
+			var body string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("content-type", contentType+"; charset=utf-8")
+				io.WriteString(w, body)
+			}))
+			defer srv.Close()
+			get := func(b string) *Response {
+				body = b
+				resp, err := http.Get(srv.URL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer resp.Body.Close()
+				rc, err := NewResponse(resp, true)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rc.Replace(map[string]string{"content-length": "0"})
+				return rc
+			}
+			expected, got := get("name: app\nreplicas: 3\n"), get("replicas: 3 # reordered\nname: app\n")
+			diff, err := expected.CompareTo(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff != "" {
+				t.Errorf("CompareTo() got = %q, want the bodies compared as YAML", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package comparabletypes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+
+	"perri.to/expect/snapshots"
+)
+
+var _ snapshots.Comparable = (*Image)(nil)
+var _ snapshots.SnapshotLocator = (*Image)(nil)
+var _ snapshots.Tolerant = (*Image)(nil)
+var _ snapshots.ContextComparer = (*Image)(nil)
+
+// register the formats we understand even if the user did not import them.
+var (
+	_ = gif.Decode
+	_ = jpeg.Decode
+	_ = png.Decode
+)
+
+// Image holds an encoded PNG, JPEG or GIF image, which is compared pixel by pixel. When images differ and the
+// snapshot location is known, an image highlighting the differences is written next to the snapshot.
+type Image struct {
+	raw    []byte
+	format string
+	// tolerance is the per channel difference, as a fraction of the full scale, allowed for a pixel to be
+	// considered equal.
+	tolerance    float64
+	snapshotPath string
+}
+
+// NewImageComparable constructs an Image comparable from an encoded image.
+func NewImageComparable(b []byte) (*Image, error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("decoding image: %w", err)
+	}
+	return &Image{raw: b, format: format}, nil
+}
+
+// SetTolerance sets how much, as a fraction between 0 and 1, each color channel of a pixel can differ before
+// we consider the pixel different, this is useful for lossy formats.
+func (i *Image) SetTolerance(tolerance float64) error {
+	if !(tolerance >= 0 && tolerance <= 1) {
+		return fmt.Errorf("invalid image tolerance %v, it must be between 0 and 1", tolerance)
+	}
+	i.tolerance = tolerance
+	return nil
+}
+
+// SetSnapshotPath implements snapshots.SnapshotLocator so we know where to write the diff image.
+func (i *Image) SetSnapshotPath(p string) {
+	i.snapshotPath = p
+}
+
+func (i *Image) diffImagePath() string {
+	return snapshots.SidecarPath(i.snapshotPath, "diff.png")
+}
+
+func (i *Image) Subtypes() bool {
+	return false
+}
+
+func (i *Image) ReplaceSubtypes(_ map[snapshots.Kind]map[string]string) {
+	return
+}
+
+func (i *Image) CompareTo(c snapshots.Comparable) (string, error) {
+	return i.CompareToContext(context.Background(), c)
+}
+
+// CompareToContext implements snapshots.ContextComparer, once ctx is done the pixels are no longer compared and
+// no diff image is written.
+func (i *Image) CompareToContext(ctx context.Context, c snapshots.Comparable) (string, error) {
+	other, isImage := c.(*Image)
+	if !isImage {
+		return "", snapshots.CantCompare(fmt.Sprintf("%T", i), fmt.Sprintf("%T", c))
+	}
+	expected, _, sourceErr := image.Decode(bytes.NewReader(i.raw))
+	got, _, targetErr := image.Decode(bytes.NewReader(other.raw))
+	switch {
+	case sourceErr != nil && targetErr != nil:
+		return "", snapshots.BothPartsInvalid(fmt.Sprintf("%T", i), fmt.Sprintf("%T", c), i.Kind())
+	case sourceErr != nil:
+		return "", snapshots.InvalidSource(fmt.Sprintf("%T", i), i.Kind())
+	case targetErr != nil:
+		return "", snapshots.InvalidTarget(fmt.Sprintf("%T", c), c.Kind())
+	}
+
+	eb, gb := expected.Bounds(), got.Bounds()
+	if eb.Dx() != gb.Dx() || eb.Dy() != gb.Dy() {
+		// there is no diff image of images of different sizes, a previous one would be misleading.
+		if err := i.removeDiffImage(); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Size: expected %dx%d but got %dx%d\n", eb.Dx(), eb.Dy(), gb.Dx(), gb.Dy()), nil
+	}
+
+	diffImage := image.NewRGBA(image.Rect(0, 0, eb.Dx(), eb.Dy()))
+	differentPixels := 0
+	var first image.Point
+	for y := 0; y < eb.Dy(); y++ {
+		if ctx.Err() != nil {
+			return snapshots.DiffSummary(i.raw, other.raw), nil
+		}
+		for x := 0; x < eb.Dx(); x++ {
+			ec := expected.At(eb.Min.X+x, eb.Min.Y+y)
+			gc := got.At(gb.Min.X+x, gb.Min.Y+y)
+			if i.pixelsMatch(ec, gc) {
+				// a faded version of the original, so the differences stand out.
+				gray := color.GrayModel.Convert(ec).(color.Gray)
+				diffImage.Set(x, y, color.RGBA{R: gray.Y, G: gray.Y, B: gray.Y, A: 64})
+				continue
+			}
+			if differentPixels == 0 {
+				first = image.Pt(x, y)
+			}
+			differentPixels++
+			diffImage.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	if differentPixels == 0 {
+		return "", i.removeDiffImage()
+	}
+
+	total := eb.Dx() * eb.Dy()
+	result := fmt.Sprintf("Pixels: %d of %d pixels (%.2f%%) differ, the first one at (%d, %d)\n",
+		differentPixels, total, float64(differentPixels)*100/float64(total), first.X, first.Y)
+	if i.snapshotPath != "" {
+		if err := writeDiffImage(i.diffImagePath(), diffImage); err != nil {
+			return "", fmt.Errorf("writing diff image: %w", err)
+		}
+		result += fmt.Sprintf("Pixels: the differences are highlighted in %s\n", i.diffImagePath())
+	}
+	return result, nil
+}
+
+func (i *Image) pixelsMatch(a, b color.Color) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	allowed := i.tolerance * 0xffff
+	for _, channels := range [][2]uint32{{ar, br}, {ag, bg}, {ab, bb}, {aa, ba}} {
+		delta := float64(channels[0]) - float64(channels[1])
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta > allowed {
+			return false
+		}
+	}
+	return true
+}
+
+func writeDiffImage(p string, img image.Image) error {
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return err
+	}
+	return os.WriteFile(p, b.Bytes(), 0644)
+}
+
+// removeDiffImage gets rid of the diff image of a previous failure, if any.
+func (i *Image) removeDiffImage() error {
+	if i.snapshotPath == "" {
+		return nil
+	}
+	if err := os.Remove(i.diffImagePath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing stale diff image: %w", err)
+	}
+	return nil
+}
+
+func (i *Image) String() string {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(i.raw))
+	if err != nil {
+		return fmt.Sprintf("invalid image of %d bytes", len(i.raw))
+	}
+	return fmt.Sprintf("%s image of %dx%d pixels, %d bytes", format, cfg.Width, cfg.Height, len(i.raw))
+}
+
+const KindImage snapshots.Kind = "image"
+
+func (i *Image) Kind() snapshots.Kind {
+	return KindImage
+}
+
+// Dump returns the image as it was passed, in its original encoding.
+func (i *Image) Dump() []byte {
+	return i.raw
+}
+
+func (i *Image) Load(raw []byte) snapshots.Comparable {
+	_, format, _ := image.DecodeConfig(bytes.NewReader(raw))
+	return &Image{raw: raw, format: format, tolerance: i.tolerance, snapshotPath: i.snapshotPath}
+}
+
+// Replace does nothing, there is no sensible way to replace parts of an image.
+func (i *Image) Replace(_ map[string]string) {
+	return
+}
+
+func (i *Image) Extension() string {
+	if i.format == "" {
+		return "img"
+	}
+	return i.format
+}
@@ -0,0 +1,166 @@
+package comparabletypes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"perri.to/expect/snapshots"
+)
+
+func encodedSquare(t *testing.T, c color.Color, dot color.Color) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	img.Set(3, 4, dot)
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestImage_CompareTo(t *testing.T) {
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	tests := []struct {
+		name      string
+		other     []byte
+		tolerance float64
+		want      string
+		wantDiff  bool
+	}{
+		{
+			name:  "equal",
+			other: encodedSquare(t, white, white),
+		},
+		{
+			name:      "within tolerance",
+			other:     encodedSquare(t, white, color.RGBA{R: 250, G: 250, B: 250, A: 255}),
+			tolerance: 0.05,
+		},
+		{
+			name:  "different",
+			other: encodedSquare(t, white, color.RGBA{A: 255}),
+			want: "Pixels: 1 of 100 pixels (1.00%%) differ, the first one at (3, 4)\n" +
+				"Pixels: the differences are highlighted in %s\n",
+			wantDiff: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshotPath := filepath.Join(t.TempDir(), "square.png")
+			diffPath := snapshots.SidecarPath(snapshotPath, "diff.png")
+			actual, err := NewImageComparable(tt.other)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := actual.SetTolerance(tt.tolerance); err != nil {
+				t.Fatal(err)
+			}
+			actual.SetSnapshotPath(snapshotPath)
+			expectation := actual.Load(encodedSquare(t, white, white))
+			got, err := expectation.CompareTo(actual)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			if tt.wantDiff {
+				want = fmt.Sprintf(tt.want, diffPath)
+			}
+			if got != want {
+				t.Errorf("CompareTo() got = %q, want %q", got, want)
+			}
+			_, err = os.Stat(diffPath)
+			if (err == nil) != tt.wantDiff {
+				t.Errorf("diff image presence is %v, want %v", err == nil, tt.wantDiff)
+			}
+		})
+	}
+}
+
+func TestImage_CompareToSize(t *testing.T) {
+	small, err := NewImageComparable(encodedSquare(t, color.White, color.White))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, image.NewGray(image.Rect(0, 0, 4, 2))); err != nil {
+		t.Fatal(err)
+	}
+	got, err := small.CompareTo(small.Load(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Size: expected 10x10 but got 4x2\n"; got != want {
+		t.Errorf("CompareTo() got = %q, want %q", got, want)
+	}
+	if small.Extension() != "png" {
+		t.Errorf("Extension() got = %q, want png", small.Extension())
+	}
+}
+
+func TestImage_CompareToContext(t *testing.T) {
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	snapshotPath := filepath.Join(t.TempDir(), "square.png")
+	expected, err := NewImageComparable(encodedSquare(t, white, white))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected.SetSnapshotPath(snapshotPath)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got, err := expected.CompareToContext(ctx, expected.Load(encodedSquare(t, white, color.Black)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(got, "(the comparison took too long to show the whole difference)\n") {
+		t.Errorf("CompareToContext() got = %q, want a summary", got)
+	}
+	if _, err := os.Stat(snapshots.SidecarPath(snapshotPath, "diff.png")); !os.IsNotExist(err) {
+		t.Errorf("CompareToContext() wrote a diff image, stat error = %v", err)
+	}
+}
+
+func TestImage_CompareToStaleDiffImage(t *testing.T) {
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	snapshotPath := filepath.Join(t.TempDir(), "square.png")
+	// a diff image that can not be removed, a directory with contents stands in for it.
+	diffPath := snapshots.SidecarPath(snapshotPath, "diff.png")
+	if err := os.MkdirAll(filepath.Join(diffPath, "contents"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := NewImageComparable(encodedSquare(t, white, white))
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual.SetSnapshotPath(snapshotPath)
+	if _, err := actual.Load(encodedSquare(t, white, white)).CompareTo(actual); err == nil {
+		t.Error("CompareTo() error = nil, want the failure to remove the stale diff image")
+	}
+}
+
+func TestImage_SetTolerance(t *testing.T) {
+	img, err := NewImageComparable(encodedSquare(t, color.White, color.White))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tolerance := range []float64{-0.1, 1.5, math.NaN()} {
+		if err := img.SetTolerance(tolerance); err == nil {
+			t.Errorf("SetTolerance(%v) expected an error", tolerance)
+		}
+	}
+	if err := img.SetTolerance(1); err != nil {
+		t.Errorf("SetTolerance(1) error = %v", err)
+	}
+}
@@ -13,6 +13,7 @@ import (
 var _ snapshots.Comparable = (*JSON)(nil)
 
 type JSON struct {
+	withRenderer
 	rawJSON json.RawMessage
 }
 
@@ -35,7 +36,7 @@ func (j *JSON) ReplaceSubtypes(_ map[snapshots.Kind]map[string]string) {
 }
 
 func (j *JSON) CompareTo(c snapshots.Comparable) (string, error) {
-	opts := jsondiff.DefaultConsoleOptions()
+	opts := jsondiffOptions(j.currentRenderer().Theme)
 
 	// TODO: Take string here too, we should be able to handle it most of the time
 	newJSON, isJSON := c.(*JSON)
@@ -81,7 +82,7 @@ func (j *JSON) Dump() []byte {
 }
 
 func (j *JSON) Load(rawJSON []byte) snapshots.Comparable {
-	return &JSON{rawJSON: rawJSON}
+	return &JSON{withRenderer: j.withRenderer, rawJSON: rawJSON}
 }
 
 func (j *JSON) Replace(rs map[string]string) {
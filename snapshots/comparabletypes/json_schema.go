@@ -0,0 +1,487 @@
+package comparabletypes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/sjson"
+
+	"perri.to/expect/snapshots"
+)
+
+var _ snapshots.Comparable = (*JSONSchema)(nil)
+
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema compares JSON documents by shape rather than by value, when snapshotted it stores a JSON Schema
+// inferred from the document and, when compared, it validates the new document against the stored schema.
+// It shares KindJSON with JSON, so the json replacers apply to the document before the schema is inferred
+// or validated, which is mostly useful for the value checked paths.
+type JSONSchema struct {
+	rawJSON json.RawMessage
+	schema  json.RawMessage
+	// valueChecked holds the paths (in sjson syntax, using # for all the items of an array) which values are
+	// pinned in the inferred schema.
+	valueChecked []string
+}
+
+// NewJSONSchemaFromString constructs a JSONSchema from a JSON document, the values in valueChecked paths
+// will be part of the inferred schema.
+func NewJSONSchemaFromString(s string, valueChecked ...string) snapshots.Comparable {
+	return NewJSONSchemaFromBytes([]byte(s), valueChecked...)
+}
+
+// NewJSONSchemaFromBytes constructs a JSONSchema from a JSON document, the values in valueChecked paths
+// will be part of the inferred schema.
+func NewJSONSchemaFromBytes(b []byte, valueChecked ...string) snapshots.Comparable {
+	j := JSONSchema{rawJSON: b, valueChecked: valueChecked}
+	return &j
+}
+
+func (j *JSONSchema) Subtypes() bool {
+	return false
+}
+
+func (j *JSONSchema) ReplaceSubtypes(_ map[snapshots.Kind]map[string]string) {
+	return
+}
+
+// CompareTo validates the document in c against the schema held by j, if j was not loaded from a snapshot
+// the schema is inferred from its own document.
+func (j *JSONSchema) CompareTo(c snapshots.Comparable) (string, error) {
+	var target json.RawMessage
+	switch other := c.(type) {
+	case *JSONSchema:
+		target = other.rawJSON
+	case *JSON:
+		target = other.rawJSON
+	default:
+		return "", snapshots.CantCompare(fmt.Sprintf("%T", j), fmt.Sprintf("%T", c))
+	}
+
+	rawSchema := j.schema
+	if rawSchema == nil {
+		if len(j.rawJSON) == 0 && len(target) == 0 {
+			// empty therefore equal
+			return "", nil
+		}
+		var err error
+		if rawSchema, err = j.inferSchema(); err != nil {
+			return "", snapshots.InvalidSource(fmt.Sprintf("%T", j), j.Kind())
+		}
+	}
+	schema := &jsonSchema{}
+	if err := json.Unmarshal(rawSchema, schema); err != nil {
+		return "", snapshots.InvalidSource(fmt.Sprintf("%T", j), j.Kind())
+	}
+	document, err := decodeJSONDocument(target)
+	if err != nil {
+		return "", snapshots.InvalidTarget(fmt.Sprintf("%T", c), c.Kind())
+	}
+	violations, err := schema.validate(document, "$")
+	if err != nil {
+		return "", fmt.Errorf("validating document against schema: %w", err)
+	}
+	return strings.Join(violations, "\n"), nil
+}
+
+func (j *JSONSchema) String() string {
+	if j.rawJSON == nil {
+		return string(j.schema)
+	}
+	return string(j.rawJSON)
+}
+
+func (j *JSONSchema) Kind() snapshots.Kind {
+	return KindJSON
+}
+
+// Dump returns the schema, inferring it from the document if we were not loaded from a snapshot, if the document
+// can't be parsed it is returned as is.
+func (j *JSONSchema) Dump() []byte {
+	if j.schema != nil {
+		return j.schema
+	}
+	schema, err := j.inferSchema()
+	if err != nil {
+		return j.rawJSON
+	}
+	return schema
+}
+
+func (j *JSONSchema) Load(rawSchema []byte) snapshots.Comparable {
+	return &JSONSchema{schema: rawSchema, valueChecked: j.valueChecked}
+}
+
+// Replace sets the values of the passed paths in the document, the schema, if any, is not affected.
+func (j *JSONSchema) Replace(rs map[string]string) {
+	if j.rawJSON == nil {
+		return
+	}
+	var err error
+	for k, v := range rs {
+		j.rawJSON, err = sjson.SetBytes(j.rawJSON, k, v)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (j *JSONSchema) Extension() string {
+	return "schema.json"
+}
+
+func (j *JSONSchema) inferSchema() (json.RawMessage, error) {
+	document, err := decodeJSONDocument(j.rawJSON)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := inferJSONSchema(document, nil, j.valueChecked)
+	if err != nil {
+		return nil, err
+	}
+	schema.Schema = jsonSchemaDialect
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+func decodeJSONDocument(raw []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var document interface{}
+	if err := dec.Decode(&document); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("trailing data after json document")
+	}
+	return document, nil
+}
+
+// schemaTypes is the type keyword of a schema, which can be either a string or a list of them.
+type schemaTypes []string
+
+func (t schemaTypes) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *schemaTypes) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return fmt.Errorf("type must be a string or a list of strings: %w", err)
+	}
+	*t = many
+	return nil
+}
+
+func (t schemaTypes) allows(typeName string) bool {
+	for _, allowed := range t {
+		if allowed == typeName || (allowed == "number" && typeName == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonSchema holds the subset of JSON Schema we infer and validate, it is enough to describe the shape of a
+// document and can be extended by hand, in the snapshot, with enum, pattern and anyOf.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Type                 schemaTypes            `json:"type,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Const                *json.RawMessage       `json:"const,omitempty"`
+	Enum                 []json.RawMessage      `json:"enum,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	AnyOf                []*jsonSchema          `json:"anyOf,omitempty"`
+}
+
+func jsonTypeOf(v interface{}) string {
+	switch tv := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := tv.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// isValueChecked returns true if the path, where each array item is represented by #, is one of the checked ones.
+func isValueChecked(path []string, valueChecked []string) bool {
+	joined := strings.Join(path, ".")
+	for _, p := range valueChecked {
+		if p == joined {
+			return true
+		}
+	}
+	return false
+}
+
+func inferJSONSchema(v interface{}, path []string, valueChecked []string) (*jsonSchema, error) {
+	schema := &jsonSchema{}
+	if isValueChecked(path, valueChecked) {
+		m, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling value for %s: %w", strings.Join(path, "."), err)
+		}
+		raw := json.RawMessage(m)
+		schema.Const = &raw
+	}
+	switch tv := v.(type) {
+	case json.Number:
+		// integers tend to become floats between runs, we are only interested in it being a number.
+		schema.Type = schemaTypes{"number"}
+	case []interface{}:
+		schema.Type = schemaTypes{"array"}
+		for _, item := range tv {
+			itemSchema, err := inferJSONSchema(item, append(path, "#"), valueChecked)
+			if err != nil {
+				return nil, err
+			}
+			schema.Items = mergeJSONSchemas(schema.Items, itemSchema)
+		}
+	case map[string]interface{}:
+		schema.Type = schemaTypes{"object"}
+		schema.Properties = make(map[string]*jsonSchema, len(tv))
+		schema.Required = make([]string, 0, len(tv))
+		for k, item := range tv {
+			itemSchema, err := inferJSONSchema(item, append(path, k), valueChecked)
+			if err != nil {
+				return nil, err
+			}
+			schema.Properties[k] = itemSchema
+			schema.Required = append(schema.Required, k)
+		}
+		sort.Strings(schema.Required)
+		noAdditional := false
+		schema.AdditionalProperties = &noAdditional
+	default:
+		schema.Type = schemaTypes{jsonTypeOf(v)}
+	}
+	return schema, nil
+}
+
+// mergeJSONSchemas produces a schema that accepts what both a and b accept, as it is used for array items, it
+// is not a general union but a reasonable approximation for the shapes we infer.
+func mergeJSONSchemas(a, b *jsonSchema) *jsonSchema {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	merged := &jsonSchema{}
+	typeNames := map[string]bool{}
+	for _, t := range append(append(schemaTypes{}, a.Type...), b.Type...) {
+		typeNames[t] = true
+	}
+	for t := range typeNames {
+		merged.Type = append(merged.Type, t)
+	}
+	sort.Strings(merged.Type)
+
+	if a.Properties != nil || b.Properties != nil {
+		merged.Properties = map[string]*jsonSchema{}
+		for k, v := range a.Properties {
+			merged.Properties[k] = v
+		}
+		for k, v := range b.Properties {
+			merged.Properties[k] = mergeJSONSchemas(merged.Properties[k], v)
+		}
+		switch {
+		case a.Properties == nil:
+			merged.Required = b.Required
+		case b.Properties == nil:
+			merged.Required = a.Required
+		default:
+			// only what is present in every item is required.
+			inB := map[string]bool{}
+			for _, k := range b.Required {
+				inB[k] = true
+			}
+			merged.Required = []string{}
+			for _, k := range a.Required {
+				if inB[k] {
+					merged.Required = append(merged.Required, k)
+				}
+			}
+		}
+		noAdditional := false
+		merged.AdditionalProperties = &noAdditional
+	}
+	merged.Items = mergeJSONSchemas(a.Items, b.Items)
+
+	aValues, bValues := a.allowedValues(), b.allowedValues()
+	if aValues != nil && bValues != nil {
+		for _, v := range bValues {
+			if !containsJSONValue(aValues, v) {
+				aValues = append(aValues, v)
+			}
+		}
+		if len(aValues) == 1 {
+			merged.Const = &aValues[0]
+		} else {
+			merged.Enum = aValues
+		}
+	}
+	return merged
+}
+
+func (s *jsonSchema) allowedValues() []json.RawMessage {
+	if s.Const != nil {
+		return []json.RawMessage{*s.Const}
+	}
+	return s.Enum
+}
+
+func containsJSONValue(values []json.RawMessage, v json.RawMessage) bool {
+	for _, candidate := range values {
+		if jsonValuesEqual(candidate, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonValuesEqual(a, b json.RawMessage) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	// maps are marshaled with sorted keys, so a round trip makes key order irrelevant.
+	da, errA := decodeJSONDocument(ca.Bytes())
+	db, errB := decodeJSONDocument(cb.Bytes())
+	if errA != nil || errB != nil {
+		return bytes.Equal(ca.Bytes(), cb.Bytes())
+	}
+	ma, _ := json.Marshal(da)
+	mb, _ := json.Marshal(db)
+	return bytes.Equal(ma, mb)
+}
+
+// validate returns the list of violations of the schema by v, which is located in path.
+func (s *jsonSchema) validate(v interface{}, path string) ([]string, error) {
+	var violations []string
+	typeName := jsonTypeOf(v)
+	if len(s.Type) > 0 && !s.Type.allows(typeName) {
+		return []string{fmt.Sprintf("%s: expected %s but got %s", path, strings.Join(s.Type, " or "), typeName)}, nil
+	}
+
+	if s.Const != nil || s.Enum != nil {
+		m, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling value at %s: %w", path, err)
+		}
+		if s.Const != nil && !jsonValuesEqual(*s.Const, m) {
+			violations = append(violations, fmt.Sprintf("%s: expected value %s but got %s", path, compactJSON(*s.Const), m))
+		}
+		if s.Enum != nil && !containsJSONValue(s.Enum, m) {
+			allowed := make([]string, 0, len(s.Enum))
+			for _, e := range s.Enum {
+				allowed = append(allowed, compactJSON(e))
+			}
+			violations = append(violations, fmt.Sprintf("%s: value %s is not one of %s", path, m, strings.Join(allowed, ", ")))
+		}
+	}
+
+	if s.Pattern != "" {
+		if str, ok := v.(string); ok {
+			re, err := regexp.Compile(s.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("compiling pattern at %s: %w", path, err)
+			}
+			if !re.MatchString(str) {
+				violations = append(violations, fmt.Sprintf("%s: value %q does not match pattern %q", path, str, s.Pattern))
+			}
+		}
+	}
+
+	if len(s.AnyOf) > 0 {
+		matched := false
+		for _, candidate := range s.AnyOf {
+			candidateViolations, err := candidate.validate(v, path)
+			if err != nil {
+				return nil, err
+			}
+			if len(candidateViolations) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			violations = append(violations, fmt.Sprintf("%s: does not match any of the allowed schemas", path))
+		}
+	}
+
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		for _, k := range s.Required {
+			if _, ok := tv[k]; !ok {
+				violations = append(violations, fmt.Sprintf("%s: missing required property %q", path, k))
+			}
+		}
+		keys := make([]string, 0, len(tv))
+		for k := range tv {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			propertySchema, ok := s.Properties[k]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					violations = append(violations, fmt.Sprintf("%s: unexpected property %q", path, k))
+				}
+				continue
+			}
+			propertyViolations, err := propertySchema.validate(tv[k], path+"."+k)
+			if err != nil {
+				return nil, err
+			}
+			violations = append(violations, propertyViolations...)
+		}
+	case []interface{}:
+		if s.Items == nil {
+			break
+		}
+		for i, item := range tv {
+			itemViolations, err := s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			violations = append(violations, itemViolations...)
+		}
+	}
+	return violations, nil
+}
+
+func compactJSON(raw json.RawMessage) string {
+	var b bytes.Buffer
+	if err := json.Compact(&b, raw); err != nil {
+		return string(raw)
+	}
+	return b.String()
+}
@@ -0,0 +1,149 @@
+package comparabletypes
+
+import (
+	"errors"
+	"testing"
+
+	"perri.to/expect/snapshots"
+)
+
+func TestJSONSchema_CompareTo(t *testing.T) {
+	const snapshotted = `{"menu": {
+  "id": "file",
+  "value": 3,
+  "status": "ok",
+  "popup": {
+    "menuitem": [
+      {"value": "New", "onclick": "CreateNewDoc()"},
+      {"value": "Open", "onclick": "OpenDoc()", "hotkey": "o"}
+    ]
+  }
+}}`
+	tests := []struct {
+		name         string
+		document     string
+		valueChecked []string
+		replacer     map[string]string
+		want         string
+		wantErr      bool
+	}{
+		{
+			name: "same shape different values",
+			document: `{"menu": {
+  "id": "edit",
+  "value": 7.5,
+  "status": "degraded",
+  "popup": {"menuitem": [{"value": "Cut", "onclick": "Cut()"}]}
+}}`,
+			want: "",
+		},
+		{
+			name: "different shape",
+			document: `{"menu": {
+  "id": 1,
+  "status": "ok",
+  "extra": true,
+  "popup": {"menuitem": [{"value": "Cut"}, {"value": "Copy", "onclick": "Copy()", "hotkey": 3}]}
+}}`,
+			want: `$.menu: missing required property "value"
+$.menu: unexpected property "extra"
+$.menu.id: expected string but got integer
+$.menu.popup.menuitem[0]: missing required property "onclick"
+$.menu.popup.menuitem[1].hotkey: expected string but got integer`,
+		},
+		{
+			name:         "value checked",
+			document:     `{"menu": {"id": "file", "value": 3, "status": "down", "popup": {"menuitem": []}}}`,
+			valueChecked: []string{"menu.status"},
+			want:         `$.menu.status: expected value "ok" but got "down"`,
+		},
+		{
+			name:         "value checked replaced",
+			document:     `{"menu": {"id": "file", "value": 3, "status": "down", "popup": {"menuitem": []}}}`,
+			valueChecked: []string{"menu.status"},
+			replacer:     map[string]string{"menu.status": "replaced"},
+			want:         "",
+		},
+		{
+			name:     "invalid document",
+			document: `{"menu": `,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := NewJSONSchemaFromString(snapshotted, tt.valueChecked...)
+			target := NewJSONSchemaFromString(tt.document, tt.valueChecked...)
+			if tt.replacer != nil {
+				source.Replace(tt.replacer)
+				target.Replace(tt.replacer)
+			}
+			// go through a dump and load, as a snapshot would.
+			expectation := target.Load(source.Dump())
+			got, err := expectation.CompareTo(target)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CompareTo() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("CompareTo() got = \n%s\n, want \n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONSchema_Dump(t *testing.T) {
+	j := NewJSONSchemaFromString(`{"items": [{"a": 1, "b": "x"}, {"a": null}], "ok": true}`, "ok")
+	const want = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {
+    "items": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "a": {
+            "type": [
+              "null",
+              "number"
+            ]
+          },
+          "b": {
+            "type": "string"
+          }
+        },
+        "required": [
+          "a"
+        ],
+        "additionalProperties": false
+      }
+    },
+    "ok": {
+      "type": "boolean",
+      "const": true
+    }
+  },
+  "required": [
+    "items",
+    "ok"
+  ],
+  "additionalProperties": false
+}`
+	if got := string(j.Dump()); got != want {
+		t.Errorf("Dump() got = \n%s\n, want \n%s", got, want)
+	}
+}
+
+func TestJSONSchema_DumpInvalid(t *testing.T) {
+	const invalid = `{"menu": `
+	j := NewJSONSchemaFromString(invalid)
+	if got := string(j.Dump()); got != invalid {
+		t.Errorf("Dump() got = %q, want the document as is %q", got, invalid)
+	}
+	_, err := j.Load(j.Dump()).CompareTo(NewJSONSchemaFromString(`{"menu": {}}`))
+	var invalidSource *snapshots.ErrSourceInvalid
+	if !errors.As(err, &invalidSource) {
+		t.Errorf("CompareTo() error = %v, want an invalid source", err)
+	}
+}
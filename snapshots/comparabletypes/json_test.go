@@ -131,3 +131,16 @@ func TestJSON_CompareTo(t *testing.T) {
 		})
 	}
 }
+
+func TestJSON_CompareToRendered(t *testing.T) {
+	j := NewJSONFromString(`{"value": "New"}`)
+	j.(snapshots.Rendering).SetRenderer(snapshots.Renderer{Theme: snapshots.ThemePlain})
+	got, err := j.Load(j.Dump()).CompareTo(NewJSONFromString(`{"value": "Open", "id": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n    {+\"id\": 1+},\n    \"value\": {~\"New\" => \"Open\"~}\n}"
+	if got != want {
+		t.Errorf("CompareTo() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,159 @@
+package comparabletypes
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Markers can be written by hand in string snapshots to match the parts of the result that change on each run,
+// they only match within a line.
+const (
+	// MarkerAny matches any text, including none.
+	MarkerAny = "[[ANY]]"
+	// MarkerAnyLine matches any line, it is meant to be the whole line.
+	MarkerAnyLine = "[[ANY_LINE]]"
+	// MarkerRegexPrefix starts a marker that matches a regular expression, ie: [[REGEX:\d+ms]].
+	MarkerRegexPrefix = "[[REGEX:"
+)
+
+// markerPattern returns the regular expression that a line with markers matches or nil if it has none.
+func markerPattern(line string) (*regexp.Regexp, error) {
+	if !strings.Contains(line, "[[") {
+		return nil, nil
+	}
+	var pattern strings.Builder
+	found := false
+	for {
+		start := strings.Index(line, "[[")
+		if start == -1 {
+			break
+		}
+		pattern.WriteString(regexp.QuoteMeta(line[:start]))
+		rest := line[start:]
+		switch {
+		case strings.HasPrefix(rest, MarkerAny):
+			pattern.WriteString(".*?")
+			line = rest[len(MarkerAny):]
+		case strings.HasPrefix(rest, MarkerAnyLine):
+			pattern.WriteString(".*")
+			line = rest[len(MarkerAnyLine):]
+		case strings.HasPrefix(rest, MarkerRegexPrefix):
+			end := strings.Index(rest, "]]")
+			// a regular expression ending in a character class, ie: [[REGEX:[a-z]]], closes with the last ].
+			for end != -1 && end+2 < len(rest) && rest[end+2] == ']' {
+				end++
+			}
+			if end == -1 {
+				return nil, fmt.Errorf("marker %q is not closed", rest)
+			}
+			expr := rest[len(MarkerRegexPrefix):end]
+			if _, err := regexp.Compile(expr); err != nil {
+				return nil, fmt.Errorf("invalid regular expression in marker: %w", err)
+			}
+			pattern.WriteString("(?:" + expr + ")")
+			line = rest[end+2:]
+		default:
+			// not a marker, just brackets.
+			pattern.WriteString(regexp.QuoteMeta("[["))
+			line = rest[2:]
+			continue
+		}
+		found = true
+	}
+	if !found {
+		return nil, nil
+	}
+	pattern.WriteString(regexp.QuoteMeta(line))
+	return regexp.Compile("^" + pattern.String() + "$")
+}
+
+// markerMatch is a line of the expectation with markers and the line of the result it matches, by position.
+type markerMatch struct {
+	expected, got int
+}
+
+// markerMatches returns the lines of expected with markers that match a line of got. Lines are first aligned
+// with a line diff, then the lines with markers in each changed block are matched, in order, against the lines
+// that replaced them.
+func markerMatches(dmp *diffMatchPatch, expected, got string) ([]markerMatch, error) {
+	if !strings.Contains(expected, "[[") {
+		return nil, nil
+	}
+	var matches []markerMatch
+	lines := lineDiff(dmp, expected, got)
+	expectedLine, gotLine := 0, 0
+	for i := 0; i < len(lines); {
+		switch lines[i].op {
+		case diffmatchpatch.DiffEqual:
+			expectedLine++
+			gotLine++
+			i++
+			continue
+		case diffmatchpatch.DiffInsert:
+			gotLine++
+			i++
+			continue
+		}
+		var deleted, inserted []string
+		for ; i < len(lines) && lines[i].op == diffmatchpatch.DiffDelete; i++ {
+			deleted = append(deleted, lines[i].text)
+		}
+		for ; i < len(lines) && lines[i].op == diffmatchpatch.DiffInsert; i++ {
+			inserted = append(inserted, lines[i].text)
+		}
+		next := 0
+		for j, d := range deleted {
+			pattern, err := markerPattern(strings.TrimSuffix(d, "\n"))
+			if err != nil {
+				return nil, err
+			}
+			if pattern == nil {
+				continue
+			}
+			for k := next; k < len(inserted); k++ {
+				if pattern.MatchString(strings.TrimSuffix(inserted[k], "\n")) {
+					matches = append(matches, markerMatch{expected: expectedLine + j, got: gotLine + k})
+					next = k + 1
+					break
+				}
+			}
+		}
+		expectedLine += len(deleted)
+		gotLine += len(inserted)
+	}
+	return matches, nil
+}
+
+// resolveMarkers returns expected with the lines with markers that match a line of got replaced by that line,
+// so they are not a difference.
+func resolveMarkers(dmp *diffMatchPatch, expected, got string) (string, error) {
+	matches, err := markerMatches(dmp, expected, got)
+	if err != nil || len(matches) == 0 {
+		return expected, err
+	}
+	expectedLines, gotLines := strings.SplitAfter(expected, "\n"), strings.SplitAfter(got, "\n")
+	for _, m := range matches {
+		expectedLines[m.expected] = gotLines[m.got]
+	}
+	return strings.Join(expectedLines, ""), nil
+}
+
+// preserveMarkers returns got with the lines that are matched by a line with markers of previous replaced by
+// that line, so updating a snapshot does not lose the markers.
+func preserveMarkers(previous, got string) string {
+	matches, err := markerMatches(newDiffMatchPatch(context.Background()), previous, got)
+	if err != nil || len(matches) == 0 {
+		return got
+	}
+	previousLines, gotLines := strings.SplitAfter(previous, "\n"), strings.SplitAfter(got, "\n")
+	for _, m := range matches {
+		line := gotLines[m.got]
+		// keep the line break, or lack of, of the new text.
+		gotLines[m.got] = strings.TrimSuffix(previousLines[m.expected], "\n") + line[len(strings.TrimSuffix(line, "\n")):]
+	}
+	return strings.Join(gotLines, "")
+}
@@ -0,0 +1,73 @@
+package comparabletypes
+
+import (
+	"testing"
+)
+
+const markedSnapshot = `Building project
+[[ANY_LINE]]
+Compiled 12 files in [[REGEX:\d+ms]]
+Build hash: [[ANY]]
+Done
+`
+
+func TestStringComparable_CompareToMarkers(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		got      string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "matched",
+			expected: markedSnapshot,
+			got:      "Building project\nusing go1.18\nCompiled 12 files in 431ms\nBuild hash: 1f2e3d\nDone\n",
+		},
+		{
+			name:     "regular expression not matched",
+			expected: markedSnapshot,
+			got:      "Building project\nusing go1.18\nCompiled 12 files in 1.2s\nBuild hash: 1f2e3d\nDone\n",
+			want:     "Building project\nusing go1.18\nCompiled 12 files in {-[[REGEX:\\d+m-}{+1.2+}s{-]]-}\nBuild hash: 1f2e3d\nDone\n",
+		},
+		{
+			name:     "other lines still compared",
+			expected: markedSnapshot,
+			got:      "Building project\nusing go1.18\nCompiled 12 files in 431ms\nBuild hash: 1f2e3d\nFailed\n",
+			want:     "Building project\nusing go1.18\nCompiled 12 files in 431ms\nBuild hash: 1f2e3d\n{-Don-}{+Fail+}e{+d+}\n",
+		},
+		{
+			name:     "character class",
+			expected: "id: [[REGEX:[a-f0-9]]]\n",
+			got:      "id: c\n",
+		},
+		{
+			name:     "invalid regular expression",
+			expected: "took [[REGEX:(\\d+ms]]\n",
+			got:      "took 1ms\n",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &StringComparable{string: tt.expected, contextSize: -1}
+			got, err := s.CompareTo(NewStringComparable(tt.got))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CompareTo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CompareTo() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringComparable_Merge(t *testing.T) {
+	got := "Building project\nusing go1.20\nCompiled 14 files in 512ms\nBuild hash: 9a8b7c\nWarnings: 1\nDone\n"
+	// the count of files changed, so that line does not match its marker anymore and is regenerated.
+	want := "Building project\n[[ANY_LINE]]\nCompiled 14 files in 512ms\nBuild hash: [[ANY]]\nWarnings: 1\nDone\n"
+	merged := string(NewStringComparable(got).(*StringComparable).Merge([]byte(markedSnapshot)))
+	if merged != want {
+		t.Errorf("Merge() = %q, want %q", merged, want)
+	}
+}
@@ -0,0 +1,360 @@
+package comparabletypes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// markupNode is the tree both XML and HTML documents are parsed into for comparison, it only keeps what we
+// consider significant: elements, their attributes and non blank text.
+type markupNode struct {
+	// name is the element name, it is empty for text nodes and the document node.
+	name string
+	// attrs are kept sorted by name, so their order in the source is irrelevant.
+	attrs    []markupAttr
+	text     string
+	isText   bool
+	children []*markupNode
+	parent   *markupNode
+}
+
+type markupAttr struct {
+	name  string
+	value string
+}
+
+func newMarkupDocument() *markupNode {
+	return &markupNode{}
+}
+
+func (n *markupNode) appendChild(child *markupNode) {
+	child.parent = n
+	n.children = append(n.children, child)
+}
+
+// appendText adds text to n, merging it with a previous text node if there is one.
+func (n *markupNode) appendText(text string) {
+	if text == "" {
+		return
+	}
+	if l := len(n.children); l > 0 && n.children[l-1].isText {
+		n.children[l-1].text = joinMarkupText(n.children[l-1].text, text)
+		return
+	}
+	n.appendChild(&markupNode{isText: true, text: text})
+}
+
+func joinMarkupText(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + " " + b
+}
+
+func (n *markupNode) sortAttrs() {
+	sort.SliceStable(n.attrs, func(i, j int) bool { return n.attrs[i].name < n.attrs[j].name })
+}
+
+func (n *markupNode) attr(name string) (string, bool) {
+	for _, a := range n.attrs {
+		if a.name == name {
+			return a.value, true
+		}
+	}
+	return "", false
+}
+
+// setAttr replaces the value of an existing attribute, it returns false if there is no such attribute.
+func (n *markupNode) setAttr(name, value string) bool {
+	for i, a := range n.attrs {
+		if a.name == name {
+			n.attrs[i].value = value
+			return true
+		}
+	}
+	return false
+}
+
+// setText replaces all the contents of n with a text node.
+func (n *markupNode) setText(text string) {
+	if n.isText {
+		n.text = text
+		return
+	}
+	n.children = nil
+	n.appendChild(&markupNode{isText: true, text: text})
+}
+
+func (n *markupNode) elements() []*markupNode {
+	elements := make([]*markupNode, 0, len(n.children))
+	for _, c := range n.children {
+		if !c.isText {
+			elements = append(elements, c)
+		}
+	}
+	return elements
+}
+
+// key is what we use to align children of two nodes.
+func (n *markupNode) key() string {
+	if n.isText {
+		return "#text"
+	}
+	return n.name
+}
+
+// path returns an XPath like location for n, positions are only added when needed to tell siblings apart.
+func (n *markupNode) path() string {
+	if n.parent == nil {
+		return ""
+	}
+	step := n.name
+	if n.isText {
+		step = "text()"
+	}
+	same, position := 0, 0
+	for _, sibling := range n.parent.children {
+		if sibling.key() == n.key() {
+			same++
+		}
+		if sibling == n {
+			position = same
+		}
+	}
+	if same > 1 {
+		step = fmt.Sprintf("%s[%d]", step, position)
+	}
+	return n.parent.path() + "/" + step
+}
+
+// compareMarkup reports the element level differences between the expected and got trees.
+func compareMarkup(expected, got *markupNode) string {
+	var differences []string
+	compareMarkupNodes(expected, got, &differences)
+	if len(differences) == 0 {
+		return ""
+	}
+	return strings.Join(differences, "\n") + "\n"
+}
+
+func compareMarkupNodes(expected, got *markupNode, differences *[]string) {
+	location := got.path()
+	if location == "" {
+		location = "/"
+	}
+	if expected.isText {
+		if expected.text != got.text {
+			*differences = append(*differences, fmt.Sprintf("%s: expected text %q but got %q", location, expected.text, got.text))
+		}
+		return
+	}
+
+	for _, a := range expected.attrs {
+		v, ok := got.attr(a.name)
+		switch {
+		case !ok:
+			*differences = append(*differences, fmt.Sprintf("%s: attribute %s is expected but not present", location, a.name))
+		case v != a.value:
+			*differences = append(*differences, fmt.Sprintf("%s: attribute %s has value %q but we expected %q", location, a.name, v, a.value))
+		}
+	}
+	for _, a := range got.attrs {
+		if _, ok := expected.attr(a.name); !ok {
+			*differences = append(*differences, fmt.Sprintf("%s: attribute %s is not expected but present, with value %q", location, a.name, a.value))
+		}
+	}
+
+	for _, pair := range alignMarkupChildren(expected.children, got.children) {
+		switch {
+		case pair.got == nil:
+			*differences = append(*differences, fmt.Sprintf("%s: %s is expected but not present", pair.expected.path(), pair.expected.describe()))
+		case pair.expected == nil:
+			*differences = append(*differences, fmt.Sprintf("%s: %s is not expected but present", pair.got.path(), pair.got.describe()))
+		default:
+			compareMarkupNodes(pair.expected, pair.got, differences)
+		}
+	}
+}
+
+func (n *markupNode) describe() string {
+	if n.isText {
+		return fmt.Sprintf("text %q", n.text)
+	}
+	return fmt.Sprintf("element <%s>", n.name)
+}
+
+type markupPair struct {
+	expected *markupNode
+	got      *markupNode
+}
+
+// alignMarkupChildren pairs the children of two nodes using the longest common subsequence of their keys, so
+// a single inserted element is reported as such instead of shifting all its siblings.
+func alignMarkupChildren(expected, got []*markupNode) []markupPair {
+	lcs := make([][]int, len(expected)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(got)+1)
+	}
+	for i := len(expected) - 1; i >= 0; i-- {
+		for j := len(got) - 1; j >= 0; j-- {
+			if expected[i].key() == got[j].key() {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var pairs []markupPair
+	i, j := 0, 0
+	for i < len(expected) && j < len(got) {
+		switch {
+		case expected[i].key() == got[j].key():
+			pairs = append(pairs, markupPair{expected: expected[i], got: got[j]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			pairs = append(pairs, markupPair{expected: expected[i]})
+			i++
+		default:
+			pairs = append(pairs, markupPair{got: got[j]})
+			j++
+		}
+	}
+	for ; i < len(expected); i++ {
+		pairs = append(pairs, markupPair{expected: expected[i]})
+	}
+	for ; j < len(got); j++ {
+		pairs = append(pairs, markupPair{got: got[j]})
+	}
+	return pairs
+}
+
+// markupRenderer holds what differs between rendering XML and HTML.
+type markupRenderer struct {
+	escapeText func(string) string
+	escapeAttr func(string) string
+	// selfClosing returns the way to render an element without children, ie: `<br>` or `<empty/>`
+	selfClosing func(n *markupNode) string
+	// raw tells if the text of the element should be written as is.
+	raw func(n *markupNode) bool
+	// preserve tells if whitespace is significant in the element, its contents are then written inline, without
+	// adding indentation or line breaks.
+	preserve func(n *markupNode) bool
+}
+
+// render writes the tree in a normalized form, one element per line and indented with two spaces.
+func (r *markupRenderer) render(n *markupNode) string {
+	var b strings.Builder
+	for _, c := range n.children {
+		r.renderNode(&b, c, 0)
+	}
+	return b.String()
+}
+
+func (r *markupRenderer) renderNode(b *strings.Builder, n *markupNode, depth int) {
+	indent := strings.Repeat("  ", depth)
+	if n.isText {
+		b.WriteString(indent)
+		b.WriteString(r.text(n.parent, n.text))
+		b.WriteString("\n")
+		return
+	}
+	switch {
+	case len(n.children) == 0:
+		b.WriteString(indent)
+		b.WriteString(r.openTag(n))
+		b.WriteString(r.selfClosing(n))
+		b.WriteString("\n")
+	case len(n.children) == 1 && n.children[0].isText, r.preserve(n):
+		b.WriteString(indent)
+		r.renderInline(b, n)
+		b.WriteString("\n")
+	default:
+		b.WriteString(indent)
+		b.WriteString(r.openTag(n))
+		b.WriteString(">\n")
+		for _, c := range n.children {
+			r.renderNode(b, c, depth+1)
+		}
+		b.WriteString(indent)
+		b.WriteString(fmt.Sprintf("</%s>\n", n.name))
+	}
+}
+
+// renderInline writes n and its children as they are, with no indentation or line breaks.
+func (r *markupRenderer) renderInline(b *strings.Builder, n *markupNode) {
+	if n.isText {
+		b.WriteString(r.text(n.parent, n.text))
+		return
+	}
+	b.WriteString(r.openTag(n))
+	if len(n.children) == 0 {
+		b.WriteString(r.selfClosing(n))
+		return
+	}
+	b.WriteString(">")
+	if first := n.children[0]; r.preserve(n) && first.isText && strings.HasPrefix(first.text, "\n") {
+		// a line break right after the start tag is dropped by parsers, so one that is part of the text needs
+		// another before it.
+		b.WriteString("\n")
+	}
+	for _, c := range n.children {
+		r.renderInline(b, c)
+	}
+	b.WriteString(fmt.Sprintf("</%s>", n.name))
+}
+
+func (r *markupRenderer) openTag(n *markupNode) string {
+	var open strings.Builder
+	open.WriteString("<")
+	open.WriteString(n.name)
+	for _, a := range n.attrs {
+		open.WriteString(fmt.Sprintf(" %s=\"%s\"", a.name, r.escapeAttr(a.value)))
+	}
+	return open.String()
+}
+
+func (r *markupRenderer) text(parent *markupNode, text string) string {
+	if parent != nil && r.raw(parent) {
+		return text
+	}
+	return r.escapeText(text)
+}
+
+// collapseWhitespace trims s and turns every run of whitespace into a single space.
+func collapseWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// applyMarkupReplacers sets the text or attribute selected by each key of rs to the corresponding value, the
+// selection is done by selectFn which understands whatever selector syntax the document type uses.
+func applyMarkupReplacers(doc *markupNode, rs map[string]string,
+	selectFn func(doc *markupNode, selector string) ([]*markupNode, string, error)) error {
+	for selector, value := range rs {
+		nodes, attr, err := selectFn(doc, selector)
+		if err != nil {
+			return fmt.Errorf("invalid replacer selector %q: %w", selector, err)
+		}
+		for _, n := range nodes {
+			if attr != "" {
+				n.setAttr(attr, value)
+				continue
+			}
+			n.setText(value)
+		}
+	}
+	return nil
+}
+
+// walkMarkup calls fn for n and every element below it, in document order.
+func walkMarkup(n *markupNode, fn func(*markupNode)) {
+	fn(n)
+	for _, c := range n.children {
+		if !c.isText {
+			walkMarkup(c, fn)
+		}
+	}
+}
@@ -0,0 +1,465 @@
+package comparabletypes
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nsf/jsondiff"
+	"github.com/tidwall/sjson"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"perri.to/expect/snapshots"
+)
+
+var _ snapshots.Comparable = (*Proto)(nil)
+var _ snapshots.Validator = (*Proto)(nil)
+
+// ProtoFormat is the representation used to store a Proto snapshot.
+type ProtoFormat string
+
+const (
+	// ProtoJSON stores messages as protojson.
+	ProtoJSON ProtoFormat = "json"
+	// ProtoText stores messages as prototext.
+	ProtoText ProtoFormat = "text"
+)
+
+// Proto holds a protocol buffers message, which is compared semantically through its protojson form: unset fields
+// are treated as their default values and unknown fields, which protojson and prototext can not represent, are
+// compared and stored apart, see unknownKey, regardless of their order.
+type Proto struct {
+	withRenderer
+	message proto.Message
+	format  ProtoFormat
+	// replacers are applied to the JSON form of the message, using the proto field names.
+	replacers map[string]string
+	// loadErr holds the error of parsing a snapshot, or of a nil message, if any, it is reported when comparing.
+	loadErr error
+}
+
+// errNilMessage is the loadErr of a Proto without a message.
+var errNilMessage = errors.New("nil proto message")
+
+// NewProtoComparable constructs a Proto comparable from a message which will be stored in the passed format.
+func NewProtoComparable(m proto.Message, format ProtoFormat) *Proto {
+	p := &Proto{message: m, format: format}
+	if m == nil {
+		p.loadErr = errNilMessage
+	}
+	return p
+}
+
+func (p *Proto) Subtypes() bool {
+	return false
+}
+
+func (p *Proto) ReplaceSubtypes(_ map[snapshots.Kind]map[string]string) {
+	return
+}
+
+// canonicalJSON returns the protojson form of the message with every field present, so an unset field and one
+// set to its default value are the same, its unknown fields and the replacers applied.
+func (p *Proto) canonicalJSON() (json.RawMessage, error) {
+	m, err := protojson.MarshalOptions{EmitUnpopulated: true, UseProtoNames: true}.Marshal(p.message)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling %s: %w", p.messageName(), err)
+	}
+	if m, err = p.withUnknownFields(m); err != nil {
+		return nil, err
+	}
+	for k, v := range p.replacers {
+		m, err = sjson.SetBytes(m, k, v)
+		if err != nil {
+			return nil, fmt.Errorf("replacing %s: %w", k, err)
+		}
+	}
+	// protojson output is purposely unstable, compacting and indenting it makes it deterministic.
+	var compact, indented bytes.Buffer
+	if err := json.Compact(&compact, m); err != nil {
+		return nil, fmt.Errorf("compacting json of %s: %w", p.messageName(), err)
+	}
+	if err := json.Indent(&indented, compact.Bytes(), "", "  "); err != nil {
+		return nil, fmt.Errorf("indenting json of %s: %w", p.messageName(), err)
+	}
+	return indented.Bytes(), nil
+}
+
+func (p *Proto) messageName() string {
+	if p.message == nil {
+		return "<nil>"
+	}
+	return string(p.message.ProtoReflect().Descriptor().FullName())
+}
+
+func (p *Proto) CompareTo(c snapshots.Comparable) (string, error) {
+	other, isProto := c.(*Proto)
+	if !isProto {
+		return "", snapshots.CantCompare(fmt.Sprintf("%T", p), fmt.Sprintf("%T", c))
+	}
+	switch {
+	case p.loadErr != nil && other.loadErr != nil:
+		return "", snapshots.BothPartsInvalid(fmt.Sprintf("%T", p), fmt.Sprintf("%T", c), p.Kind())
+	case p.loadErr != nil:
+		return "", fmt.Errorf("%w: %v", snapshots.InvalidSource(fmt.Sprintf("%T", p), p.Kind()), p.loadErr)
+	case other.loadErr != nil:
+		return "", fmt.Errorf("%w: %v", snapshots.InvalidTarget(fmt.Sprintf("%T", c), c.Kind()), other.loadErr)
+	}
+	if p.messageName() != other.messageName() {
+		return fmt.Sprintf("Message: expected %s but got %s\n", p.messageName(), other.messageName()), nil
+	}
+	expected, err := p.canonicalJSON()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", snapshots.InvalidSource(fmt.Sprintf("%T", p), p.Kind()), err)
+	}
+	got, err := other.canonicalJSON()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", snapshots.InvalidTarget(fmt.Sprintf("%T", c), c.Kind()), err)
+	}
+	opts := jsondiffOptions(p.currentRenderer().Theme)
+	difference, explanation := jsondiff.Compare(expected, got, &opts)
+	if difference == jsondiff.FullMatch {
+		return "", nil
+	}
+	return explanation, nil
+}
+
+func (p *Proto) String() string {
+	return string(p.Dump())
+}
+
+const KindProto snapshots.Kind = "proto"
+
+func (p *Proto) Kind() snapshots.Kind {
+	return KindProto
+}
+
+// Dump returns the message, with the replacements applied, in a deterministic form of the chosen format. It
+// returns nothing for messages that can not be marshaled, see Validate.
+func (p *Proto) Dump() []byte {
+	dump, err := p.dump()
+	if err != nil {
+		return nil
+	}
+	return dump
+}
+
+// Validate returns why the message can not be dumped, if it can't.
+func (p *Proto) Validate() error {
+	_, err := p.dump()
+	return err
+}
+
+func (p *Proto) dump() ([]byte, error) {
+	if p.loadErr != nil {
+		return nil, p.loadErr
+	}
+	canonical, err := p.canonicalJSON()
+	if err != nil {
+		return nil, err
+	}
+	if p.format != ProtoText {
+		return canonical, nil
+	}
+	m := p.message
+	if len(p.replacers) > 0 {
+		replaced := &Proto{message: p.message.ProtoReflect().New().Interface()}
+		// a replacement might not be a valid value for the field type, we can only dump the original then.
+		if err := replaced.unmarshalJSON(canonical); err == nil {
+			m = replaced.message
+		}
+	}
+	text, err := prototext.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling %s: %w", p.messageName(), err)
+	}
+	text = normalizePrototext(text)
+	// prototext can not represent unknown fields, they go in comments, which it skips.
+	for _, u := range collectUnknownFields(m.ProtoReflect()) {
+		line, err := json.Marshal(u)
+		if err != nil {
+			return nil, fmt.Errorf("storing the unknown fields of %s: %w", p.messageName(), err)
+		}
+		if len(text) > 0 && text[len(text)-1] != '\n' {
+			text = append(text, '\n')
+		}
+		text = append(append(append(text, unknownComment...), line...), '\n')
+	}
+	return text, nil
+}
+
+// normalizePrototext removes the random extra space prototext adds after field names to make its output
+// unstable.
+func normalizePrototext(text []byte) []byte {
+	lines := strings.Split(string(text), "\n")
+	for i, line := range lines {
+		if p := strings.Index(line, ":  "); p != -1 && !strings.ContainsAny(line[:p], `"'`) {
+			lines[i] = line[:p+1] + " " + strings.TrimLeft(line[p+1:], " ")
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// Load parses a snapshot into a new message of the same type as ours.
+func (p *Proto) Load(raw []byte) snapshots.Comparable {
+	if p.message == nil {
+		return &Proto{withRenderer: p.withRenderer, format: p.format, loadErr: errNilMessage}
+	}
+	loaded := &Proto{withRenderer: p.withRenderer, message: p.message.ProtoReflect().New().Interface(), format: p.format}
+	if p.format == ProtoText {
+		loaded.loadErr = loaded.unmarshalText(raw)
+	} else {
+		loaded.loadErr = loaded.unmarshalJSON(raw)
+	}
+	return loaded
+}
+
+// unknownKey is the key, in the JSON form of a message, of its unknown fields and those of its submessages.
+const unknownKey = "@unknown"
+
+// unknownComment starts the comments holding unknown fields in the text form of a message.
+const unknownComment = "# " + unknownKey + " "
+
+// unknownFields are the unknown fields of a message, or of the submessage at Path, as the base64 of the wire
+// encoding of each field, sorted so their order does not matter.
+type unknownFields struct {
+	Path   []string `json:"path"`
+	Fields []string `json:"fields"`
+}
+
+// collectUnknownFields returns the unknown fields of m and its submessages, sorted by path. Map entries are
+// in the path by their key and list items by their index.
+func collectUnknownFields(m protoreflect.Message) []unknownFields {
+	var unknown []unknownFields
+	var collect func(m protoreflect.Message, path []string)
+	collect = func(m protoreflect.Message, path []string) {
+		if raw := m.GetUnknown(); len(raw) > 0 {
+			unknown = append(unknown, unknownFields{Path: append([]string{}, path...), Fields: splitUnknownFields(raw)})
+		}
+		m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+			fieldPath := append(append([]string{}, path...), string(fd.Name()))
+			switch {
+			case fd.IsMap():
+				if fd.MapValue().Message() != nil {
+					v.Map().Range(func(k protoreflect.MapKey, item protoreflect.Value) bool {
+						collect(item.Message(), append(fieldPath, k.String()))
+						return true
+					})
+				}
+			case fd.IsList():
+				if fd.Message() != nil {
+					for i := 0; i < v.List().Len(); i++ {
+						collect(v.List().Get(i).Message(), append(fieldPath, strconv.Itoa(i)))
+					}
+				}
+			case fd.Message() != nil:
+				collect(v.Message(), fieldPath)
+			}
+			return true
+		})
+	}
+	collect(m, nil)
+	sort.Slice(unknown, func(i, j int) bool {
+		return strings.Join(unknown[i].Path, "\x00") < strings.Join(unknown[j].Path, "\x00")
+	})
+	return unknown
+}
+
+// splitUnknownFields returns the fields in raw encoded as unknownFields holds them.
+func splitUnknownFields(raw []byte) []string {
+	var fields []string
+	for len(raw) > 0 {
+		_, _, n := protowire.ConsumeField(raw)
+		if n < 0 {
+			// malformed, it is kept whole.
+			n = len(raw)
+		}
+		fields = append(fields, base64.StdEncoding.EncodeToString(raw[:n]))
+		raw = raw[n:]
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// withUnknownFields adds the unknown fields of the message to m, its JSON form, under unknownKey.
+func (p *Proto) withUnknownFields(m []byte) ([]byte, error) {
+	unknown := collectUnknownFields(p.message.ProtoReflect())
+	if len(unknown) == 0 {
+		return m, nil
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(m, &object); err != nil {
+		return nil, fmt.Errorf("storing the unknown fields of %s: %w", p.messageName(), err)
+	}
+	var err error
+	if object[unknownKey], err = json.Marshal(unknown); err != nil {
+		return nil, fmt.Errorf("storing the unknown fields of %s: %w", p.messageName(), err)
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(object); err != nil {
+		return nil, fmt.Errorf("storing the unknown fields of %s: %w", p.messageName(), err)
+	}
+	return b.Bytes(), nil
+}
+
+// unmarshalJSON parses raw, the JSON form of a message with its unknown fields, into our message.
+func (p *Proto) unmarshalJSON(raw []byte) error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err != nil || object[unknownKey] == nil {
+		// not an object, ie: a well known type, or without unknown fields.
+		return protojson.Unmarshal(raw, p.message)
+	}
+	var unknown []unknownFields
+	if err := json.Unmarshal(object[unknownKey], &unknown); err != nil {
+		return fmt.Errorf("decoding unknown fields: %w", err)
+	}
+	delete(object, unknownKey)
+	known, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	if err := protojson.Unmarshal(known, p.message); err != nil {
+		return err
+	}
+	return setUnknownFields(p.message.ProtoReflect(), unknown)
+}
+
+// unmarshalText parses raw, the text form of a message with its unknown fields in comments, into our message.
+func (p *Proto) unmarshalText(raw []byte) error {
+	if err := prototext.Unmarshal(raw, p.message); err != nil {
+		return err
+	}
+	var unknown []unknownFields
+	for _, line := range strings.Split(string(raw), "\n") {
+		if !strings.HasPrefix(line, unknownComment) {
+			continue
+		}
+		var u unknownFields
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, unknownComment)), &u); err != nil {
+			return fmt.Errorf("decoding unknown fields: %w", err)
+		}
+		unknown = append(unknown, u)
+	}
+	return setUnknownFields(p.message.ProtoReflect(), unknown)
+}
+
+// setUnknownFields sets the unknown fields of m and its submessages.
+func setUnknownFields(m protoreflect.Message, unknown []unknownFields) error {
+	for _, u := range unknown {
+		target, err := submessage(m, u.Path)
+		if err != nil {
+			return fmt.Errorf("setting unknown fields of %s: %w", strings.Join(u.Path, "."), err)
+		}
+		var raw []byte
+		for _, f := range u.Fields {
+			b, err := base64.StdEncoding.DecodeString(f)
+			if err != nil {
+				return fmt.Errorf("decoding unknown field of %s: %w", strings.Join(u.Path, "."), err)
+			}
+			raw = append(raw, b...)
+		}
+		target.SetUnknown(raw)
+	}
+	return nil
+}
+
+// submessage returns the message at path within m, as collectUnknownFields writes it.
+func submessage(m protoreflect.Message, path []string) (protoreflect.Message, error) {
+	for len(path) > 0 {
+		fd := m.Descriptor().Fields().ByName(protoreflect.Name(path[0]))
+		if fd == nil || fd.Message() == nil {
+			return nil, fmt.Errorf("%s is not a message field of %s", path[0], m.Descriptor().FullName())
+		}
+		if !fd.IsMap() && !fd.IsList() {
+			m, path = m.Mutable(fd).Message(), path[1:]
+			continue
+		}
+		if len(path) < 2 {
+			return nil, fmt.Errorf("%s is missing its key or index", path[0])
+		}
+		if fd.IsList() {
+			l := m.Mutable(fd).List()
+			i, err := strconv.Atoi(path[1])
+			if err != nil || i < 0 || i >= l.Len() {
+				return nil, fmt.Errorf("%s has no item %s", path[0], path[1])
+			}
+			m, path = l.Get(i).Message(), path[2:]
+			continue
+		}
+		if fd.MapValue().Message() == nil {
+			return nil, fmt.Errorf("%s is not a map of messages", path[0])
+		}
+		key, err := protoMapKey(fd.MapKey(), path[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s key %s: %w", path[0], path[1], err)
+		}
+		m, path = m.Mutable(fd).Map().Mutable(key).Message(), path[2:]
+	}
+	return m, nil
+}
+
+// protoMapKey parses s, as protoreflect.MapKey.String prints it, into a key of the kind of fd.
+func protoMapKey(fd protoreflect.FieldDescriptor, s string) (protoreflect.MapKey, error) {
+	var v protoreflect.Value
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		v = protoreflect.ValueOfString(s)
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return protoreflect.MapKey{}, err
+		}
+		v = protoreflect.ValueOfBool(b)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		i, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return protoreflect.MapKey{}, err
+		}
+		v = protoreflect.ValueOfInt32(int32(i))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return protoreflect.MapKey{}, err
+		}
+		v = protoreflect.ValueOfInt64(i)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		u, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return protoreflect.MapKey{}, err
+		}
+		v = protoreflect.ValueOfUint32(uint32(u))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		u, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return protoreflect.MapKey{}, err
+		}
+		v = protoreflect.ValueOfUint64(u)
+	default:
+		return protoreflect.MapKey{}, fmt.Errorf("unsupported map key kind %s", fd.Kind())
+	}
+	return v.MapKey(), nil
+}
+
+// Replace takes field paths, as the JSON comparable does but with the proto field names, and sets them to the
+// passed values.
+func (p *Proto) Replace(rs map[string]string) {
+	p.replacers = rs
+}
+
+func (p *Proto) Extension() string {
+	if p.format == ProtoText {
+		return "textproto"
+	}
+	return "pb.json"
+}
@@ -0,0 +1,168 @@
+package comparabletypes
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/sourcecontextpb"
+	"google.golang.org/protobuf/types/known/typepb"
+
+	"perri.to/expect/snapshots"
+)
+
+func sampleType(name, file string) *typepb.Type {
+	return &typepb.Type{
+		Name: name,
+		Fields: []*typepb.Field{
+			{Kind: typepb.Field_TYPE_STRING, Number: 1, Name: "id"},
+			{Kind: typepb.Field_TYPE_INT64, Number: 2, Name: "created", JsonName: "created"},
+		},
+		SourceContext: &sourcecontextpb.SourceContext{FileName: file},
+	}
+}
+
+func TestProto_CompareTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		other    *typepb.Type
+		replacer map[string]string
+		want     string
+	}{
+		{
+			name:  "equal",
+			other: sampleType("Order", "order.proto"),
+		},
+		{
+			name: "explicit defaults",
+			other: func() *typepb.Type {
+				tp := sampleType("Order", "order.proto")
+				tp.Syntax = typepb.Syntax_SYNTAX_PROTO2
+				tp.Oneofs = []string{}
+				return tp
+			}(),
+		},
+		{
+			name:     "replaced",
+			other:    sampleType("Order", "generated/order.proto"),
+			replacer: map[string]string{"source_context.file_name": "some.proto"},
+		},
+		{
+			name:  "different",
+			other: sampleType("Invoice", "order.proto"),
+			want:  "\"name\": \x1b[0;33m\"Order\" => \"Invoice\"\x1b[0m,",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, format := range []ProtoFormat{ProtoJSON, ProtoText} {
+				p := NewProtoComparable(sampleType("Order", "order.proto"), format)
+				other := NewProtoComparable(tt.other, format)
+				if tt.replacer != nil {
+					p.Replace(tt.replacer)
+					other.Replace(tt.replacer)
+				}
+				// go through a dump and load, as a snapshot would.
+				expectation := other.Load(p.Dump())
+				if tt.replacer != nil {
+					expectation.Replace(tt.replacer)
+				}
+				got, err := expectation.CompareTo(other)
+				if err != nil {
+					t.Fatalf("CompareTo(%s) error = %v", format, err)
+				}
+				if !containsLine(got, tt.want) {
+					t.Errorf("CompareTo(%s) got = \n%q\n, want it to contain %q", format, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// containsLine tells if s has line, as indented by jsondiff, or if s is empty when line is.
+func containsLine(s, line string) bool {
+	if line == "" {
+		return s == ""
+	}
+	for _, l := range strings.Split(s, "\n") {
+		if l == "    "+line {
+			return true
+		}
+	}
+	return false
+}
+
+func TestProto_Dump(t *testing.T) {
+	const want = `name: "Order"
+fields: {
+  kind: TYPE_STRING
+  number: 1
+  name: "id"
+}
+fields: {
+  kind: TYPE_INT64
+  number: 2
+  name: "created"
+  json_name: "created"
+}
+source_context: {
+  file_name: "order.proto"
+}
+`
+	got := string(NewProtoComparable(sampleType("Order", "order.proto"), ProtoText).Dump())
+	if got != want {
+		t.Errorf("Dump() got = \n%s\n, want \n%s", got, want)
+	}
+}
+
+func TestProto_UnknownFields(t *testing.T) {
+	// fields 98 and 99 are not part of the messages, they are kept as unknown fields.
+	field98, field99 := []byte{0xd0, 0x06, 0x02}, []byte{0xd8, 0x06, 0x01}
+	withUnknown := func(unknown ...[]byte) *typepb.Type {
+		m := sampleType("Order", "order.proto")
+		m.ProtoReflect().SetUnknown(bytes.Join(unknown, nil))
+		m.Fields[1].ProtoReflect().SetUnknown(field99)
+		return m
+	}
+	tests := []struct {
+		name     string
+		got      *typepb.Type
+		wantDiff bool
+	}{
+		{name: "reordered", got: withUnknown(field99, field98)},
+		{name: "missing", got: withUnknown(field98), wantDiff: true},
+		{name: "none", got: sampleType("Order", "order.proto"), wantDiff: true},
+	}
+	for _, format := range []ProtoFormat{ProtoJSON, ProtoText} {
+		for _, tt := range tests {
+			t.Run(string(format)+"_"+tt.name, func(t *testing.T) {
+				p := NewProtoComparable(withUnknown(field98, field99), format)
+				// the unknown fields survive being stored.
+				got, err := p.Load(p.Dump()).CompareTo(NewProtoComparable(tt.got, format))
+				if err != nil {
+					t.Fatalf("CompareTo() error = %v", err)
+				}
+				if (got != "") != tt.wantDiff {
+					t.Errorf("CompareTo() got = \n%s\n, want a difference %v", got, tt.wantDiff)
+				}
+			})
+		}
+	}
+}
+
+func TestProto_NilMessage(t *testing.T) {
+	p := NewProtoComparable(nil, ProtoText)
+	if got := p.Dump(); len(got) != 0 {
+		t.Errorf("Dump() got = %q, want nothing", got)
+	}
+	if err := p.Validate(); err == nil {
+		t.Error("Validate() expected an error for a nil message")
+	}
+	loaded := p.Load([]byte(`name: "Order"`))
+	_, err := NewProtoComparable(sampleType("Order", "order.proto"), ProtoText).CompareTo(loaded)
+	var invalid *snapshots.ErrTargetInvalid
+	if !errors.As(err, &invalid) {
+		t.Errorf("CompareTo() error = %v, want an invalid target", err)
+	}
+}
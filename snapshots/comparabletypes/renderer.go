@@ -0,0 +1,51 @@
+package comparabletypes
+
+import (
+	"github.com/nsf/jsondiff"
+
+	"perri.to/expect/snapshots"
+)
+
+// withRenderer is embedded by the comparables that print their differences through a snapshots.Renderer, it
+// implements snapshots.Rendering.
+type withRenderer struct {
+	renderer *snapshots.Renderer
+}
+
+// SetRenderer sets how the differences are printed, snapshots.DefaultRenderer is used otherwise.
+func (w *withRenderer) SetRenderer(r snapshots.Renderer) {
+	w.renderer = &r
+}
+
+func (w *withRenderer) currentRenderer() snapshots.Renderer {
+	if w.renderer == nil {
+		return snapshots.DefaultRenderer
+	}
+	return *w.renderer
+}
+
+// escape returns text, a difference printed without styles, escaped as the theme needs it.
+func (w *withRenderer) escape(text string) string {
+	return w.currentRenderer().Theme.EscapeText(text)
+}
+
+// passRenderer sets our renderer, if one was set, in c.
+func (w *withRenderer) passRenderer(c snapshots.Comparable) {
+	if rendering, ok := c.(snapshots.Rendering); ok && w.renderer != nil {
+		rendering.SetRenderer(*w.renderer)
+	}
+}
+
+// jsondiffOptions returns the jsondiff options that print the differences in the passed theme.
+func jsondiffOptions(t snapshots.Theme) jsondiff.Options {
+	tag := func(s snapshots.Style) jsondiff.Tag {
+		return jsondiff.Tag{Begin: t.Styles[s].Begin, End: t.Styles[s].End}
+	}
+	opts := jsondiff.DefaultConsoleOptions()
+	opts.Added = tag(snapshots.StyleAdded)
+	opts.Removed = tag(snapshots.StyleRemoved)
+	opts.Changed = tag(snapshots.StyleChanged)
+	opts.Skipped = tag(snapshots.StyleSkipped)
+	opts.ChangedSeparator = t.EscapeText(opts.ChangedSeparator)
+	return opts
+}
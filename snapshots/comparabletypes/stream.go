@@ -0,0 +1,269 @@
+package comparabletypes
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"perri.to/expect/snapshots"
+)
+
+var (
+	_ snapshots.Comparable      = (*Stream)(nil)
+	_ snapshots.StreamLoader    = (*Stream)(nil)
+	_ snapshots.StreamDumper    = (*Stream)(nil)
+	_ snapshots.ContextComparer = (*Stream)(nil)
+)
+
+// DefaultMaxDifferences is how many differing lines a Stream reports before it stops comparing.
+const DefaultMaxDifferences = 10
+
+// Stream holds text too large to be kept in memory, ie: protocol dumps, it is spooled to a temporary file and
+// compared line by line, position against position, until a number of differences is found. Streams must be
+// closed to remove the temporary file.
+type Stream struct {
+	path           string
+	size           int64
+	maxDifferences int
+	replacers      map[string]string
+	// loadErr holds the error of spooling a snapshot, if any, it is reported when comparing.
+	loadErr error
+}
+
+// NewStreamComparable constructs a Stream comparable by spooling r to a temporary file.
+func NewStreamComparable(r io.Reader) (*Stream, error) {
+	s := &Stream{maxDifferences: DefaultMaxDifferences}
+	if err := s.spool(r); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// spool copies r to a temporary file, which is removed if the copy fails.
+func (s *Stream) spool(r io.Reader) error {
+	fd, err := os.CreateTemp("", "expect-stream-*")
+	if err != nil {
+		return fmt.Errorf("creating stream spool file: %w", err)
+	}
+	size, err := io.Copy(fd, r)
+	if err != nil {
+		fd.Close()
+		os.Remove(fd.Name())
+		return fmt.Errorf("spooling stream: %w", err)
+	}
+	if err := fd.Close(); err != nil {
+		os.Remove(fd.Name())
+		return fmt.Errorf("closing stream spool file: %w", err)
+	}
+	s.path, s.size = fd.Name(), size
+	return nil
+}
+
+// SetMaxDifferences sets how many differing lines are reported before the comparison stops.
+func (s *Stream) SetMaxDifferences(n int) {
+	s.maxDifferences = n
+}
+
+// Close removes the temporary file holding the stream.
+func (s *Stream) Close() error {
+	if s.path == "" {
+		return nil
+	}
+	return os.Remove(s.path)
+}
+
+func (s *Stream) Subtypes() bool {
+	return false
+}
+
+func (s *Stream) ReplaceSubtypes(_ map[snapshots.Kind]map[string]string) {
+	return
+}
+
+// lines returns a function that returns the next line of the stream, with the replacements applied and its line
+// break, if it has one, and false once there are no more lines.
+func (s *Stream) lines() (func() (string, bool, error), io.Closer, error) {
+	fd, err := os.Open(s.path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening stream spool file: %w", err)
+	}
+	r := bufio.NewReader(fd)
+	replacer := s.replacer()
+	return func() (string, bool, error) {
+		line, err := r.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return "", false, nil
+		}
+		if err != nil && err != io.EOF {
+			return "", false, fmt.Errorf("reading stream: %w", err)
+		}
+		if strings.HasSuffix(line, "\n") {
+			return replacer.Replace(line[:len(line)-1]) + "\n", true, nil
+		}
+		return replacer.Replace(line), true, nil
+	}, fd, nil
+}
+
+func (s *Stream) replacer() *strings.Replacer {
+	repl := make([]string, 0, len(s.replacers)*2)
+	for k, v := range s.replacers {
+		repl = append(repl, k, v)
+	}
+	return strings.NewReplacer(repl...)
+}
+
+func (s *Stream) CompareTo(c snapshots.Comparable) (string, error) {
+	return s.CompareToContext(context.Background(), c)
+}
+
+// CompareToContext implements snapshots.ContextComparer, once ctx is done the comparison stops at the current line
+// and the differences found until then are returned, there is no summary of the whole streams without reading them.
+// Without replacers the sizes are compared first, replacing changes them, so they are not reported when there are
+// replacers, a missing line break at the end is reported either way.
+func (s *Stream) CompareToContext(ctx context.Context, c snapshots.Comparable) (string, error) {
+	other, isStream := c.(*Stream)
+	if !isStream {
+		return "", snapshots.CantCompare(fmt.Sprintf("%T", s), fmt.Sprintf("%T", c))
+	}
+	switch {
+	case s.loadErr != nil && other.loadErr != nil:
+		return "", snapshots.BothPartsInvalid(fmt.Sprintf("%T", s), fmt.Sprintf("%T", c), s.Kind())
+	case s.loadErr != nil:
+		return "", snapshots.InvalidSource(fmt.Sprintf("%T", s), s.Kind())
+	case other.loadErr != nil:
+		return "", snapshots.InvalidTarget(fmt.Sprintf("%T", c), c.Kind())
+	}
+	expected, expectedFile, err := s.lines()
+	if err != nil {
+		return "", err
+	}
+	defer expectedFile.Close()
+	got, gotFile, err := other.lines()
+	if err != nil {
+		return "", err
+	}
+	defer gotFile.Close()
+
+	var result strings.Builder
+	if s.size != other.size && len(s.replacers) == 0 {
+		result.WriteString(fmt.Sprintf("Size: expected %d bytes but got %d\n", s.size, other.size))
+	}
+	differences := 0
+	for line := 1; ; line++ {
+		e, hasExpected, err := expected()
+		if err != nil {
+			return "", err
+		}
+		g, hasGot, err := got()
+		if err != nil {
+			return "", err
+		}
+		if !hasExpected && !hasGot {
+			break
+		}
+		if ctx.Err() != nil {
+			result.WriteString(fmt.Sprintf(
+				"[...] stopped at line %d, the comparison took too long to show the whole difference\n", line))
+			break
+		}
+		te, tg := strings.TrimSuffix(e, "\n"), strings.TrimSuffix(g, "\n")
+		switch {
+		case !hasGot:
+			result.WriteString(fmt.Sprintf("line %d: is expected but not present, expected %q\n", line, te))
+		case !hasExpected:
+			result.WriteString(fmt.Sprintf("line %d: is not expected but present, with %q\n", line, tg))
+		case te != tg:
+			result.WriteString(fmt.Sprintf("line %d: expected %q but got %q\n", line, te, tg))
+		case e != g && te == e:
+			result.WriteString(fmt.Sprintf("line %d: is not expected to end with a line break\n", line))
+		case e != g:
+			result.WriteString(fmt.Sprintf("line %d: is expected to end with a line break\n", line))
+		default:
+			continue
+		}
+		differences++
+		if s.maxDifferences > 0 && differences == s.maxDifferences {
+			result.WriteString(fmt.Sprintf("[...] stopped after %d differences\n", differences))
+			break
+		}
+	}
+	return result.String(), nil
+}
+
+// String returns the whole stream, which defeats its purpose, prefer DumpTo.
+func (s *Stream) String() string {
+	return string(s.Dump())
+}
+
+const KindStream snapshots.Kind = "stream"
+
+func (s *Stream) Kind() snapshots.Kind {
+	return KindStream
+}
+
+// Dump returns the whole stream, which defeats its purpose, snapshots are written with DumpTo.
+func (s *Stream) Dump() []byte {
+	var b bytes.Buffer
+	if err := s.DumpTo(&b); err != nil {
+		panic(err)
+	}
+	return b.Bytes()
+}
+
+// DumpTo writes the stream, with the replacements applied, to w.
+func (s *Stream) DumpTo(w io.Writer) error {
+	fd, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("opening stream spool file: %w", err)
+	}
+	defer fd.Close()
+	if len(s.replacers) == 0 {
+		_, err = io.Copy(w, fd)
+		return err
+	}
+	r := bufio.NewReader(fd)
+	replacer := s.replacer()
+	for {
+		line, err := r.ReadString('\n')
+		if _, werr := replacer.WriteString(w, line); werr != nil {
+			return werr
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Load spools raw, Streams are loaded with LoadReader when run through the helpers.
+func (s *Stream) Load(raw []byte) snapshots.Comparable {
+	loaded, err := s.LoadReader(bytes.NewReader(raw))
+	if err != nil {
+		return &Stream{maxDifferences: s.maxDifferences, loadErr: err}
+	}
+	return loaded
+}
+
+// LoadReader spools the snapshot read from r into a new Stream, which must be closed.
+func (s *Stream) LoadReader(r io.Reader) (snapshots.Comparable, error) {
+	loaded := &Stream{maxDifferences: s.maxDifferences}
+	if err := loaded.spool(r); err != nil {
+		return nil, err
+	}
+	return loaded, nil
+}
+
+// Replace takes strings and replaces them by the passed values, as StringComparable does, within each line.
+func (s *Stream) Replace(rs map[string]string) {
+	s.replacers = rs
+}
+
+func (s *Stream) Extension() string {
+	return "txt"
+}
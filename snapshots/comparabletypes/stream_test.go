@@ -0,0 +1,149 @@
+package comparabletypes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func newTestStream(t *testing.T, s string) *Stream {
+	t.Helper()
+	st, err := NewStreamComparable(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+func TestStream_CompareTo(t *testing.T) {
+	var many strings.Builder
+	for i := 0; i < 100; i++ {
+		many.WriteString(fmt.Sprintf("packet %d\n", i))
+	}
+	tests := []struct {
+		name           string
+		expected       string
+		got            string
+		maxDifferences int
+		replacer       map[string]string
+		want           string
+	}{
+		{
+			name:     "equal",
+			expected: many.String(),
+			got:      many.String(),
+		},
+		{
+			name:     "different lines",
+			expected: "a\nb\nc\n",
+			got:      "a\nB\nc\nd\n",
+			want: `Size: expected 6 bytes but got 8
+line 2: expected "b" but got "B"
+line 4: is not expected but present, with "d"
+`,
+		},
+		{
+			name:     "missing line break",
+			expected: "a\nb\n",
+			got:      "a\nb",
+			want:     "Size: expected 4 bytes but got 3\nline 2: is expected to end with a line break\n",
+		},
+		{
+			name:     "replaced missing line break",
+			expected: "took 10ms\nok",
+			got:      "took 1234ms\nok\n",
+			replacer: map[string]string{"10ms": "<time>", "1234ms": "<time>"},
+			want:     "line 2: is not expected to end with a line break\n",
+		},
+		{
+			name:           "stops after max differences",
+			expected:       many.String(),
+			got:            strings.ReplaceAll(many.String(), "packet", "frame"),
+			maxDifferences: 2,
+			want: `Size: expected 990 bytes but got 890
+line 1: expected "packet 0" but got "frame 0"
+line 2: expected "packet 1" but got "frame 1"
+[...] stopped after 2 differences
+`,
+		},
+		{
+			name:     "replaced",
+			expected: "took 10ms\nok\n",
+			got:      "took 1234ms\nok\n",
+			replacer: map[string]string{"10ms": "<time>", "1234ms": "<time>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStream(t, tt.expected)
+			if tt.maxDifferences != 0 {
+				s.SetMaxDifferences(tt.maxDifferences)
+			}
+			loaded := s.Load(s.Dump()).(*Stream)
+			defer loaded.Close()
+			other := newTestStream(t, tt.got)
+			if tt.replacer != nil {
+				loaded.Replace(tt.replacer)
+				other.Replace(tt.replacer)
+			}
+			got, err := loaded.CompareTo(other)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("CompareTo() = \n%s\n, want \n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStream_DumpTo(t *testing.T) {
+	s := newTestStream(t, "took 10ms\nok")
+	s.Replace(map[string]string{"10ms": "<time>"})
+	var b strings.Builder
+	if err := s.DumpTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "took <time>\nok"; got != want {
+		t.Errorf("DumpTo() = %q, want %q", got, want)
+	}
+}
+
+func TestStream_CompareToContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got, err := newTestStream(t, "a\nb\nc\n").CompareToContext(ctx, newTestStream(t, "a\nx\nc\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "[...] stopped at line 1, the comparison took too long to show the whole difference\n"
+	if got != want {
+		t.Errorf("CompareToContext() got = %q, want %q", got, want)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(_ []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestNewStreamComparableRemovesSpoolOnError(t *testing.T) {
+	d := t.TempDir()
+	t.Setenv("TMPDIR", d)
+	if _, err := NewStreamComparable(io.MultiReader(strings.NewReader("a\n"), failingReader{})); err == nil {
+		t.Fatal("NewStreamComparable() expected an error")
+	}
+	entries, err := os.ReadDir(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("the spool file was left behind: %v", entries)
+	}
+}
@@ -2,37 +2,199 @@ package comparabletypes
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"strings"
+	"unicode"
 
 	"github.com/sergi/go-diff/diffmatchpatch"
+	"golang.org/x/text/unicode/norm"
+
 	"perri.to/expect/snapshots"
 )
 
 const DefaultContextSize = 3
 
 var _ snapshots.Comparable = (*StringComparable)(nil)
+var _ snapshots.ContextComparer = (*StringComparable)(nil)
+var _ snapshots.ContextComparer = (*PrettyStringComparable)(nil)
 
 type StringComparable struct {
+	withRenderer
 	string
 	// how many lines of context we want to print before and after a difference, -1 to turn off
 	contextSize int
+	// unified makes differences be printed as a unified diff instead of inline markers.
+	unified bool
+	// intraline highlights the changed parts of modified lines in a unified diff.
+	intraline bool
+	// normalization is applied to both sides before comparing.
+	normalization StringNormalization
+	// explicit holds the options the comparable was constructed with, they take precedence over the configured ones.
+	explicit StringOptions
 }
 type PrettyStringComparable struct {
 	StringComparable
 }
 
+// StringDiffInline and StringDiffUnified are the possible values of StringOptions.Diff.
+const (
+	StringDiffInline  = "inline"
+	StringDiffUnified = "unified"
+)
+
+// StringOptions are the options of the string comparables, they can be set per kind in the `options` section of
+// the configuration, ie: `"options": {"string": {"diff": "unified"}}`.
+type StringOptions struct {
+	// Diff is the format of the differences, StringDiffInline (the default) or StringDiffUnified.
+	Diff string `json:"diff,omitempty"`
+	// ContextSize is how many lines of context are printed around differences, -1 for all, 3 if not set.
+	ContextSize *int `json:"context_size,omitempty"`
+	// Intraline highlights the changed parts of modified lines in unified diffs, if the theme can.
+	Intraline bool `json:"intraline,omitempty"`
+	StringNormalization
+}
+
+// StringNormalization holds the differences that are not important when comparing strings, they are normalized
+// away in both the expectation and the result, the snapshots are stored as they are.
+type StringNormalization struct {
+	// LineEndings turns \r\n and \r into \n.
+	LineEndings bool `json:"normalize_line_endings,omitempty"`
+	// TrailingWhitespace removes the whitespace at the end of the lines.
+	TrailingWhitespace bool `json:"trim_trailing_whitespace,omitempty"`
+	// BlankLines collapses consecutive blank lines into one.
+	BlankLines bool `json:"collapse_blank_lines,omitempty"`
+	// Indentation removes the whitespace at the start of the lines.
+	Indentation bool `json:"ignore_indentation,omitempty"`
+	// UnicodeNFC normalizes the text to the unicode Normalization Form C, so composed and decomposed characters
+	// are the same.
+	UnicodeNFC bool `json:"unicode_nfc,omitempty"`
+}
+
+// apply returns s normalized.
+func (n StringNormalization) apply(s string) string {
+	if n.UnicodeNFC {
+		s = norm.NFC.String(s)
+	}
+	if n.LineEndings {
+		s = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(s)
+	}
+	if !n.TrailingWhitespace && !n.BlankLines && !n.Indentation {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	normalized := lines[:0]
+	for _, line := range lines {
+		if n.TrailingWhitespace {
+			line = strings.TrimRightFunc(line, unicode.IsSpace)
+		}
+		if n.Indentation {
+			line = strings.TrimLeftFunc(line, unicode.IsSpace)
+		}
+		if n.BlankLines && strings.TrimSpace(line) == "" && len(normalized) > 0 &&
+			strings.TrimSpace(normalized[len(normalized)-1]) == "" {
+			continue
+		}
+		normalized = append(normalized, line)
+	}
+	return strings.Join(normalized, "\n")
+}
+
 // NewStringComparable constructs a StringComparable from a string.
 func NewStringComparable(s string) snapshots.Comparable {
-	sc := StringComparable{s, DefaultContextSize}
+	sc := StringComparable{string: s, contextSize: DefaultContextSize}
 	return &sc
 }
 
 // NewPrettyStringComparable constructs a PrettyStringComparable from a string.
 func NewPrettyStringComparable(s string) snapshots.Comparable {
-	sc := StringComparable{s, DefaultContextSize}
+	sc := StringComparable{string: s, contextSize: DefaultContextSize}
 	return &PrettyStringComparable{sc}
 }
 
+// NewStringComparableWithOptions constructs a StringComparable, or a PrettyStringComparable if pretty is true,
+// from a string with the passed options.
+func NewStringComparableWithOptions(s string, pretty bool, o StringOptions) (snapshots.Comparable, error) {
+	sc := StringComparable{string: s, contextSize: DefaultContextSize, explicit: o}
+	if err := sc.setOptions(o); err != nil {
+		return nil, err
+	}
+	if pretty {
+		return &PrettyStringComparable{sc}, nil
+	}
+	return &sc, nil
+}
+
+func (s *StringComparable) setOptions(o StringOptions) error {
+	if err := s.setDiff(o.Diff); err != nil {
+		return err
+	}
+	if o.ContextSize != nil {
+		s.contextSize = *o.ContextSize
+	}
+	s.intraline = o.Intraline
+	s.normalization = o.StringNormalization
+	return nil
+}
+
+func (s *StringComparable) setDiff(diff string) error {
+	switch diff {
+	case "", StringDiffInline:
+		s.unified = false
+	case StringDiffUnified:
+		s.unified = true
+	default:
+		return fmt.Errorf("unknown string diff format %q", diff)
+	}
+	return nil
+}
+
+// Configure takes StringOptions in json, only the options present are applied and not over the ones passed to
+// NewStringComparableWithOptions, which were asked for by the test.
+func (s *StringComparable) Configure(raw json.RawMessage) error {
+	var o StringOptions
+	if err := json.Unmarshal(raw, &o); err != nil {
+		return fmt.Errorf("unmarshaling string options: %w", err)
+	}
+	var present map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &present); err != nil {
+		return fmt.Errorf("unmarshaling string options: %w", err)
+	}
+	configured := func(key string, explicit bool) bool {
+		_, ok := present[key]
+		return ok && !explicit
+	}
+	if configured("diff", s.explicit.Diff != "") {
+		if err := s.setDiff(o.Diff); err != nil {
+			return err
+		}
+	}
+	if configured("context_size", s.explicit.ContextSize != nil) && o.ContextSize != nil {
+		s.contextSize = *o.ContextSize
+	}
+	if configured("intraline", s.explicit.Intraline) {
+		s.intraline = o.Intraline
+	}
+	n, explicit := &s.normalization, s.explicit.StringNormalization
+	if configured("normalize_line_endings", explicit.LineEndings) {
+		n.LineEndings = o.LineEndings
+	}
+	if configured("trim_trailing_whitespace", explicit.TrailingWhitespace) {
+		n.TrailingWhitespace = o.TrailingWhitespace
+	}
+	if configured("collapse_blank_lines", explicit.BlankLines) {
+		n.BlankLines = o.BlankLines
+	}
+	if configured("ignore_indentation", explicit.Indentation) {
+		n.Indentation = o.Indentation
+	}
+	if configured("unicode_nfc", explicit.UnicodeNFC) {
+		n.UnicodeNFC = o.UnicodeNFC
+	}
+	return nil
+}
+
 func (s *StringComparable) Subtypes() bool {
 	return false
 }
@@ -42,27 +204,63 @@ func (s *StringComparable) ReplaceSubtypes(_ map[snapshots.Kind]map[string]strin
 }
 
 func (s *StringComparable) CompareTo(c snapshots.Comparable) (string, error) {
-	return s.compareTo(c, false)
+	return s.compareTo(context.Background(), c, false)
 }
 func (s *PrettyStringComparable) CompareTo(c snapshots.Comparable) (string, error) {
-	return s.compareTo(c, true)
+	return s.compareTo(context.Background(), c, true)
+}
+
+// CompareToContext implements snapshots.ContextComparer, the differences found by the deadline of ctx are coarser
+// and, if it is exceeded, summarized.
+func (s *StringComparable) CompareToContext(ctx context.Context, c snapshots.Comparable) (string, error) {
+	return s.compareTo(ctx, c, false)
+}
+
+// CompareToContext implements snapshots.ContextComparer, see StringComparable.CompareToContext.
+func (s *PrettyStringComparable) CompareToContext(ctx context.Context, c snapshots.Comparable) (string, error) {
+	return s.compareTo(ctx, c, true)
 }
 
-func (s *StringComparable) compareTo(c snapshots.Comparable, pretty bool) (string, error) {
-	otherStr := c.String()
+func (s *StringComparable) compareTo(ctx context.Context, c snapshots.Comparable, pretty bool) (string, error) {
+	ownStr, otherStr := s.normalization.apply(s.string), s.normalization.apply(c.String())
+	dmp := newDiffMatchPatch(ctx)
+	ownStr, err := resolveMarkers(dmp, ownStr, otherStr)
+	if err != nil {
+		return "", err
+	}
 	// let's save some time, also i think Diffmatchpatch returns a DiffEqual token if all is equal
-	if s.string == otherStr {
+	if ownStr == otherStr {
 		return "", nil
 	}
-	dmp := diffmatchpatch.New()
-	diffs := dmp.DiffMain(s.string, otherStr, false)
+	diff := s.diff(dmp, ownStr, otherStr, pretty)
+	if ctx.Err() != nil {
+		return snapshots.DiffSummary([]byte(ownStr), []byte(otherStr)), nil
+	}
+	return diff, nil
+}
+
+// diff returns the difference between ownStr and otherStr as configured.
+func (s *StringComparable) diff(dmp *diffMatchPatch, ownStr, otherStr string, pretty bool) string {
+	renderer := s.currentRenderer()
+	// only the pretty variant is themed, the plain one always uses markers.
+	theme := snapshots.ThemePlain
+	if pretty {
+		theme = renderer.Theme
+	}
+	if renderer.Layout == snapshots.LayoutSideBySide {
+		return sideBySideDiff(dmp, ownStr, otherStr, s.contextSize, theme, renderer.ColumnWidth())
+	}
+	if s.unified {
+		return unifiedDiff(dmp, ownStr, otherStr, s.contextSize, theme, s.intraline)
+	}
+	diffs := dmp.DiffMain(ownStr, otherStr, false)
 
 	var buffer bytes.Buffer
 	for j, diff := range diffs {
 		switch diff.Type {
 		case diffmatchpatch.DiffEqual:
 			if s.contextSize == -1 || !strings.ContainsRune(diff.Text, '\n') {
-				buffer.WriteString(diff.Text)
+				buffer.WriteString(theme.EscapeText(diff.Text))
 			} else {
 				hasLastNewLine := diff.Text[len(diff.Text)-1] == '\n'
 				lines := strings.Split(diff.Text, "\n")
@@ -80,22 +278,19 @@ func (s *StringComparable) compareTo(c snapshots.Comparable, pretty bool) (strin
 				// no context before this, we skip printing upper context
 				if j != 0 {
 					for _, line := range lines[:lower+1] {
-						buffer.WriteString(line)
+						buffer.WriteString(theme.EscapeText(line))
 						buffer.WriteRune('\n')
 					}
 				}
 				if lower != -1 {
-					if pretty {
-						buffer.WriteString("\x1b[33m[...]\x1b[0m\n")
-					} else {
-						buffer.WriteString("{=...=}\n")
-					}
+					buffer.WriteString(theme.Elision)
+					buffer.WriteRune('\n')
 				}
 				// no context after this, we skip printing lower context
 				if j != len(diffs)-1 {
 					last := l - upper
 					for i, line := range lines[upper:] {
-						buffer.WriteString(line)
+						buffer.WriteString(theme.EscapeText(line))
 						if i != last-1 {
 							buffer.WriteRune('\n')
 						}
@@ -106,32 +301,12 @@ func (s *StringComparable) compareTo(c snapshots.Comparable, pretty bool) (strin
 				}
 			}
 		case diffmatchpatch.DiffDelete:
-			if pretty {
-				buffer.WriteString("\x1b[31m")
-			} else {
-				buffer.WriteString("{-")
-			}
-			buffer.WriteString(diff.Text)
-			if pretty {
-				buffer.WriteString("\x1b[0m")
-			} else {
-				buffer.WriteString("-}")
-			}
+			buffer.WriteString(theme.Paint(snapshots.StyleDeleted, diff.Text))
 		case diffmatchpatch.DiffInsert:
-			if pretty {
-				buffer.WriteString("\x1b[32m")
-			} else {
-				buffer.WriteString("{+")
-			}
-			buffer.WriteString(diff.Text)
-			if pretty {
-				buffer.WriteString("\x1b[0m")
-			} else {
-				buffer.WriteString("+}")
-			}
+			buffer.WriteString(theme.Paint(snapshots.StyleInserted, diff.Text))
 		}
 	}
-	return buffer.String(), nil
+	return buffer.String()
 }
 
 func (s *StringComparable) String() string {
@@ -149,12 +324,14 @@ func (s *StringComparable) Dump() []byte {
 }
 
 func (s *StringComparable) Load(rawS []byte) snapshots.Comparable {
-	sc := StringComparable{string(rawS), s.contextSize}
+	sc := *s
+	sc.string = string(rawS)
 	return &sc
 }
 
 func (s *PrettyStringComparable) Load(rawS []byte) snapshots.Comparable {
-	sc := StringComparable{string(rawS), s.contextSize}
+	sc := s.StringComparable
+	sc.string = string(rawS)
 	psc := PrettyStringComparable{sc}
 	return &psc
 }
@@ -166,7 +343,12 @@ func (s *StringComparable) Replace(r map[string]string) {
 		repl = append(repl, k, v)
 	}
 	rs := strings.NewReplacer(repl...).Replace(s.string)
-	*s = StringComparable{rs, s.contextSize}
+	s.string = rs
+}
+
+// Merge keeps the markers of the previous snapshot, in the lines they still match, when it is updated.
+func (s *StringComparable) Merge(previous []byte) []byte {
+	return []byte(preserveMarkers(string(previous), s.string))
 }
 
 func (s *StringComparable) Extension() string {
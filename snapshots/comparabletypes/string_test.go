@@ -1,13 +1,16 @@
 package comparabletypes
 
 import (
+	"context"
+	"encoding/json"
 	"testing"
+	"time"
 
 	"perri.to/expect/snapshots"
 )
 
 func newStringComparableFromLiteral(s string) *StringComparable {
-	c := StringComparable(s)
+	c := StringComparable{string: s, contextSize: DefaultContextSize}
 	return &c
 }
 
@@ -30,7 +33,7 @@ func TestStringComparable_CompareTo(t *testing.T) {
 			name: "half different",
 			s:    newStringComparableFromLiteral("Lorem ipsum dolor."),
 			args: args{c: NewStringComparable("Lorem dolor sit amet.")},
-			want: "@@ -3,16 +3,19 @@\n rem \n-ipsum \n dolor\n+ sit amet\n .\n",
+			want: "Lorem {-ipsum -}dolor{+ sit amet+}.",
 		}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -56,14 +59,14 @@ func TestPrettyStringComparable_CompareTo(t *testing.T) {
 		want string
 	}{{
 		name: "all equal",
-		s:    &PrettyStringComparable{"Lorem ipsum dolor."},
+		s:    &PrettyStringComparable{*newStringComparableFromLiteral("Lorem ipsum dolor.")},
 		args: args{c: NewStringComparable("Lorem ipsum dolor.")},
 		want: "",
 	},
 		{
 			name: "half different",
-			s:    &PrettyStringComparable{"Lorem ipsum dolor."},
-			args: args{c: &PrettyStringComparable{StringComparable("Lorem dolor sit amet.")}},
+			s:    &PrettyStringComparable{*newStringComparableFromLiteral("Lorem ipsum dolor.")},
+			args: args{c: &PrettyStringComparable{*newStringComparableFromLiteral("Lorem dolor sit amet.")}},
 			want: "Lorem \x1b[31mipsum \x1b[0mdolor\x1b[32m sit amet\x1b[0m.",
 		}}
 	for _, tt := range tests {
@@ -78,3 +81,311 @@ func TestPrettyStringComparable_CompareTo(t *testing.T) {
 		})
 	}
 }
+
+func TestStringComparable_CompareToUnified(t *testing.T) {
+	const expected = "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	tests := []struct {
+		name    string
+		got     string
+		options string
+		pretty  bool
+		want    string
+	}{
+		{
+			name:    "equal",
+			got:     expected,
+			options: `{"diff": "unified"}`,
+		},
+		{
+			name:    "two hunks",
+			got:     "one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\n",
+			options: `{"diff": "unified", "context_size": 1}`,
+			want: `--- expected
++++ actual
+@@ -1,3 +1,3 @@
+ one
+-two
++2
+ three
+@@ -10 +10,2 @@
+ ten
++eleven
+`,
+		},
+		{
+			name:    "no newline at end",
+			got:     "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten",
+			options: `{"diff": "unified", "context_size": 0}`,
+			want: `--- expected
++++ actual
+@@ -10 +10 @@
+-ten
++ten
+\ No newline at end of file
+`,
+		},
+		{
+			name:    "pretty intraline",
+			got:     "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nTEN\n",
+			options: `{"diff": "unified", "context_size": 0, "intraline": true}`,
+			pretty:  true,
+			want: "\x1b[1m--- expected\x1b[0m\n\x1b[1m+++ actual\x1b[0m\n\x1b[36m@@ -10 +10 @@\x1b[0m\n" +
+				"\x1b[31m-\x1b[7mten\x1b[27m\x1b[0m\n\x1b[32m+\x1b[7mTEN\x1b[27m\x1b[0m\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var o StringOptions
+			if err := json.Unmarshal([]byte(tt.options), &o); err != nil {
+				t.Fatal(err)
+			}
+			s, err := NewStringComparableWithOptions(expected, tt.pretty, o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// options are carried over to the loaded expectation, as when read from a snapshot.
+			got, err := s.Load(s.Dump()).CompareTo(NewStringComparable(tt.got))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("CompareTo() = \n%q\n, want \n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrettyStringComparable_CompareToRendered(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		got      string
+		renderer snapshots.Renderer
+		want     string
+	}{
+		{
+			name:     "plain",
+			expected: "Lorem <ipsum> dolor.",
+			got:      "Lorem <ipsum> sit amet.",
+			renderer: snapshots.Renderer{Theme: snapshots.ThemePlain},
+			want:     "Lorem <ipsum> {-dolor-}{+sit amet+}.",
+		},
+		{
+			name:     "html",
+			expected: "Lorem <ipsum> dolor.",
+			got:      "Lorem <ipsum> sit amet.",
+			renderer: snapshots.Renderer{Theme: snapshots.ThemeHTML},
+			want:     "Lorem &lt;ipsum&gt; <del>dolor</del><ins>sit amet</ins>.",
+		},
+		{
+			name:     "side by side",
+			expected: "one\ntwo\n<three>\nfour\n",
+			got:      "one\n2\n<three>\nfour\nfive\n",
+			renderer: snapshots.Renderer{Theme: snapshots.ThemePlain, Layout: snapshots.LayoutSideBySide, Width: 23},
+			want: `expected     actual
+one          one
+two        | 2
+<three>      <three>
+four         four
+           > five
+`,
+		},
+		{
+			name:     "side by side too narrow",
+			expected: "one\ntwo\n",
+			got:      "one\n2\n",
+			renderer: snapshots.Renderer{Theme: snapshots.ThemePlain, Layout: snapshots.LayoutSideBySide, Width: 4},
+			want: `expected     actual
+one          one
+two        | 2
+`,
+		},
+		{
+			name:     "side by side html",
+			expected: "one\ntwo\n<three>\nfour\n",
+			got:      "one\n2\n<three>\nfour\nfive\n",
+			renderer: snapshots.Renderer{Theme: snapshots.ThemeHTML, Layout: snapshots.LayoutSideBySide, Width: 23},
+			want: `<b>expected     actual</b>
+one          one
+<del>two       </del> | <ins>2</ins>
+&lt;three&gt;      &lt;three&gt;
+four         four
+<ins>           &gt; five</ins>
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewPrettyStringComparable(tt.expected)
+			s.(snapshots.Rendering).SetRenderer(tt.renderer)
+			// the renderer is carried over to the loaded expectation, as when read from a snapshot.
+			got, err := s.Load(s.Dump()).CompareTo(NewPrettyStringComparable(tt.got))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("CompareTo() = \n%q\n, want \n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringComparable_CompareToNormalized(t *testing.T) {
+	const expected = "Hello\n\n  World\ncafé\n"
+	tests := []struct {
+		name          string
+		got           string
+		normalization StringNormalization
+		want          string
+	}{
+		{
+			name: "not normalized",
+			got:  "Hello \n\n  World\ncafé\n",
+			want: "Hello{+ +}",
+		},
+		{
+			name:          "line endings",
+			got:           "Hello\r\n\r\n  World\r\ncafé\r\n",
+			normalization: StringNormalization{LineEndings: true},
+		},
+		{
+			name:          "trailing whitespace",
+			got:           "Hello \n\t\n  World  \ncafé\n",
+			normalization: StringNormalization{TrailingWhitespace: true},
+		},
+		{
+			name:          "blank lines",
+			got:           "Hello\n\n\n\n  World\ncafé\n",
+			normalization: StringNormalization{BlankLines: true},
+		},
+		{
+			name:          "indentation",
+			got:           "Hello\n\n\tWorld\ncafé\n",
+			normalization: StringNormalization{Indentation: true},
+		},
+		{
+			name:          "unicode",
+			got:           "Hello\n\n  World\ncafe\u0301\n",
+			normalization: StringNormalization{UnicodeNFC: true},
+		},
+		{
+			name:          "still different",
+			got:           "Hello\r\n\r\n  Mars\r\ncafé\r\n",
+			normalization: StringNormalization{LineEndings: true},
+			want:          "Hello\n\n  {-Wo-}{+Ma+}r{-ld-}{+s+}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewStringComparableWithOptions(expected, false, StringOptions{StringNormalization: tt.normalization})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.Load(s.Dump()).CompareTo(NewStringComparable(tt.got))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("CompareTo() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringComparable_ConfigureKeepsExplicitOptions(t *testing.T) {
+	contextSize := 1
+	c, err := NewStringComparableWithOptions("hello", false, StringOptions{
+		Diff:                StringDiffUnified,
+		ContextSize:         &contextSize,
+		StringNormalization: StringNormalization{TrailingWhitespace: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := c.(*StringComparable)
+	err = s.Configure([]byte(`{"diff": "inline", "context_size": 5, "trim_trailing_whitespace": false, "normalize_line_endings": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the options of the test win, the configured ones fill the rest.
+	if !s.unified || s.contextSize != 1 || !s.normalization.TrailingWhitespace || !s.normalization.LineEndings {
+		t.Errorf("Configure() left unified = %v, contextSize = %d, normalization = %+v", s.unified, s.contextSize,
+			s.normalization)
+	}
+	// options that are not configured are left alone.
+	if err := s.Configure([]byte(`{"intraline": true}`)); err != nil {
+		t.Fatal(err)
+	}
+	if !s.intraline || !s.unified || !s.normalization.LineEndings {
+		t.Errorf("Configure() left intraline = %v, unified = %v, normalization = %+v", s.intraline, s.unified,
+			s.normalization)
+	}
+}
+
+func TestStringComparable_CompareToContext(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		a, b string
+		want string
+	}{
+		{
+			name: "no_deadline",
+			ctx:  context.Background(),
+			a:    "hello world",
+			b:    "hello there",
+			want: "hello {-wo-}{+the+}r{-ld-}{+e+}",
+		},
+		{
+			name: "deadline_exceeded",
+			ctx:  expired,
+			a:    "hello world",
+			b:    "hello there!",
+			want: "differs at byte 6, sizes 11 vs 12 (the comparison took too long to show the whole difference)\n",
+		},
+		{
+			name: "deadline_exceeded_equal",
+			ctx:  expired,
+			a:    "hello world",
+			b:    "hello world",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newStringComparableFromLiteral(tt.a).CompareToContext(tt.ctx, newStringComparableFromLiteral(tt.b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("CompareToContext() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_diffMatchPatchLimit(t *testing.T) {
+	soon, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	later, cancelLater := context.WithTimeout(context.Background(), time.Hour)
+	defer cancelLater()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		max  time.Duration
+	}{
+		{name: "no_deadline", ctx: context.Background(), max: defaultDiffTimeout},
+		{name: "deadline_soon", ctx: soon, max: 10 * time.Millisecond},
+		{name: "deadline_later", ctx: later, max: defaultDiffTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dmp := newDiffMatchPatch(tt.ctx)
+			dmp.DiffMain("hello world", "hello there", false)
+			if dmp.DiffTimeout <= 0 || dmp.DiffTimeout > tt.max {
+				t.Errorf("DiffTimeout = %v, want it in (0, %v]", dmp.DiffTimeout, tt.max)
+			}
+		})
+	}
+}
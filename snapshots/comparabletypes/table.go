@@ -0,0 +1,273 @@
+package comparabletypes
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+
+	"perri.to/expect/snapshots"
+)
+
+var _ snapshots.Comparable = (*Table)(nil)
+
+// Table holds delimited tabular data, such as CSV or TSV, whose first row is the header. Cells are compared by
+// column name, so reordering the columns is not a difference, and rows either by position or, if keyed, by the
+// value of the key column.
+type Table struct {
+	withRenderer
+	raw       []byte
+	delimiter rune
+	keyBy     string
+	ignored   map[string]bool
+	replacers map[string]string
+}
+
+// NewCSVComparable constructs a Table comparable from comma separated values with a header row.
+func NewCSVComparable(b []byte) *Table {
+	return &Table{raw: b, delimiter: ',', ignored: map[string]bool{}}
+}
+
+// NewTSVComparable constructs a Table comparable from tab separated values with a header row.
+func NewTSVComparable(b []byte) *Table {
+	return &Table{raw: b, delimiter: '\t', ignored: map[string]bool{}}
+}
+
+// KeyBy makes rows be matched by the value in column instead of by their position, which makes the comparison
+// insensitive to the order of the rows.
+func (t *Table) KeyBy(column string) {
+	t.keyBy = column
+}
+
+// IgnoreColumns makes the named columns not be compared, they are still stored in the snapshot.
+func (t *Table) IgnoreColumns(columns ...string) {
+	for _, c := range columns {
+		t.ignored[c] = true
+	}
+}
+
+func (t *Table) Subtypes() bool {
+	return false
+}
+
+func (t *Table) ReplaceSubtypes(_ map[snapshots.Kind]map[string]string) {
+	return
+}
+
+// parsedTable is the header and rows of a Table, every row has as many cells as the header.
+type parsedTable struct {
+	header []string
+	rows   [][]string
+}
+
+func (p *parsedTable) column(name string) int {
+	for i, h := range p.header {
+		if h == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// rowName returns how a row is called in the differences, its position, counting from 1 after the header,
+// and the key if there is one.
+func (p *parsedTable) rowName(row int, key int) string {
+	if key == -1 {
+		return fmt.Sprintf("row %d", row+1)
+	}
+	return fmt.Sprintf("row %d (%s=%s)", row+1, p.header[key], p.rows[row][key])
+}
+
+func (t *Table) parse() (*parsedTable, error) {
+	r := csv.NewReader(bytes.NewReader(t.raw))
+	r.Comma = t.delimiter
+	if t.delimiter == '\t' {
+		// TSV usually does not quote, be lenient with stray quotes.
+		r.LazyQuotes = true
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return &parsedTable{}, nil
+	}
+	p := &parsedTable{header: records[0], rows: records[1:]}
+	for column, value := range t.replacers {
+		i := p.column(column)
+		if i == -1 {
+			continue
+		}
+		for _, row := range p.rows {
+			row[i] = value
+		}
+	}
+	return p, nil
+}
+
+func (t *Table) CompareTo(c snapshots.Comparable) (string, error) {
+	other, isTable := c.(*Table)
+	if !isTable {
+		return "", snapshots.CantCompare(fmt.Sprintf("%T", t), fmt.Sprintf("%T", c))
+	}
+	expected, expectedErr := t.parse()
+	got, gotErr := other.parse()
+	switch {
+	case expectedErr != nil && gotErr != nil:
+		return "", snapshots.BothPartsInvalid(fmt.Sprintf("%T", t), fmt.Sprintf("%T", c), t.Kind())
+	case expectedErr != nil:
+		return "", snapshots.InvalidSource(fmt.Sprintf("%T", t), t.Kind())
+	case gotErr != nil:
+		return "", snapshots.InvalidTarget(fmt.Sprintf("%T", c), c.Kind())
+	}
+
+	var result strings.Builder
+	// columns holds, for each compared column, its position in the expected and in the got table.
+	var columns [][2]int
+	for i, name := range expected.header {
+		if t.ignored[name] {
+			continue
+		}
+		j := got.column(name)
+		if j == -1 {
+			result.WriteString(fmt.Sprintf("column %s: is expected but not present\n", name))
+			continue
+		}
+		columns = append(columns, [2]int{i, j})
+	}
+	for _, name := range got.header {
+		if !t.ignored[name] && expected.column(name) == -1 {
+			result.WriteString(fmt.Sprintf("column %s: is not expected but present\n", name))
+		}
+	}
+
+	expectedKey, gotKey := -1, -1
+	if t.keyBy != "" {
+		expectedKey, gotKey = expected.column(t.keyBy), got.column(t.keyBy)
+		if expectedKey == -1 || gotKey == -1 {
+			return "", fmt.Errorf("key column %s is not present in both tables", t.keyBy)
+		}
+	}
+	for _, pair := range matchTableRows(expected, got, expectedKey, gotKey) {
+		switch {
+		case pair[1] == -1:
+			result.WriteString(fmt.Sprintf("%s: is expected but not present\n", expected.rowName(pair[0], expectedKey)))
+		case pair[0] == -1:
+			result.WriteString(fmt.Sprintf("%s: is not expected but present\n", got.rowName(pair[1], gotKey)))
+		default:
+			expectedRow, gotRow := expected.rows[pair[0]], got.rows[pair[1]]
+			for _, column := range columns {
+				if expectedRow[column[0]] == gotRow[column[1]] {
+					continue
+				}
+				result.WriteString(fmt.Sprintf("%s, column %s: expected %q but got %q\n",
+					expected.rowName(pair[0], expectedKey), expected.header[column[0]],
+					expectedRow[column[0]], gotRow[column[1]]))
+			}
+		}
+	}
+	return t.escape(result.String()), nil
+}
+
+// matchTableRows pairs the positions of the expected and got rows, -1 is used for the side where a row is
+// missing. Rows are paired by position or, if the key columns are passed, by key; repeated keys are paired in
+// the order they appear.
+func matchTableRows(expected, got *parsedTable, expectedKey, gotKey int) [][2]int {
+	var pairs [][2]int
+	if expectedKey == -1 {
+		for i := 0; i < len(expected.rows) || i < len(got.rows); i++ {
+			pair := [2]int{i, i}
+			if i >= len(expected.rows) {
+				pair[0] = -1
+			}
+			if i >= len(got.rows) {
+				pair[1] = -1
+			}
+			pairs = append(pairs, pair)
+		}
+		return pairs
+	}
+	byKey := map[string][]int{}
+	for i, row := range got.rows {
+		byKey[row[gotKey]] = append(byKey[row[gotKey]], i)
+	}
+	for i, row := range expected.rows {
+		candidates := byKey[row[expectedKey]]
+		if len(candidates) == 0 {
+			pairs = append(pairs, [2]int{i, -1})
+			continue
+		}
+		pairs = append(pairs, [2]int{i, candidates[0]})
+		byKey[row[expectedKey]] = candidates[1:]
+	}
+	for i, row := range got.rows {
+		for _, left := range byKey[row[gotKey]] {
+			if left == i {
+				pairs = append(pairs, [2]int{-1, i})
+			}
+		}
+	}
+	return pairs
+}
+
+func (t *Table) String() string {
+	return string(t.Dump())
+}
+
+const KindTable snapshots.Kind = "table"
+
+func (t *Table) Kind() snapshots.Kind {
+	return KindTable
+}
+
+// Dump returns the table, with the replacements applied, written back with the standard quoting. TSV cells are
+// written raw unless they hold a tab or line break or start with a quote, those are quoted as in CSV so the dump
+// loads back into the same cells. If it can't be parsed it is returned as is.
+func (t *Table) Dump() []byte {
+	p, err := t.parse()
+	if err != nil {
+		return t.raw
+	}
+	var b bytes.Buffer
+	if t.delimiter == '\t' {
+		// csv.Writer would quote cells containing quotes, which is not TSV.
+		for _, row := range append([][]string{p.header}, p.rows...) {
+			cells := make([]string, len(row))
+			for i, cell := range row {
+				cells[i] = tsvCell(cell)
+			}
+			b.WriteString(strings.Join(cells, "\t"))
+			b.WriteString("\n")
+		}
+		return b.Bytes()
+	}
+	w := csv.NewWriter(&b)
+	if err := w.WriteAll(append([][]string{p.header}, p.rows...)); err != nil {
+		return t.raw
+	}
+	return b.Bytes()
+}
+
+// tsvCell returns cell as is, unless it would not be read back as the same cell, then it is quoted as in CSV.
+func tsvCell(cell string) string {
+	if !strings.ContainsAny(cell, "\t\r\n") && !strings.HasPrefix(cell, `"`) {
+		return cell
+	}
+	return `"` + strings.ReplaceAll(cell, `"`, `""`) + `"`
+}
+
+func (t *Table) Load(raw []byte) snapshots.Comparable {
+	return &Table{withRenderer: t.withRenderer, raw: raw, delimiter: t.delimiter, keyBy: t.keyBy, ignored: t.ignored}
+}
+
+// Replace takes column names and sets every cell of those columns to the passed value.
+func (t *Table) Replace(rs map[string]string) {
+	t.replacers = rs
+}
+
+func (t *Table) Extension() string {
+	if t.delimiter == '\t' {
+		return "tsv"
+	}
+	return "csv"
+}
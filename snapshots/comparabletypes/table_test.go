@@ -0,0 +1,143 @@
+package comparabletypes
+
+import (
+	"testing"
+)
+
+const sampleCSV = `id,name,price,updated
+1,apple,3,2022-05-02
+2,"pear, green",4,2022-05-02
+3,plum,5,2022-05-02
+`
+
+func TestTable_CompareTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		other    string
+		keyBy    string
+		ignored  []string
+		replacer map[string]string
+		want     string
+	}{
+		{
+			name:  "equal",
+			other: sampleCSV,
+		},
+		{
+			name: "reordered columns",
+			other: `name,id,updated,price
+apple,1,2022-05-02,3
+"pear, green",2,2022-05-02,4
+plum,3,2022-05-02,5
+`,
+		},
+		{
+			name: "different cells",
+			other: `id,name,price,updated
+1,apple,3,2022-05-02
+2,"pear, green",6,2022-05-02
+3,prune,5,2022-05-02
+4,fig,1,2022-05-02
+`,
+			want: `row 2, column price: expected "4" but got "6"
+row 3, column name: expected "plum" but got "prune"
+row 4: is not expected but present
+`,
+		},
+		{
+			name: "keyed",
+			other: `id,name,price,updated
+3,plum,5,2022-05-02
+1,apple,7,2022-05-02
+4,fig,1,2022-05-02
+`,
+			keyBy: "id",
+			want: `row 1 (id=1), column price: expected "3" but got "7"
+row 2 (id=2): is expected but not present
+row 3 (id=4): is not expected but present
+`,
+		},
+		{
+			name: "ignored and replaced columns",
+			other: `id,name,price,updated,fetched
+1,apple,3,2023-01-01,now
+2,"pear, green",4,2023-01-01,now
+3,plum,5,2023-01-01,now
+`,
+			ignored:  []string{"fetched"},
+			replacer: map[string]string{"updated": "<date>"},
+		},
+		{
+			name: "different columns",
+			other: `id,name,cost,updated
+1,apple,3,2022-05-02
+2,"pear, green",4,2022-05-02
+3,plum,5,2022-05-02
+`,
+			want: `column price: is expected but not present
+column cost: is not expected but present
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := NewCSVComparable([]byte(sampleCSV))
+			tb.KeyBy(tt.keyBy)
+			tb.IgnoreColumns(tt.ignored...)
+			other := NewCSVComparable([]byte(tt.other))
+			if tt.replacer != nil {
+				tb.Replace(tt.replacer)
+				other.Replace(tt.replacer)
+			}
+			expectation := tb.Load(tb.Dump())
+			if tt.replacer != nil {
+				expectation.Replace(tt.replacer)
+			}
+			got, err := expectation.CompareTo(other)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("CompareTo() got = \n%s\n, want \n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTable_Invalid(t *testing.T) {
+	tb := NewCSVComparable([]byte(sampleCSV))
+	_, err := tb.CompareTo(NewCSVComparable([]byte("id,name\n1\n")))
+	if err == nil {
+		t.Error("CompareTo() expected an error for rows with missing cells")
+	}
+}
+
+func TestTable_Dump(t *testing.T) {
+	tb := NewTSVComparable([]byte("id\tname\n1\t\"quoted\"\n"))
+	if got, want := string(tb.Dump()), "id\tname\n1\tquoted\n"; got != want {
+		t.Errorf("Dump() got = %q, want %q", got, want)
+	}
+}
+
+func TestTable_DumpQuotesTSVCells(t *testing.T) {
+	tb := NewTSVComparable([]byte("id\tname\n1\t\"tab\there\"\n2\t\"line\nbreak\"\n3\t\"\"\"starts\"\" quoted\"\n4\tin \"quotes\"\n"))
+	want := "id\tname\n1\t\"tab\there\"\n2\t\"line\nbreak\"\n3\t\"\"\"starts\"\" quoted\"\n4\tin \"quotes\"\n"
+	dump := tb.Dump()
+	if got := string(dump); got != want {
+		t.Errorf("Dump() got = %q, want %q", got, want)
+	}
+	loaded := tb.Load(dump).(*Table)
+	p, err := loaded.parse()
+	if err != nil {
+		t.Fatalf("parsing the dump: %v", err)
+	}
+	wantCells := []string{"tab\there", "line\nbreak", "\"starts\" quoted", "in \"quotes\""}
+	for i, row := range p.rows {
+		if row[1] != wantCells[i] {
+			t.Errorf("row %d got = %q, want %q", i, row[1], wantCells[i])
+		}
+	}
+	if diff, err := tb.CompareTo(loaded); diff != "" || err != nil {
+		t.Errorf("CompareTo() of the loaded dump got = %q, %v", diff, err)
+	}
+}
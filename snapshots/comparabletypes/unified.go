@@ -0,0 +1,319 @@
+package comparabletypes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+
+	"perri.to/expect/snapshots"
+)
+
+// diffLine is a line of a line based diff, text includes the line break unless it is the last line and the text
+// does not end in one.
+type diffLine struct {
+	op   diffmatchpatch.Operation
+	text string
+}
+
+// diffMatchPatch is a diffmatchpatch whose diffs give up on finding the smallest difference, and settle for a
+// larger one, after the usual diffmatchpatch timeout or by the deadline of the comparison, whichever comes first.
+// The diffmatchpatch timeout applies to each diff, and a comparison can take several, so it is set before each one
+// from what is left until the deadline.
+type diffMatchPatch struct {
+	*diffmatchpatch.DiffMatchPatch
+	// deadline is zero when the comparison has no deadline.
+	deadline time.Time
+}
+
+// defaultDiffTimeout is the usual diffmatchpatch timeout, it is kept for comparisons without a deadline.
+var defaultDiffTimeout = diffmatchpatch.New().DiffTimeout
+
+// newDiffMatchPatch returns a diffMatchPatch bound by the deadline of ctx, if any.
+func newDiffMatchPatch(ctx context.Context) *diffMatchPatch {
+	deadline, _ := ctx.Deadline()
+	return &diffMatchPatch{DiffMatchPatch: diffmatchpatch.New(), deadline: deadline}
+}
+
+// limit sets the timeout of the next diff.
+func (d *diffMatchPatch) limit() {
+	d.DiffTimeout = defaultDiffTimeout
+	if d.deadline.IsZero() {
+		return
+	}
+	if left := time.Until(d.deadline); left < d.DiffTimeout {
+		d.DiffTimeout = left
+	}
+	// a timeout of 0 is no timeout.
+	if d.DiffTimeout <= 0 {
+		d.DiffTimeout = time.Nanosecond
+	}
+}
+
+func (d *diffMatchPatch) DiffMain(text1, text2 string, checklines bool) []diffmatchpatch.Diff {
+	d.limit()
+	return d.DiffMatchPatch.DiffMain(text1, text2, checklines)
+}
+
+func (d *diffMatchPatch) DiffMainRunes(text1, text2 []rune, checklines bool) []diffmatchpatch.Diff {
+	d.limit()
+	return d.DiffMatchPatch.DiffMainRunes(text1, text2, checklines)
+}
+
+// lineDiff returns the difference between a and b line by line.
+func lineDiff(dmp *diffMatchPatch, a, b string) []diffLine {
+	// diffmatchpatch line mode mangles the line indexes, so we encode each distinct line as a rune ourselves.
+	codes := map[string]rune{}
+	encode := func(text string) []rune {
+		var encoded []rune
+		for _, line := range strings.SplitAfter(text, "\n") {
+			if line == "" {
+				continue
+			}
+			code, ok := codes[line]
+			if !ok {
+				code = lineRune(len(codes))
+				codes[line] = code
+			}
+			encoded = append(encoded, code)
+		}
+		return encoded
+	}
+	aRunes, bRunes := encode(a), encode(b)
+	decoded := make(map[rune]string, len(codes))
+	for line, code := range codes {
+		decoded[code] = line
+	}
+	var result []diffLine
+	for _, d := range dmp.DiffMainRunes(aRunes, bRunes, false) {
+		for _, code := range d.Text {
+			result = append(result, diffLine{op: d.Type, text: decoded[code]})
+		}
+	}
+	return result
+}
+
+// lineRune returns the rune used to represent the nth distinct line, skipping the surrogate range which is not
+// valid in a string.
+func lineRune(n int) rune {
+	r := rune(n + 1)
+	if r >= 0xD800 {
+		r += 0x800
+	}
+	return r
+}
+
+// unifiedDiff returns the differences between expected and got in the unified format understood by patch, with
+// contextSize lines of context around the changes (-1 for a single hunk with all the lines), styled by theme. If
+// intraline, the changed parts of modified lines are highlighted.
+func unifiedDiff(dmp *diffMatchPatch, expected, got string, contextSize int, theme snapshots.Theme,
+	intraline bool) string {
+	lines := lineDiff(dmp, expected, got)
+	if contextSize < 0 {
+		contextSize = len(lines)
+	}
+	var b strings.Builder
+	b.WriteString(theme.Paint(snapshots.StyleHeader, "--- expected") + "\n")
+	b.WriteString(theme.Paint(snapshots.StyleHeader, "+++ actual") + "\n")
+	for _, h := range unifiedHunks(lines, contextSize) {
+		b.WriteString(theme.Paint(snapshots.StyleHunk, h.header(lines)) + "\n")
+		var highlights map[int]string
+		if intraline {
+			highlights = intralineHighlights(dmp, lines[h.start:h.end], h.start, theme)
+		}
+		for i := h.start; i < h.end; i++ {
+			l := lines[i]
+			text, ok := highlights[i]
+			if !ok {
+				text = theme.EscapeText(strings.TrimSuffix(l.text, "\n"))
+			}
+			switch l.op {
+			case diffmatchpatch.DiffDelete:
+				b.WriteString(theme.Wrap(snapshots.StyleDeletedLine, "-"+text))
+			case diffmatchpatch.DiffInsert:
+				b.WriteString(theme.Wrap(snapshots.StyleInsertedLine, "+"+text))
+			default:
+				b.WriteString(" " + text)
+			}
+			b.WriteString("\n")
+			if !strings.HasSuffix(l.text, "\n") {
+				b.WriteString("\\ No newline at end of file\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// unifiedHunk is a range of diff lines that is printed together.
+type unifiedHunk struct {
+	start, end int
+}
+
+// unifiedHunks groups the changes in lines, with contextSize lines around them, changes closer than twice the
+// context are in the same hunk.
+func unifiedHunks(lines []diffLine, contextSize int) []unifiedHunk {
+	var hunks []unifiedHunk
+	for i, l := range lines {
+		if l.op == diffmatchpatch.DiffEqual {
+			continue
+		}
+		start, end := i-contextSize, i+contextSize+1
+		if start < 0 {
+			start = 0
+		}
+		if end > len(lines) {
+			end = len(lines)
+		}
+		if n := len(hunks); n > 0 && start <= hunks[n-1].end {
+			hunks[n-1].end = end
+			continue
+		}
+		hunks = append(hunks, unifiedHunk{start: start, end: end})
+	}
+	return hunks
+}
+
+// header returns the @@ line of the hunk, with the line ranges it covers in both texts.
+func (h unifiedHunk) header(lines []diffLine) string {
+	oldStart, newStart := 1, 1
+	for _, l := range lines[:h.start] {
+		if l.op != diffmatchpatch.DiffInsert {
+			oldStart++
+		}
+		if l.op != diffmatchpatch.DiffDelete {
+			newStart++
+		}
+	}
+	var oldCount, newCount int
+	for _, l := range lines[h.start:h.end] {
+		if l.op != diffmatchpatch.DiffInsert {
+			oldCount++
+		}
+		if l.op != diffmatchpatch.DiffDelete {
+			newCount++
+		}
+	}
+	return fmt.Sprintf("@@ -%s +%s @@", unifiedRange(oldStart, oldCount), unifiedRange(newStart, newCount))
+}
+
+// unifiedRange formats a range as GNU diff does: the count is omitted when it is one and an empty range starts
+// at the line before it.
+func unifiedRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// intralineHighlights pairs the lines of blocks of deletions followed by as many insertions and returns them,
+// escaped and by position, with the changed parts highlighted.
+func intralineHighlights(dmp *diffMatchPatch, lines []diffLine, offset int,
+	theme snapshots.Theme) map[int]string {
+	highlights := map[int]string{}
+	for i := 0; i < len(lines); {
+		if lines[i].op != diffmatchpatch.DiffDelete {
+			i++
+			continue
+		}
+		deletes := i
+		for i < len(lines) && lines[i].op == diffmatchpatch.DiffDelete {
+			i++
+		}
+		inserts := i
+		for i < len(lines) && lines[i].op == diffmatchpatch.DiffInsert {
+			i++
+		}
+		if inserts-deletes != i-inserts {
+			continue
+		}
+		for j := 0; j < inserts-deletes; j++ {
+			removed := strings.TrimSuffix(lines[deletes+j].text, "\n")
+			added := strings.TrimSuffix(lines[inserts+j].text, "\n")
+			diffs := dmp.DiffCleanupSemantic(dmp.DiffMain(removed, added, false))
+			var del, ins strings.Builder
+			for _, d := range diffs {
+				switch d.Type {
+				case diffmatchpatch.DiffEqual:
+					del.WriteString(theme.EscapeText(d.Text))
+					ins.WriteString(theme.EscapeText(d.Text))
+				case diffmatchpatch.DiffDelete:
+					del.WriteString(theme.Paint(snapshots.StyleHighlight, d.Text))
+				case diffmatchpatch.DiffInsert:
+					ins.WriteString(theme.Paint(snapshots.StyleHighlight, d.Text))
+				}
+			}
+			highlights[offset+deletes+j] = del.String()
+			highlights[offset+inserts+j] = ins.String()
+		}
+	}
+	return highlights
+}
+
+// sideBySideDiff returns the differences between expected and got line by line in two columns of width each,
+// like `diff -y` does, with contextSize lines of context around the changes (-1 for all of them), styled by
+// theme. The gutter marks changed lines with |, missing ones with < and extra ones with >.
+func sideBySideDiff(dmp *diffMatchPatch, expected, got string, contextSize int, theme snapshots.Theme,
+	width int) string {
+	lines := lineDiff(dmp, expected, got)
+	if contextSize < 0 {
+		contextSize = len(lines)
+	}
+	var b strings.Builder
+	b.WriteString(theme.Paint(snapshots.StyleHeader, sideBySideRow("expected", "actual", " ", width)) + "\n")
+	for n, h := range unifiedHunks(lines, contextSize) {
+		if n > 0 || h.start > 0 {
+			b.WriteString(theme.Elision + "\n")
+		}
+		for i := h.start; i < h.end; {
+			if lines[i].op == diffmatchpatch.DiffEqual {
+				b.WriteString(theme.EscapeText(sideBySideRow(lines[i].text, lines[i].text, " ", width)) + "\n")
+				i++
+				continue
+			}
+			// pair the deletions with the insertions that follow them.
+			var deleted, inserted []string
+			for ; i < h.end && lines[i].op == diffmatchpatch.DiffDelete; i++ {
+				deleted = append(deleted, lines[i].text)
+			}
+			for ; i < h.end && lines[i].op == diffmatchpatch.DiffInsert; i++ {
+				inserted = append(inserted, lines[i].text)
+			}
+			for j := 0; j < len(deleted) || j < len(inserted); j++ {
+				switch {
+				case j >= len(inserted):
+					b.WriteString(theme.Paint(snapshots.StyleDeletedLine, sideBySideRow(deleted[j], "", "<", width)))
+				case j >= len(deleted):
+					b.WriteString(theme.Paint(snapshots.StyleInsertedLine, sideBySideRow("", inserted[j], ">", width)))
+				default:
+					left := fitColumn(deleted[j], width)
+					b.WriteString(theme.Paint(snapshots.StyleDeletedLine, left) + theme.EscapeText(" | ") +
+						theme.Paint(snapshots.StyleInsertedLine, strings.TrimRight(fitColumn(inserted[j], width), " ")))
+				}
+				b.WriteString("\n")
+			}
+		}
+	}
+	if hunks := unifiedHunks(lines, contextSize); len(hunks) > 0 && hunks[len(hunks)-1].end < len(lines) {
+		b.WriteString(theme.Elision + "\n")
+	}
+	return b.String()
+}
+
+// sideBySideRow returns left and right in columns of width, separated by the gutter mark.
+func sideBySideRow(left, right, mark string, width int) string {
+	return strings.TrimRight(fitColumn(left, width)+" "+mark+" "+fitColumn(right, width), " ")
+}
+
+// fitColumn returns line, without its line break and tabs expanded, truncated or padded to width.
+func fitColumn(line string, width int) string {
+	runes := []rune(strings.ReplaceAll(strings.TrimSuffix(line, "\n"), "\t", "    "))
+	if len(runes) > width {
+		runes = append(runes[:width-1], '…')
+	}
+	return string(runes) + strings.Repeat(" ", width-len(runes))
+}
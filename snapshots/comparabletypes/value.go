@@ -0,0 +1,538 @@
+package comparabletypes
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"perri.to/expect/snapshots"
+)
+
+var _ snapshots.Comparable = (*Value)(nil)
+
+// Value holds a go value which is rendered deterministically: map keys are sorted, pointers are followed
+// rather than printed as addresses and cycles are detected. The rendering puts a field per line, so values
+// are compared field by field.
+type Value struct {
+	withRenderer
+	value interface{}
+	// rendered is set when we were loaded from a snapshot, there is no value then.
+	rendered   *string
+	unexported bool
+	formatters map[reflect.Type]func(interface{}) string
+	replacers  map[string]string
+}
+
+// NewValueComparable constructs a Value comparable from any go value. Unexported fields are skipped unless
+// IncludeUnexported is called, so types that only have those, like time.Time, render as an empty time.Time{}, use
+// RegisterFormatter to render them instead.
+func NewValueComparable(v interface{}) *Value {
+	return &Value{value: v, formatters: map[reflect.Type]func(interface{}) string{}}
+}
+
+// IncludeUnexported makes the rendering include unexported struct fields, which are skipped by default.
+func (v *Value) IncludeUnexported() {
+	v.unexported = true
+}
+
+// RegisterFormatter will render values of type t with f instead of walking them, ie: to print a time.Time
+// as a date. The returned string should fit in a line.
+func (v *Value) RegisterFormatter(t reflect.Type, f func(interface{}) string) {
+	v.formatters[t] = f
+}
+
+func (v *Value) Subtypes() bool {
+	return false
+}
+
+func (v *Value) ReplaceSubtypes(_ map[snapshots.Kind]map[string]string) {
+	return
+}
+
+func (v *Value) CompareTo(c snapshots.Comparable) (string, error) {
+	other, isValue := c.(*Value)
+	if !isValue {
+		return "", snapshots.CantCompare(fmt.Sprintf("%T", v), fmt.Sprintf("%T", c))
+	}
+	expected, got := v.render(), other.render()
+	if expected == got {
+		return "", nil
+	}
+	expectedFields, expectedOrder := flattenRenderedValue(expected)
+	gotFields, gotOrder := flattenRenderedValue(got)
+
+	var result strings.Builder
+	for _, path := range expectedOrder {
+		gotValue, ok := gotFields[path]
+		switch {
+		case !ok:
+			result.WriteString(fmt.Sprintf("%s: is expected but not present, expected value %s\n", path, expectedFields[path]))
+		case gotValue != expectedFields[path]:
+			result.WriteString(fmt.Sprintf("%s: expected %s but got %s\n", path, expectedFields[path], gotValue))
+		}
+	}
+	for _, path := range gotOrder {
+		if _, ok := expectedFields[path]; !ok {
+			result.WriteString(fmt.Sprintf("%s: is not expected but present, with value %s\n", path, gotFields[path]))
+		}
+	}
+	return v.escape(result.String()), nil
+}
+
+func (v *Value) String() string {
+	return v.render()
+}
+
+const KindValue snapshots.Kind = "value"
+
+func (v *Value) Kind() snapshots.Kind {
+	return KindValue
+}
+
+func (v *Value) Dump() []byte {
+	return []byte(v.render())
+}
+
+func (v *Value) Load(rendered []byte) snapshots.Comparable {
+	r := string(rendered)
+	return &Value{withRenderer: v.withRenderer, rendered: &r, unexported: v.unexported, formatters: v.formatters,
+		replacers: v.replacers}
+}
+
+// Replace takes field paths, as printed in the differences (ie: `Servers[1].Port` or `Labels["env"]`), and
+// renders the passed value instead of the field one, a `[*]` matches any index or key.
+func (v *Value) Replace(rs map[string]string) {
+	v.replacers = rs
+}
+
+func (v *Value) Extension() string {
+	return "txt"
+}
+
+func (v *Value) render() string {
+	var rendered string
+	if v.rendered != nil {
+		rendered = *v.rendered
+	} else {
+		r := valueRenderer{
+			unexported: v.unexported,
+			formatters: v.formatters,
+			visiting:   map[visit]bool{},
+		}
+		var b strings.Builder
+		r.render(&b, reflect.ValueOf(v.value), 0)
+		b.WriteString("\n")
+		rendered = b.String()
+	}
+	if len(v.replacers) == 0 {
+		return rendered
+	}
+	return replaceRenderedValue(rendered, v.replacers)
+}
+
+// valueRenderer walks a value writing a line per field, indented by depth, containers open with their type
+// and a { and are closed by a } in its own line:
+//
+//	main.Config{
+//	  Name: "server"
+//	  Ports: []int{
+//	    [0]: 80
+//	  }
+//	}
+//
+// Map keys are rendered inline, in a single line, ie: [main.Key{Name: "a", Ports: []int{[0]: 80}}].
+type valueRenderer struct {
+	unexported bool
+	formatters map[reflect.Type]func(interface{}) string
+	// visiting holds the pointers, maps and slices we are inside of, to detect cycles.
+	visiting map[visit]bool
+	inline   bool
+}
+
+// visit is a pointer being rendered, the type tells a struct apart from its first field, which share it.
+type visit struct {
+	pointer uintptr
+	typ     reflect.Type
+}
+
+// enter marks v, a pointer, map or slice, as being rendered, if it already was it writes a cycle mark and returns
+// false instead.
+func (r *valueRenderer) enter(b *strings.Builder, v reflect.Value) bool {
+	key := visit{pointer: v.Pointer(), typ: v.Type()}
+	if r.visiting[key] {
+		b.WriteString(fmt.Sprintf("<cycle to %s>", v.Type()))
+		return false
+	}
+	r.visiting[key] = true
+	return true
+}
+
+func (r *valueRenderer) leave(v reflect.Value) {
+	delete(r.visiting, visit{pointer: v.Pointer(), typ: v.Type()})
+}
+
+// open, item and close write the parts of a container, whose item n is named name.
+func (r *valueRenderer) open(b *strings.Builder, v reflect.Value) {
+	b.WriteString(v.Type().String())
+	if r.inline {
+		b.WriteString("{")
+		return
+	}
+	b.WriteString("{\n")
+}
+
+func (r *valueRenderer) item(b *strings.Builder, n int, name string, depth int) {
+	switch {
+	case !r.inline:
+		if n > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(strings.Repeat("  ", depth+1))
+	case n > 0:
+		b.WriteString(", ")
+	}
+	b.WriteString(name)
+	b.WriteString(": ")
+}
+
+func (r *valueRenderer) close(b *strings.Builder, n int, depth int) {
+	if r.inline {
+		b.WriteString("}")
+		return
+	}
+	if n > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString(strings.Repeat("  ", depth) + "}")
+}
+
+func (r *valueRenderer) render(b *strings.Builder, v reflect.Value, depth int) {
+	if !v.IsValid() {
+		b.WriteString("nil")
+		return
+	}
+	if f, ok := r.formatters[v.Type()]; ok && v.CanInterface() {
+		b.WriteString(f(v.Interface()))
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+		if !r.enter(b, v) {
+			return
+		}
+		defer r.leave(v)
+		b.WriteString("&")
+		r.render(b, v.Elem(), depth)
+	case reflect.Interface:
+		if v.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+		// the concrete value is checked for cycles, an interface can hold a slice or map that contains it.
+		r.render(b, v.Elem(), depth)
+	case reflect.Struct:
+		r.open(b, v)
+		n := 0
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() && !r.unexported {
+				continue
+			}
+			r.item(b, n, field.Name, depth)
+			r.render(b, v.Field(i), depth+1)
+			n++
+		}
+		r.close(b, n, depth)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// byte slices are more readable as a quoted string.
+			b.WriteString(fmt.Sprintf("%s(%q)", v.Type(), valueBytes(v)))
+			return
+		}
+		// a slice can hold itself through an interface, arrays are copied so they can not.
+		if v.Kind() == reflect.Slice && v.Len() > 0 {
+			if !r.enter(b, v) {
+				return
+			}
+			defer r.leave(v)
+		}
+		r.open(b, v)
+		for i := 0; i < v.Len(); i++ {
+			r.item(b, i, fmt.Sprintf("[%d]", i), depth)
+			r.render(b, v.Index(i), depth+1)
+		}
+		r.close(b, v.Len(), depth)
+	case reflect.Map:
+		if v.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+		if !r.enter(b, v) {
+			return
+		}
+		defer r.leave(v)
+		r.open(b, v)
+		type entry struct {
+			key   string
+			value reflect.Value
+		}
+		entries := make([]entry, 0, v.Len())
+		// keys must fit in the line of their entry.
+		keys := *r
+		keys.inline = true
+		iter := v.MapRange()
+		for iter.Next() {
+			var kb strings.Builder
+			keys.render(&kb, iter.Key(), depth+1)
+			entries = append(entries, entry{key: kb.String(), value: iter.Value()})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+		for i, e := range entries {
+			r.item(b, i, "["+e.key+"]", depth)
+			r.render(b, e.value, depth+1)
+		}
+		r.close(b, len(entries), depth)
+	case reflect.String:
+		b.WriteString(strconv.Quote(v.String()))
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		if v.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+		// addresses change between runs, all we can tell is that there is something.
+		b.WriteString(fmt.Sprintf("%s(...)", v.Type()))
+	default:
+		// bool, numbers and complex numbers print the same with or without access to unexported fields.
+		b.WriteString(fmt.Sprint(valueScalar(v)))
+	}
+}
+
+func valueBytes(v reflect.Value) []byte {
+	bs := make([]byte, v.Len())
+	for i := range bs {
+		bs[i] = byte(v.Index(i).Uint())
+	}
+	return bs
+}
+
+func valueScalar(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Complex64, reflect.Complex128:
+		return v.Complex()
+	}
+	return v.String()
+}
+
+// renderedLine is a line of a rendered value, split in its parts.
+type renderedLine struct {
+	indent string
+	// name is the field name, [index] or [key] of the line, empty for the first and closing lines.
+	name  string
+	value string
+	opens bool
+}
+
+func parseRenderedLine(line string) renderedLine {
+	trimmed := strings.TrimLeft(line, " ")
+	l := renderedLine{indent: line[:len(line)-len(trimmed)], value: trimmed}
+	if trimmed == "}" {
+		return l
+	}
+	if sep := renderedNameEnd(trimmed); sep != -1 {
+		l.name = trimmed[:sep]
+		l.value = trimmed[sep+2:]
+	}
+	l.opens = strings.HasSuffix(l.value, "{")
+	return l
+}
+
+// renderedNameEnd returns where the name of a rendered line ends, that is the first ": " not inside a map key.
+func renderedNameEnd(line string) int {
+	inQuotes := false
+	nesting := 0
+	for i := 0; i < len(line)-1; i++ {
+		switch {
+		case line[i] == '\\' && inQuotes:
+			i++
+		case line[i] == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case line[i] == '[' || line[i] == '{':
+			nesting++
+		case line[i] == ']' || line[i] == '}':
+			nesting--
+		case line[i] == ':' && line[i+1] == ' ' && nesting == 0:
+			return i
+		}
+	}
+	return -1
+}
+
+func joinRenderedPath(parent, name string) string {
+	if parent == "" || strings.HasPrefix(name, "[") {
+		return parent + name
+	}
+	return parent + "." + name
+}
+
+// flattenRenderedValue turns a rendered value into a map of field path to its value, the order of the paths
+// is also returned.
+func flattenRenderedValue(rendered string) (map[string]string, []string) {
+	fields := map[string]string{}
+	var order []string
+	var stack []string
+	for _, line := range strings.Split(strings.TrimSuffix(rendered, "\n"), "\n") {
+		l := parseRenderedLine(line)
+		if l.value == "}" && l.name == "" {
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+		parent := ""
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1]
+		}
+		path := joinRenderedPath(parent, l.name)
+		if path == "" {
+			path = "."
+		}
+		fields[path] = l.value
+		order = append(order, path)
+		if l.opens {
+			if path == "." {
+				path = ""
+			}
+			stack = append(stack, path)
+		}
+	}
+	return fields, order
+}
+
+// replaceRenderedValue renders the replacement instead of the value of every field whose path matches one of
+// the replacers, if the field held a container its contents are dropped.
+func replaceRenderedValue(rendered string, replacers map[string]string) string {
+	var b strings.Builder
+	// stack holds the paths of the open containers.
+	var stack []string
+	// skipDepth, when not -1, is the depth of the stack at which a replaced container was opened.
+	skipDepth := -1
+	for _, line := range strings.Split(strings.TrimSuffix(rendered, "\n"), "\n") {
+		l := parseRenderedLine(line)
+		closes := l.value == "}" && l.name == ""
+		if skipDepth != -1 {
+			if l.opens {
+				stack = append(stack, "")
+			}
+			if closes {
+				stack = stack[:len(stack)-1]
+				if len(stack) == skipDepth {
+					skipDepth = -1
+				}
+			}
+			continue
+		}
+		if closes {
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			b.WriteString(line)
+			b.WriteString("\n")
+			continue
+		}
+		parent := ""
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1]
+		}
+		path := joinRenderedPath(parent, l.name)
+		if replacement, ok := matchRenderedPath(path, replacers); ok && l.name != "" {
+			b.WriteString(fmt.Sprintf("%s%s: %s\n", l.indent, l.name, replacement))
+			if l.opens {
+				skipDepth = len(stack)
+				stack = append(stack, path)
+			}
+			continue
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+		if l.opens {
+			stack = append(stack, path)
+		}
+	}
+	return b.String()
+}
+
+func matchRenderedPath(path string, replacers map[string]string) (string, bool) {
+	if replacement, ok := replacers[path]; ok {
+		return replacement, true
+	}
+	for pattern, replacement := range replacers {
+		if !strings.Contains(pattern, "[*]") {
+			continue
+		}
+		if renderedPathMatches(pattern, path) {
+			return replacement, true
+		}
+	}
+	return "", false
+}
+
+// renderedPathMatches tells if path matches pattern, where [*] stands for any index or key.
+func renderedPathMatches(pattern, path string) bool {
+	for {
+		star := strings.Index(pattern, "[*]")
+		if star == -1 {
+			return pattern == path
+		}
+		if !strings.HasPrefix(path, pattern[:star+1]) {
+			return false
+		}
+		path = path[star+1:]
+		end := renderedKeyEnd(path)
+		if end == -1 {
+			return false
+		}
+		path = path[end:]
+		pattern = pattern[star+2:]
+	}
+}
+
+// renderedKeyEnd returns the position of the ] closing the key or index at the start of s.
+func renderedKeyEnd(s string) int {
+	inQuotes := false
+	nesting := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && inQuotes:
+			i++
+		case s[i] == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case s[i] == '[':
+			nesting++
+		case s[i] == ']' && nesting == 0:
+			return i
+		case s[i] == ']':
+			nesting--
+		}
+	}
+	return -1
+}
@@ -0,0 +1,214 @@
+package comparabletypes
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sampleServer struct {
+	Host    string
+	Port    int
+	Started time.Time
+}
+
+type sampleConfig struct {
+	Name    string
+	Servers []*sampleServer
+	Labels  map[string]string
+	Parent  *sampleConfig
+	Raw     []byte
+	secret  string
+}
+
+func newSampleConfig() *sampleConfig {
+	c := &sampleConfig{
+		Name: "cluster",
+		Servers: []*sampleServer{
+			{Host: "a.example.com", Port: 80, Started: time.Date(2022, 5, 2, 22, 19, 45, 0, time.UTC)},
+			{Host: "b.example.com", Port: 443, Started: time.Date(2022, 5, 2, 22, 19, 46, 0, time.UTC)},
+		},
+		Labels: map[string]string{"env": "prod", "app": "web", "tier": "front"},
+		Raw:    []byte("raw"),
+		secret: "hunter2",
+	}
+	c.Parent = c
+	return c
+}
+
+func newSampleValue(c *sampleConfig) *Value {
+	v := NewValueComparable(c)
+	v.RegisterFormatter(reflect.TypeOf(time.Time{}), func(t interface{}) string {
+		return t.(time.Time).Format(time.RFC3339)
+	})
+	return v
+}
+
+func TestValue_Dump(t *testing.T) {
+	const want = `&comparabletypes.sampleConfig{
+  Name: "cluster"
+  Servers: []*comparabletypes.sampleServer{
+    [0]: &comparabletypes.sampleServer{
+      Host: "a.example.com"
+      Port: 80
+      Started: 2022-05-02T22:19:45Z
+    }
+    [1]: &comparabletypes.sampleServer{
+      Host: "b.example.com"
+      Port: 443
+      Started: 2022-05-02T22:19:46Z
+    }
+  }
+  Labels: map[string]string{
+    ["app"]: "web"
+    ["env"]: "prod"
+    ["tier"]: "front"
+  }
+  Parent: <cycle to *comparabletypes.sampleConfig>
+  Raw: []uint8("raw")
+}
+`
+	// rendering many times shows there is no map order involved.
+	for i := 0; i < 10; i++ {
+		if got := string(newSampleValue(newSampleConfig()).Dump()); got != want {
+			t.Fatalf("Dump() got = \n%s\n, want \n%s", got, want)
+		}
+	}
+
+	v := newSampleValue(newSampleConfig())
+	v.IncludeUnexported()
+	if got, want := string(v.Dump()), "  Raw: []uint8(\"raw\")\n  secret: \"hunter2\"\n}\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("Dump() with unexported got = \n%s\n, want it to end with \n%s", got, want)
+	}
+}
+
+func TestValue_CompareTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(c *sampleConfig)
+		replacer map[string]string
+		want     string
+	}{
+		{
+			name:   "equal",
+			change: func(c *sampleConfig) {},
+		},
+		{
+			name: "different",
+			change: func(c *sampleConfig) {
+				c.Servers[1].Port = 8443
+				c.Servers = append(c.Servers, &sampleServer{Host: "c.example.com"})
+				delete(c.Labels, "tier")
+			},
+			want: `Servers[1].Port: expected 443 but got 8443
+Labels["tier"]: is expected but not present, expected value "front"
+Servers[2]: is not expected but present, with value &comparabletypes.sampleServer{
+Servers[2].Host: is not expected but present, with value "c.example.com"
+Servers[2].Port: is not expected but present, with value 0
+Servers[2].Started: is not expected but present, with value 0001-01-01T00:00:00Z
+`,
+		},
+		{
+			name: "replaced",
+			change: func(c *sampleConfig) {
+				c.Servers[0].Started = time.Now()
+				c.Servers[1].Started = time.Now()
+				c.Labels["env"] = "staging"
+			},
+			replacer: map[string]string{"Servers[*].Started": "<time>", `Labels["env"]`: "<env>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newSampleValue(newSampleConfig())
+			changed := newSampleConfig()
+			tt.change(changed)
+			other := newSampleValue(changed)
+			if tt.replacer != nil {
+				v.Replace(tt.replacer)
+				other.Replace(tt.replacer)
+			}
+			// go through a dump and load, as a snapshot would.
+			got, err := other.Load(v.Dump()).CompareTo(other)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("CompareTo() got = \n%s\n, want \n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValue_DumpCycles(t *testing.T) {
+	s := make([]interface{}, 1)
+	s[0] = s
+	m := map[string]interface{}{}
+	m["self"] = m
+	const want = `[]interface {}{
+  [0]: <cycle to []interface {}>
+}
+map[string]interface {}{
+  ["self"]: <cycle to map[string]interface {}>
+}
+`
+	if got := string(NewValueComparable(s).Dump()) + string(NewValueComparable(m).Dump()); got != want {
+		t.Errorf("Dump() got = \n%s\n, want \n%s", got, want)
+	}
+}
+
+type sampleKey struct {
+	Host  string
+	Ports [2]int
+}
+
+func TestValue_CompositeMapKeys(t *testing.T) {
+	weights := func(weight int) *Value {
+		return NewValueComparable(map[sampleKey]int{
+			{Host: "a.example.com", Ports: [2]int{80}}:      1,
+			{Host: "b.example.com", Ports: [2]int{80, 443}}: weight,
+		})
+	}
+	const wantDump = `map[comparabletypes.sampleKey]int{
+  [comparabletypes.sampleKey{Host: "a.example.com", Ports: [2]int{[0]: 80, [1]: 0}}]: 1
+  [comparabletypes.sampleKey{Host: "b.example.com", Ports: [2]int{[0]: 80, [1]: 443}}]: 2
+}
+`
+	if got := string(weights(2).Dump()); got != wantDump {
+		t.Errorf("Dump() got = \n%s\n, want \n%s", got, wantDump)
+	}
+	const wantDiff = `[comparabletypes.sampleKey{Host: "b.example.com", Ports: [2]int{[0]: 80, [1]: 443}}]: expected 2 but got 3
+`
+	got, err := weights(2).CompareTo(weights(3))
+	if err != nil {
+		t.Fatalf("CompareTo() error = %v", err)
+	}
+	if got != wantDiff {
+		t.Errorf("CompareTo() got = \n%s\n, want \n%s", got, wantDiff)
+	}
+	expected, other := weights(2), weights(3)
+	expected.Replace(map[string]string{"[*]": "<weight>"})
+	other.Replace(map[string]string{"[*]": "<weight>"})
+	if got, err := expected.CompareTo(other); err != nil || got != "" {
+		t.Errorf("CompareTo() with replacers got = \n%s\n, error = %v, want no difference", got, err)
+	}
+}
+
+func TestValue_LoadKeepsReplacers(t *testing.T) {
+	started := func(d time.Duration) *Value {
+		v := NewValueComparable(sampleServer{Host: "a.example.com", Port: 80,
+			Started: time.Date(2022, 5, 2, 22, 19, 45, 0, time.UTC).Add(d)})
+		v.RegisterFormatter(reflect.TypeOf(time.Time{}), func(t interface{}) string {
+			return t.(time.Time).Format(time.RFC3339)
+		})
+		return v
+	}
+	// the snapshot was taken before the replacers were set, they apply to it once loaded.
+	snapshot := started(0).Dump()
+	result := started(time.Hour)
+	result.Replace(map[string]string{"Started": "<time>"})
+	if got, err := result.Load(snapshot).CompareTo(result); err != nil || got != "" {
+		t.Errorf("CompareTo() got = \n%s\n, error = %v, want no difference", got, err)
+	}
+}
@@ -0,0 +1,378 @@
+package comparabletypes
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"perri.to/expect/snapshots"
+)
+
+var _ snapshots.Comparable = (*XML)(nil)
+
+// XML holds an XML document which is compared structurally, ignoring insignificant whitespace, comments and
+// the order of attributes.
+type XML struct {
+	withRenderer
+	rawXML []byte
+}
+
+// NewXMLFromString constructs an XML comparable from a string.
+func NewXMLFromString(s string) snapshots.Comparable {
+	x := XML{rawXML: []byte(s)}
+	return &x
+}
+
+// NewXMLFromBytes constructs an XML comparable from a byte slice.
+func NewXMLFromBytes(b []byte) snapshots.Comparable {
+	x := XML{rawXML: b}
+	return &x
+}
+
+func (x *XML) Subtypes() bool {
+	return false
+}
+
+func (x *XML) ReplaceSubtypes(_ map[snapshots.Kind]map[string]string) {
+	return
+}
+
+func (x *XML) CompareTo(c snapshots.Comparable) (string, error) {
+	newXML, isXML := c.(*XML)
+	if !isXML {
+		return "", snapshots.CantCompare(fmt.Sprintf("%T", x), fmt.Sprintf("%T", c))
+	}
+	if bytes.Equal(x.rawXML, newXML.rawXML) {
+		return "", nil
+	}
+	expected, sourceErr := parseXML(x.rawXML)
+	got, targetErr := parseXML(newXML.rawXML)
+	switch {
+	case sourceErr != nil && targetErr != nil:
+		return "", snapshots.BothPartsInvalid(fmt.Sprintf("%T", x), fmt.Sprintf("%T", c), x.Kind())
+	case sourceErr != nil:
+		return "", snapshots.InvalidSource(fmt.Sprintf("%T", x), x.Kind())
+	case targetErr != nil:
+		return "", snapshots.InvalidTarget(fmt.Sprintf("%T", c), c.Kind())
+	}
+	return x.escape(compareMarkup(expected, got)), nil
+}
+
+func (x *XML) String() string {
+	return string(x.rawXML)
+}
+
+const KindXML snapshots.Kind = "xml"
+
+func (x *XML) Kind() snapshots.Kind {
+	return KindXML
+}
+
+// Dump returns the document in a normalized form, if it can't be parsed it is returned as is.
+func (x *XML) Dump() []byte {
+	doc, err := parseXML(x.rawXML)
+	if err != nil {
+		return x.rawXML
+	}
+	return []byte(xmlRenderer.render(doc))
+}
+
+func (x *XML) Load(rawXML []byte) snapshots.Comparable {
+	return &XML{withRenderer: x.withRenderer, rawXML: rawXML}
+}
+
+// Replace takes XPath expressions as keys and sets the text of the selected elements, or the value of the
+// selected attributes, to the corresponding values, leaving the document in its normalized form.
+func (x *XML) Replace(rs map[string]string) {
+	if len(rs) == 0 {
+		return
+	}
+	doc, err := parseXML(x.rawXML)
+	if err != nil {
+		// we will complain about this when comparing.
+		return
+	}
+	if err := applyMarkupReplacers(doc, rs, selectXPath); err != nil {
+		panic(err)
+	}
+	x.rawXML = []byte(xmlRenderer.render(doc))
+}
+
+func (x *XML) Extension() string {
+	return "xml"
+}
+
+var xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+var xmlAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+var xmlRenderer = &markupRenderer{
+	escapeText:  xmlTextEscaper.Replace,
+	escapeAttr:  xmlAttrEscaper.Replace,
+	selfClosing: func(_ *markupNode) string { return "/>" },
+	raw:         func(_ *markupNode) bool { return false },
+	preserve:    func(_ *markupNode) bool { return false },
+}
+
+func xmlName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+// parseXML builds a markup tree from an XML document, namespace prefixes are kept as written.
+func parseXML(raw []byte) (*markupNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(raw))
+	doc := newMarkupDocument()
+	current := doc
+	for {
+		tok, err := dec.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing xml: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &markupNode{name: xmlName(t.Name)}
+			for _, a := range t.Attr {
+				n.attrs = append(n.attrs, markupAttr{name: xmlName(a.Name), value: a.Value})
+			}
+			n.sortAttrs()
+			current.appendChild(n)
+			current = n
+		case xml.EndElement:
+			if current == doc || current.name != xmlName(t.Name) {
+				return nil, fmt.Errorf("parsing xml: unexpected closing element %s", xmlName(t.Name))
+			}
+			current = current.parent
+		case xml.CharData:
+			text := strings.TrimSpace(string(t))
+			if current == doc && text != "" {
+				return nil, fmt.Errorf("parsing xml: text outside of the root element")
+			}
+			current.appendText(text)
+		}
+	}
+	if current != doc {
+		return nil, fmt.Errorf("parsing xml: element %s is not closed", current.name)
+	}
+	if len(doc.elements()) != 1 {
+		return nil, fmt.Errorf("parsing xml: expected one root element but found %d", len(doc.elements()))
+	}
+	return doc, nil
+}
+
+// xpathStep is one of the location steps of the XPath subset we support.
+type xpathStep struct {
+	// descendant is true when the step was preceded by //
+	descendant bool
+	// test is an element name, *, @attribute or text()
+	test       string
+	predicates []xpathPredicate
+}
+
+// xpathPredicate is either a position (1 based, -1 for last()) or an attribute test.
+type xpathPredicate struct {
+	position int
+	attr     string
+	value    *string
+}
+
+// parseXPath parses the XPath subset we support: absolute (/a/b) and descendant (//b) location paths of
+// element names or *, with positional ([2], [last()]) and attribute ([@id], [@id='x']) predicates, optionally
+// ending in @attribute or text(). Relative paths are taken as descendant ones.
+func parseXPath(expr string) ([]xpathStep, error) {
+	if !strings.HasPrefix(expr, "/") {
+		expr = "//" + expr
+	}
+	var steps []xpathStep
+	for i := 0; i < len(expr); {
+		if expr[i] != '/' {
+			return nil, fmt.Errorf("expected / at position %d", i)
+		}
+		step := xpathStep{}
+		i++
+		if i < len(expr) && expr[i] == '/' {
+			step.descendant = true
+			i++
+		}
+		start := i
+		depth := 0
+		var quote byte
+		for ; i < len(expr); i++ {
+			ch := expr[i]
+			switch {
+			case quote != 0:
+				if ch == quote {
+					quote = 0
+				}
+				continue
+			case ch == '\'' || ch == '"':
+				quote = ch
+				continue
+			case ch == '[':
+				depth++
+				continue
+			case ch == ']':
+				depth--
+				continue
+			}
+			if ch == '/' && depth == 0 {
+				break
+			}
+		}
+		raw := expr[start:i]
+		test := raw
+		if p := strings.IndexByte(raw, '['); p != -1 {
+			test = raw[:p]
+			predicates, err := parseXPathPredicates(raw[p:])
+			if err != nil {
+				return nil, err
+			}
+			step.predicates = predicates
+		}
+		if test == "" {
+			return nil, fmt.Errorf("empty step at position %d", start)
+		}
+		step.test = test
+		steps = append(steps, step)
+	}
+	for i, step := range steps {
+		if (strings.HasPrefix(step.test, "@") || step.test == "text()") && i != len(steps)-1 {
+			return nil, fmt.Errorf("%s can only be the last step", step.test)
+		}
+	}
+	return steps, nil
+}
+
+func parseXPathPredicates(raw string) ([]xpathPredicate, error) {
+	var predicates []xpathPredicate
+	for raw != "" {
+		if raw[0] != '[' {
+			return nil, fmt.Errorf("malformed predicate %q", raw)
+		}
+		end := strings.IndexByte(raw, ']')
+		if end == -1 {
+			return nil, fmt.Errorf("unclosed predicate %q", raw)
+		}
+		body := strings.TrimSpace(raw[1:end])
+		raw = raw[end+1:]
+		switch {
+		case body == "last()":
+			predicates = append(predicates, xpathPredicate{position: -1})
+		case strings.HasPrefix(body, "@"):
+			p := xpathPredicate{attr: body[1:]}
+			if eq := strings.IndexByte(body, '='); eq != -1 {
+				p.attr = strings.TrimSpace(body[1:eq])
+				value := strings.TrimSpace(body[eq+1:])
+				value = strings.Trim(value, `'"`)
+				p.value = &value
+			}
+			predicates = append(predicates, p)
+		default:
+			position, err := strconv.Atoi(body)
+			if err != nil || position < 1 {
+				return nil, fmt.Errorf("unsupported predicate %q", body)
+			}
+			predicates = append(predicates, xpathPredicate{position: position})
+		}
+	}
+	return predicates, nil
+}
+
+func (p xpathPredicate) filter(nodes []*markupNode) []*markupNode {
+	switch {
+	case p.position == -1:
+		return nodes[len(nodes)-1:]
+	case p.position > 0:
+		if p.position > len(nodes) {
+			return nil
+		}
+		return nodes[p.position-1 : p.position]
+	}
+	var filtered []*markupNode
+	for _, n := range nodes {
+		v, ok := n.attr(p.attr)
+		if ok && (p.value == nil || *p.value == v) {
+			filtered = append(filtered, n)
+		}
+	}
+	return filtered
+}
+
+// selectXPath returns the nodes selected by expr and, if it selects attributes, the attribute name.
+func selectXPath(doc *markupNode, expr string) ([]*markupNode, string, error) {
+	steps, err := parseXPath(expr)
+	if err != nil {
+		return nil, "", err
+	}
+	context := []*markupNode{doc}
+	for _, step := range steps {
+		var candidates []*markupNode
+		for _, n := range context {
+			if step.descendant {
+				walkMarkup(n, func(d *markupNode) { candidates = append(candidates, d) })
+				continue
+			}
+			candidates = append(candidates, n)
+		}
+		switch {
+		case strings.HasPrefix(step.test, "@"):
+			attr := step.test[1:]
+			var selected []*markupNode
+			for _, n := range uniqueMarkupNodes(candidates) {
+				if _, ok := n.attr(attr); ok {
+					selected = append(selected, n)
+				}
+			}
+			return selected, attr, nil
+		case step.test == "text()":
+			var selected []*markupNode
+			for _, n := range uniqueMarkupNodes(candidates) {
+				for _, c := range n.children {
+					if c.isText {
+						selected = append(selected, c)
+					}
+				}
+			}
+			return selected, "", nil
+		}
+		var next []*markupNode
+		for _, parent := range uniqueMarkupNodes(candidates) {
+			var matching []*markupNode
+			for _, c := range parent.elements() {
+				if step.test == "*" || step.test == c.name {
+					matching = append(matching, c)
+				}
+			}
+			for _, p := range step.predicates {
+				if len(matching) == 0 {
+					break
+				}
+				matching = p.filter(matching)
+			}
+			next = append(next, matching...)
+		}
+		context = uniqueMarkupNodes(next)
+	}
+	return context, "", nil
+}
+
+func uniqueMarkupNodes(nodes []*markupNode) []*markupNode {
+	seen := make(map[*markupNode]bool, len(nodes))
+	unique := make([]*markupNode, 0, len(nodes))
+	for _, n := range nodes {
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		unique = append(unique, n)
+	}
+	return unique
+}
@@ -0,0 +1,147 @@
+package comparabletypes
+
+import (
+	"testing"
+
+	"perri.to/expect/snapshots"
+)
+
+const envelopeXML = `<?xml version="1.0" encoding="UTF-8"?>
+<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope">
+  <soap:Body>
+    <!-- the order -->
+    <order id="42" created="2022-05-02T22:19:45Z">
+      <item sku="a" qty="1">Apple</item>
+      <item sku="b" qty="2">Banana</item>
+    </order>
+  </soap:Body>
+</soap:Envelope>`
+
+func TestXML_CompareTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		other    string
+		replacer map[string]string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "whitespace and attribute order",
+			other: `<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope"><soap:Body>
+<order created="2022-05-02T22:19:45Z" id="42"><item qty="1" sku="a">
+  Apple
+</item><item qty="2" sku="b">Banana</item></order></soap:Body></soap:Envelope>`,
+		},
+		{
+			name: "replaced",
+			other: `<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope"><soap:Body>
+<order created="2022-06-01T00:00:00Z" id="43"><item qty="1" sku="a">Apple</item>
+<item qty="2" sku="b">Banana</item></order></soap:Body></soap:Envelope>`,
+			replacer: map[string]string{"//order/@created": "date", "/soap:Envelope/soap:Body/order/@id": "id"},
+		},
+		{
+			name: "different",
+			other: `<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope"><soap:Body>
+<order created="2022-05-02T22:19:45Z" id="42" rush="yes"><item qty="1" sku="a">Apple</item>
+<note>careful</note><item qty="3" sku="b">Cherry</item></order></soap:Body></soap:Envelope>`,
+			want: `/soap:Envelope/soap:Body/order: attribute rush is not expected but present, with value "yes"
+/soap:Envelope/soap:Body/order/note: element <note> is not expected but present
+/soap:Envelope/soap:Body/order/item[2]: attribute qty has value "3" but we expected "2"
+/soap:Envelope/soap:Body/order/item[2]/text(): expected text "Banana" but got "Cherry"
+`,
+		},
+		{
+			name:    "invalid",
+			other:   `<order><item></order>`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := NewXMLFromString(envelopeXML)
+			other := NewXMLFromString(tt.other)
+			if tt.replacer != nil {
+				x.Replace(tt.replacer)
+				other.Replace(tt.replacer)
+			}
+			got, err := x.CompareTo(other)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CompareTo() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("CompareTo() got = \n%s\n, want \n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXML_Dump(t *testing.T) {
+	const want = `<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope">
+  <soap:Body>
+    <order created="2022-05-02T22:19:45Z" id="42">
+      <item qty="1" sku="a">Apple</item>
+      <item qty="2" sku="b">Banana</item>
+    </order>
+  </soap:Body>
+</soap:Envelope>
+`
+	if got := string(NewXMLFromString(envelopeXML).Dump()); got != want {
+		t.Errorf("Dump() got = \n%s\n, want \n%s", got, want)
+	}
+}
+
+func Test_selectXPath(t *testing.T) {
+	doc, err := parseXML([]byte(`<a><b id="1"><c>x</c></b><b id="2"><c>y</c><c>z</c></b></a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		expr     string
+		want     []string
+		wantAttr string
+	}{
+		{expr: "/a/b", want: []string{"/a/b[1]", "/a/b[2]"}},
+		{expr: "/a/b[2]/c[last()]", want: []string{"/a/b[2]/c[2]"}},
+		{expr: "//c[1]", want: []string{"/a/b[1]/c", "/a/b[2]/c[1]"}},
+		{expr: "b[@id='2']/*", want: []string{"/a/b[2]/c[1]", "/a/b[2]/c[2]"}},
+		{expr: "/a/*/@id", want: []string{"/a/b[1]", "/a/b[2]"}, wantAttr: "id"},
+		{expr: "/a/b[1]/c/text()", want: []string{"/a/b[1]/c/text()"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			nodes, attr, err := selectXPath(doc, tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if attr != tt.wantAttr {
+				t.Errorf("selectXPath() attr = %q, want %q", attr, tt.wantAttr)
+			}
+			var got []string
+			for _, n := range nodes {
+				got = append(got, n.path())
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("selectXPath() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("selectXPath() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestXML_CompareToEscapesForHTMLTheme(t *testing.T) {
+	x := NewXMLFromString(`<a href="x">Fish &amp; chips</a>`)
+	x.(snapshots.Rendering).SetRenderer(snapshots.Renderer{Theme: snapshots.ThemeHTML})
+	got, err := x.Load(x.Dump()).CompareTo(NewXMLFromString(`<a href="y">Fish &amp; chips</a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "/a: attribute href has value &#34;y&#34; but we expected &#34;x&#34;\n"
+	if got != want {
+		t.Errorf("CompareTo() = %q, want %q", got, want)
+	}
+}
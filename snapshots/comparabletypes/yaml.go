@@ -0,0 +1,314 @@
+package comparabletypes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/nsf/jsondiff"
+	"gopkg.in/yaml.v3"
+
+	"perri.to/expect/snapshots"
+)
+
+var _ snapshots.Comparable = (*YAML)(nil)
+
+// YAML holds a, possibly multi document, YAML stream which is compared semantically, so key order,
+// formatting and comments are irrelevant.
+type YAML struct {
+	withRenderer
+	rawYAML []byte
+}
+
+// NewYAMLFromString constructs a YAML comparable from a string.
+func NewYAMLFromString(s string) snapshots.Comparable {
+	y := YAML{rawYAML: []byte(s)}
+	return &y
+}
+
+// NewYAMLFromBytes constructs a YAML comparable from a byte slice.
+func NewYAMLFromBytes(b []byte) snapshots.Comparable {
+	y := YAML{rawYAML: b}
+	return &y
+}
+
+func (y *YAML) Subtypes() bool {
+	return false
+}
+
+func (y *YAML) ReplaceSubtypes(_ map[snapshots.Kind]map[string]string) {
+	return
+}
+
+// documents decodes every document in the stream.
+func (y *YAML) documents() ([]interface{}, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(y.rawYAML))
+	var documents []interface{}
+	for {
+		var document interface{}
+		err := dec.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			return documents, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, normalizeYAMLValue(document))
+	}
+}
+
+// jsonDocuments returns the documents in the stream in their JSON form, which is what we compare.
+func (y *YAML) jsonDocuments() ([]json.RawMessage, error) {
+	documents, err := y.documents()
+	if err != nil {
+		return nil, err
+	}
+	jsonDocuments := make([]json.RawMessage, 0, len(documents))
+	for i, document := range documents {
+		m, err := json.Marshal(document)
+		if err != nil {
+			return nil, fmt.Errorf("converting document %d to json: %w", i+1, err)
+		}
+		jsonDocuments = append(jsonDocuments, m)
+	}
+	return jsonDocuments, nil
+}
+
+// normalizeYAMLValue turns the maps with non string keys that yaml might produce into something that can be
+// marshaled as JSON.
+func normalizeYAMLValue(v interface{}) interface{} {
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		for k, item := range tv {
+			tv[k] = normalizeYAMLValue(item)
+		}
+		return tv
+	case map[interface{}]interface{}:
+		normalized := make(map[string]interface{}, len(tv))
+		for k, item := range tv {
+			normalized[fmt.Sprint(k)] = normalizeYAMLValue(item)
+		}
+		return normalized
+	case []interface{}:
+		for i, item := range tv {
+			tv[i] = normalizeYAMLValue(item)
+		}
+		return tv
+	}
+	return v
+}
+
+func (y *YAML) CompareTo(c snapshots.Comparable) (string, error) {
+	newYAML, isYAML := c.(*YAML)
+	if !isYAML {
+		return "", snapshots.CantCompare(fmt.Sprintf("%T", y), fmt.Sprintf("%T", c))
+	}
+	if bytes.Equal(y.rawYAML, newYAML.rawYAML) {
+		return "", nil
+	}
+
+	expected, sourceErr := y.jsonDocuments()
+	got, targetErr := newYAML.jsonDocuments()
+	switch {
+	case sourceErr != nil && targetErr != nil:
+		return "", snapshots.BothPartsInvalid(fmt.Sprintf("%T", y), fmt.Sprintf("%T", c), y.Kind())
+	case sourceErr != nil:
+		return "", snapshots.InvalidSource(fmt.Sprintf("%T", y), y.Kind())
+	case targetErr != nil:
+		return "", snapshots.InvalidTarget(fmt.Sprintf("%T", c), c.Kind())
+	}
+
+	opts := jsondiffOptions(y.currentRenderer().Theme)
+	multiDocument := len(expected) > 1 || len(got) > 1
+	var result strings.Builder
+	if len(expected) != len(got) {
+		result.WriteString(fmt.Sprintf("Documents: expected %d documents but got %d\n", len(expected), len(got)))
+	}
+	for i := 0; i < len(expected) || i < len(got); i++ {
+		switch {
+		case i >= len(got):
+			result.WriteString(fmt.Sprintf("Document %d: is expected but not present\n", i+1))
+			continue
+		case i >= len(expected):
+			result.WriteString(fmt.Sprintf("Document %d: is not expected but present\n", i+1))
+			continue
+		}
+		difference, explanation := jsondiff.Compare(expected[i], got[i], &opts)
+		if difference == jsondiff.FullMatch {
+			continue
+		}
+		if multiDocument {
+			result.WriteString(fmt.Sprintf("Document %d:\n", i+1))
+		}
+		result.WriteString(explanation)
+		if multiDocument {
+			result.WriteString("\n")
+		}
+	}
+	return result.String(), nil
+}
+
+func (y *YAML) String() string {
+	return string(y.rawYAML)
+}
+
+const KindYAML snapshots.Kind = "yaml"
+
+func (y *YAML) Kind() snapshots.Kind {
+	return KindYAML
+}
+
+// Dump returns a normalized form of the stream, with sorted keys, consistent indentation and no comments, if
+// the stream can't be parsed it is returned as is.
+func (y *YAML) Dump() []byte {
+	documents, err := y.documents()
+	if err != nil {
+		return y.rawYAML
+	}
+	normalized, err := encodeYAMLDocuments(documents)
+	if err != nil {
+		return y.rawYAML
+	}
+	return normalized
+}
+
+func encodeYAMLDocuments(documents []interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	for _, document := range documents {
+		if err := enc.Encode(document); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (y *YAML) Load(rawYAML []byte) snapshots.Comparable {
+	return &YAML{withRenderer: y.withRenderer, rawYAML: rawYAML}
+}
+
+// Replace sets the passed paths, which follow the same syntax than the JSON ones, in every document of the
+// stream, leaving it in its normalized form. The values are set as strings and the rest of the documents keep
+// their types, ie: integers are not turned into floats.
+func (y *YAML) Replace(rs map[string]string) {
+	if len(rs) == 0 {
+		return
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(y.rawYAML))
+	var replaced []interface{}
+	for {
+		var document yaml.Node
+		err := dec.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// we will complain about this when comparing.
+			return
+		}
+		for k, v := range rs {
+			if err := setYAMLPath(&document, splitYAMLPath(k), v); err != nil {
+				panic(fmt.Errorf("replacing %s: %w", k, err))
+			}
+		}
+		var r interface{}
+		if err := document.Decode(&r); err != nil {
+			panic(err)
+		}
+		replaced = append(replaced, normalizeYAMLValue(r))
+	}
+	normalized, err := encodeYAMLDocuments(replaced)
+	if err != nil {
+		panic(err)
+	}
+	y.rawYAML = normalized
+}
+
+// copyYAMLNode returns a deep copy of node, without its anchor.
+func copyYAMLNode(node *yaml.Node) *yaml.Node {
+	c := *node
+	c.Anchor = ""
+	c.Content = make([]*yaml.Node, len(node.Content))
+	for i, item := range node.Content {
+		c.Content[i] = copyYAMLNode(item)
+	}
+	return &c
+}
+
+// splitYAMLPath splits a JSON path in its keys, a backslash escapes the next character, ie: a dot in a key.
+func splitYAMLPath(path string) []string {
+	var keys []string
+	var key strings.Builder
+	escaped := false
+	for _, r := range path {
+		switch {
+		case escaped:
+			key.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '.':
+			keys = append(keys, key.String())
+			key.Reset()
+		default:
+			key.WriteRune(r)
+		}
+	}
+	return append(keys, key.String())
+}
+
+// setYAMLPath sets the node at path, under node, to a string holding value, creating what is missing along the
+// way as sjson does: mapping keys, sequence items (-1 appends one) and mappings in place of scalars.
+func setYAMLPath(node *yaml.Node, path []string, value string) error {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			node.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+		}
+		return setYAMLPath(node.Content[0], path, value)
+	}
+	if node.Kind == yaml.AliasNode {
+		// the anchored node is shared, setting it would replace it everywhere.
+		*node = *copyYAMLNode(node.Alias)
+	}
+	if len(path) == 0 {
+		*node = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+		return nil
+	}
+	key, rest := path[0], path[1:]
+	if node.Kind == yaml.SequenceNode {
+		i, err := strconv.Atoi(key)
+		if err != nil || i < -1 {
+			return fmt.Errorf("%q is not an index of the sequence", key)
+		}
+		if i == -1 {
+			i = len(node.Content)
+		}
+		for len(node.Content) <= i {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"})
+		}
+		return setYAMLPath(node.Content[i], rest, value)
+	}
+	if node.Kind != yaml.MappingNode {
+		*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return setYAMLPath(node.Content[i+1], rest, value)
+		}
+	}
+	item := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, item)
+	return setYAMLPath(item, rest, value)
+}
+
+func (y *YAML) Extension() string {
+	return "yaml"
+}
@@ -0,0 +1,160 @@
+package comparabletypes
+
+import (
+	"fmt"
+	"testing"
+)
+
+const deploymentYAML = `# the app
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  creationTimestamp: "2022-05-02T22:19:45Z"
+spec:
+  replicas: 3
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+  creationTimestamp: "2022-05-02T22:19:46Z"
+`
+
+func TestYAML_CompareTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		other    string
+		replacer map[string]string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "reordered and commented",
+			other: `apiVersion: apps/v1
+kind: Deployment
+spec: {replicas: 3}
+metadata:
+    creationTimestamp: "2022-05-02T22:19:45Z"
+    name: app # still the app
+---
+kind: Service
+apiVersion: v1
+metadata: {name: app, creationTimestamp: "2022-05-02T22:19:46Z"}
+`,
+			want: `""`,
+		},
+		{
+			name: "replaced",
+			other: `apiVersion: apps/v1
+kind: Deployment
+metadata: {name: app, creationTimestamp: "2022-06-01T00:00:00Z"}
+spec: {replicas: 3}
+---
+apiVersion: v1
+kind: Service
+metadata: {name: app, creationTimestamp: "2022-06-01T00:00:01Z"}
+`,
+			replacer: map[string]string{"metadata.creationTimestamp": "replaced"},
+			want:     `""`,
+		},
+		{
+			name: "different",
+			other: `apiVersion: apps/v1
+kind: Deployment
+metadata: {name: app, creationTimestamp: "2022-05-02T22:19:45Z"}
+spec: {replicas: 2}
+`,
+			want: `"Documents: expected 2 documents but got 1\nDocument 1:\n{\n    \"apiVersion\": \"apps/v1\",\n    \"kind\": \"Deployment\",\n    \"metadata\": {\n        \"creationTimestamp\": \"2022-05-02T22:19:45Z\",\n        \"name\": \"app\"\n    },\n    \"spec\": {\n        \"replicas\": \x1b[0;33m3 => 2\x1b[0m\n    }\n}\nDocument 2: is expected but not present\n"`,
+		},
+		{
+			name:    "invalid",
+			other:   "key: [unclosed",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			y := NewYAMLFromString(deploymentYAML)
+			other := NewYAMLFromString(tt.other)
+			if tt.replacer != nil {
+				y.Replace(tt.replacer)
+				other.Replace(tt.replacer)
+			}
+			got, err := y.CompareTo(other)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CompareTo() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if fmt.Sprintf("%q", got) != tt.want {
+				t.Errorf("CompareTo() got = \n%q\n, want \n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYAML_ReplaceKeepsTypes(t *testing.T) {
+	y := NewYAMLFromString(`base: &base
+  port: 8080
+  ratio: 0.5
+service:
+  <<: *base
+  replicas: 3
+  name: app
+  tags: [a, b]
+  created: 2022-05-02T22:19:45Z
+copy: *base
+`)
+	y.Replace(map[string]string{
+		"service.name":       "replaced",
+		"service.tags.1":     "42",
+		"service.tags.-1":    "c",
+		"copy.port":          "80",
+		"metadata.new\\.key": "added",
+	})
+	const want = `base:
+  port: 8080
+  ratio: 0.5
+copy:
+  port: "80"
+  ratio: 0.5
+metadata:
+  new.key: added
+service:
+  created: 2022-05-02T22:19:45Z
+  name: replaced
+  port: 8080
+  ratio: 0.5
+  replicas: 3
+  tags:
+    - a
+    - "42"
+    - c
+`
+	if got := string(y.Dump()); got != want {
+		t.Errorf("Dump() got = \n%s\n, want \n%s", got, want)
+	}
+}
+
+func TestYAML_Dump(t *testing.T) {
+	const want = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  creationTimestamp: "2022-05-02T22:19:45Z"
+  name: app
+spec:
+  replicas: 3
+---
+apiVersion: v1
+kind: Service
+metadata:
+  creationTimestamp: "2022-05-02T22:19:46Z"
+  name: app
+`
+	if got := string(NewYAMLFromString(deploymentYAML).Dump()); got != want {
+		t.Errorf("Dump() got = \n%s\n, want \n%s", got, want)
+	}
+}
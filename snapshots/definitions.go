@@ -1,6 +1,14 @@
 package snapshots
 
-import "fmt"
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+)
 
 // Kind is used to represent a kind of comparable types, ideally is used to know if two Comparables
 // can compare themselves with custom method, or they need string comparison
@@ -20,6 +28,133 @@ type Comparable interface {
 	ReplaceSubtypes(map[Kind]map[string]string)
 }
 
+// SnapshotLocator can be implemented by Comparables that want to know where their snapshot is stored, ie: to
+// write auxiliary files next to it.
+type SnapshotLocator interface {
+	SetSnapshotPath(string)
+}
+
+// Merger can be implemented by Comparables that keep parts of the previous snapshot when it is updated, ie:
+// markers written by hand.
+type Merger interface {
+	// Merge returns what to store in place of previous.
+	Merge(previous []byte) []byte
+}
+
+// StreamLoader can be implemented by Comparables that load their snapshot from a reader instead of receiving it
+// all in memory.
+type StreamLoader interface {
+	LoadReader(io.Reader) (Comparable, error)
+}
+
+// StreamDumper can be implemented by Comparables that write their snapshot to a writer instead of returning it
+// all in memory.
+type StreamDumper interface {
+	DumpTo(io.Writer) error
+}
+
+// Configurable can be implemented by Comparables that take options from the `options` section of the
+// configuration, they receive the raw json configured for their Kind.
+type Configurable interface {
+	Configure(json.RawMessage) error
+}
+
+// Validator can be implemented by Comparables that can hold something they can not store, ie: a message that can
+// not be marshaled, Validate tells why so it is not written as a snapshot, which would be empty.
+type Validator interface {
+	Validate() error
+}
+
+// Tolerant can be implemented by Comparables that allow some difference before failing, ie: images of lossy
+// formats, it is set per assertion with expect.Tolerance.
+type Tolerant interface {
+	SetTolerance(float64) error
+}
+
+// ContextComparer can be implemented by Comparables whose comparisons can take long, they stop when ctx is done
+// and return a DiffSummary, or the part of the difference found, instead of the whole difference. Comparisons of
+// Comparables that do not implement it are left running when they take too long.
+type ContextComparer interface {
+	CompareToContext(ctx context.Context, c Comparable) (string, error)
+}
+
+// DiffSummary returns a short description of how expected and got differ, for when the whole difference takes too
+// long to compute, or "" if they do not.
+func DiffSummary(expected, got []byte) string {
+	at := 0
+	for at < len(expected) && at < len(got) && expected[at] == got[at] {
+		at++
+	}
+	return diffSummary(int64(at), int64(len(expected)), int64(len(got)))
+}
+
+// StreamDiffSummary is DiffSummary for contents too large to be held in memory, they are read from expected and got.
+func StreamDiffSummary(expected, got io.Reader) (string, error) {
+	er, gr := bufio.NewReader(expected), bufio.NewReader(got)
+	var at, expectedSize, gotSize int64
+	differs := false
+	for {
+		eb, eerr := er.ReadByte()
+		if eerr != nil && eerr != io.EOF {
+			return "", eerr
+		}
+		gb, gerr := gr.ReadByte()
+		if gerr != nil && gerr != io.EOF {
+			return "", gerr
+		}
+		if eerr == io.EOF && gerr == io.EOF {
+			break
+		}
+		if eerr == nil {
+			expectedSize++
+		}
+		if gerr == nil {
+			gotSize++
+		}
+		if !differs {
+			differs = eerr != nil || gerr != nil || eb != gb
+		}
+		if !differs {
+			at++
+		}
+	}
+	return diffSummary(at, expectedSize, gotSize), nil
+}
+
+func diffSummary(at, expectedSize, gotSize int64) string {
+	if at == expectedSize && at == gotSize {
+		return ""
+	}
+	return fmt.Sprintf("differs at byte %d, sizes %d vs %d (the comparison took too long to show the whole difference)\n",
+		at, expectedSize, gotSize)
+}
+
+const sidecarMark = ".sidecar."
+
+// sidecarName matches the file names SidecarPath produces, the snapshot is what comes before the last mark that is
+// followed by a role and an extension.
+var sidecarName = regexp.MustCompile(`^(.+)\.sidecar\.[^.]+(\.[^.]+)+$`)
+
+// SidecarPath returns the path for an auxiliary file of the snapshot in snapshotPath, suffix is a role and an
+// extension (ie: "diff.png" or "actual.pb.json"). Sidecars are never taken for snapshots when cleaning up.
+func SidecarPath(snapshotPath, suffix string) string {
+	return snapshotPath + sidecarMark + suffix
+}
+
+// IsSidecar returns true if fileName has the form of the ones produced by SidecarPath.
+func IsSidecar(fileName string) bool {
+	return sidecarName.MatchString(filepath.Base(fileName))
+}
+
+// SidecarOf returns the path of the snapshot the sidecar in fileName belongs to, or "" if it is not a sidecar.
+func SidecarOf(fileName string) string {
+	m := sidecarName.FindStringSubmatch(filepath.Base(fileName))
+	if m == nil {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(fileName), m[1])
+}
+
 // CantCompare constructs a valid ErrCannotCompare
 func CantCompare(source, target string) error {
 	return &ErrCannotCompare{
@@ -0,0 +1,226 @@
+package snapshots
+
+import (
+	"fmt"
+	"html"
+	"os"
+)
+
+// Style is the role of a piece of a difference, themes decide how each one looks.
+type Style int
+
+const (
+	// StyleDeleted is a part of the expectation that is not in the result.
+	StyleDeleted Style = iota
+	// StyleInserted is a part of the result that is not in the expectation.
+	StyleInserted
+	// StyleRemoved is an element of a structured document, ie: a JSON property, that is not in the result.
+	StyleRemoved
+	// StyleAdded is an element of a structured document that is not in the expectation.
+	StyleAdded
+	// StyleChanged is a value that is different in the expectation and the result.
+	StyleChanged
+	// StyleSkipped is a part of a structured document that is equal in both and was left out.
+	StyleSkipped
+	// StyleDeletedLine is a whole line that is not in the result, in line based differences.
+	StyleDeletedLine
+	// StyleInsertedLine is a whole line that is not in the expectation, in line based differences.
+	StyleInsertedLine
+	// StyleHighlight marks the changed part within a changed line.
+	StyleHighlight
+	// StyleHeader is the header of a difference, ie: the file names of a unified diff.
+	StyleHeader
+	// StyleHunk is the header of each hunk of a line based difference.
+	StyleHunk
+)
+
+// Tag is what goes before and after a piece of text in a Style.
+type Tag struct {
+	Begin string
+	End   string
+}
+
+// Theme decides how the differences look.
+type Theme struct {
+	Name   string
+	Styles map[Style]Tag
+	// Elision is printed instead of the unchanged parts that are left out.
+	Elision string
+	// Escape, if set, is applied to the text before styling it, ie: to escape html.
+	Escape func(string) string
+}
+
+// Paint returns text, escaped, in the passed style.
+func (t Theme) Paint(s Style, text string) string {
+	return t.Wrap(s, t.EscapeText(text))
+}
+
+// Wrap returns text, which must be already escaped, in the passed style.
+func (t Theme) Wrap(s Style, text string) string {
+	if text == "" {
+		return ""
+	}
+	tag := t.Styles[s]
+	return tag.Begin + text + tag.End
+}
+
+// EscapeText returns text escaped as the theme needs it but without any style.
+func (t Theme) EscapeText(text string) string {
+	if t.Escape != nil {
+		return t.Escape(text)
+	}
+	return text
+}
+
+var (
+	// ThemeANSI colors the differences with ANSI escape sequences, this is the look expect always had.
+	ThemeANSI = Theme{
+		Name: "ansi",
+		Styles: map[Style]Tag{
+			StyleDeleted:      {Begin: "\x1b[31m", End: "\x1b[0m"},
+			StyleInserted:     {Begin: "\x1b[32m", End: "\x1b[0m"},
+			StyleRemoved:      {Begin: "\x1b[0;31m", End: "\x1b[0m"},
+			StyleAdded:        {Begin: "\x1b[0;32m", End: "\x1b[0m"},
+			StyleChanged:      {Begin: "\x1b[0;33m", End: "\x1b[0m"},
+			StyleSkipped:      {Begin: "\x1b[0;90m", End: "\x1b[0m"},
+			StyleDeletedLine:  {Begin: "\x1b[31m", End: "\x1b[0m"},
+			StyleInsertedLine: {Begin: "\x1b[32m", End: "\x1b[0m"},
+			StyleHighlight:    {Begin: "\x1b[7m", End: "\x1b[27m"},
+			StyleHeader:       {Begin: "\x1b[1m", End: "\x1b[0m"},
+			StyleHunk:         {Begin: "\x1b[36m", End: "\x1b[0m"},
+		},
+		Elision: "\x1b[33m[...]\x1b[0m",
+	}
+	// ThemePlain uses no escape sequences, inline differences are marked as {-deleted-} and {+inserted+} while
+	// line based ones are left as they are, so they can be used as patches.
+	ThemePlain = Theme{
+		Name: "plain",
+		Styles: map[Style]Tag{
+			StyleDeleted:  {Begin: "{-", End: "-}"},
+			StyleInserted: {Begin: "{+", End: "+}"},
+			StyleRemoved:  {Begin: "{-", End: "-}"},
+			StyleAdded:    {Begin: "{+", End: "+}"},
+			StyleChanged:  {Begin: "{~", End: "~}"},
+		},
+		Elision: "{=...=}",
+	}
+	// ThemeHTML produces html, to be placed inside a <pre> tag, with the differences in <del>, <ins> and <mark>
+	// tags and the rest in spans with a class per style.
+	ThemeHTML = Theme{
+		Name: "html",
+		Styles: map[Style]Tag{
+			StyleDeleted:      {Begin: "<del>", End: "</del>"},
+			StyleInserted:     {Begin: "<ins>", End: "</ins>"},
+			StyleRemoved:      {Begin: "<del>", End: "</del>"},
+			StyleAdded:        {Begin: "<ins>", End: "</ins>"},
+			StyleChanged:      {Begin: `<span class="changed">`, End: "</span>"},
+			StyleSkipped:      {Begin: `<span class="skipped">`, End: "</span>"},
+			StyleDeletedLine:  {Begin: "<del>", End: "</del>"},
+			StyleInsertedLine: {Begin: "<ins>", End: "</ins>"},
+			StyleHighlight:    {Begin: "<mark>", End: "</mark>"},
+			StyleHeader:       {Begin: "<b>", End: "</b>"},
+			StyleHunk:         {Begin: `<span class="hunk">`, End: "</span>"},
+		},
+		Elision: `<span class="elided">[...]</span>`,
+		Escape:  html.EscapeString,
+	}
+)
+
+// ThemeByName returns one of the provided themes by its name.
+func ThemeByName(name string) (Theme, error) {
+	for _, t := range []Theme{ThemeANSI, ThemePlain, ThemeHTML} {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return Theme{}, fmt.Errorf("unknown theme %q", name)
+}
+
+// Layout is how line based differences are arranged.
+type Layout string
+
+const (
+	// LayoutInline prints the differences one after the other.
+	LayoutInline Layout = "inline"
+	// LayoutSideBySide prints the expectation and the result in two columns.
+	LayoutSideBySide Layout = "side_by_side"
+)
+
+// DefaultWidth is the width of side by side differences when none is set.
+const DefaultWidth = 160
+
+// MinWidth is the narrowest side by side difference, with columns of 10 characters.
+const MinWidth = 23
+
+// Renderer holds how differences are rendered by the comparables.
+type Renderer struct {
+	Theme  Theme
+	Layout Layout
+	// Width is the total width of side by side differences.
+	Width int
+}
+
+// ColumnWidth returns the width of each side of a side by side difference, which are separated by a 3
+// characters gutter. Widths below MinWidth are taken as MinWidth.
+func (r Renderer) ColumnWidth() int {
+	width := r.Width
+	if width <= 0 {
+		width = DefaultWidth
+	}
+	if width < MinWidth {
+		width = MinWidth
+	}
+	return (width - 3) / 2
+}
+
+// DefaultRenderer is used by the comparables that were not passed one, it keeps the colored look.
+var DefaultRenderer = Renderer{Theme: ThemeANSI, Layout: LayoutInline}
+
+// Rendering can be implemented by Comparables that print their differences through a Renderer.
+type Rendering interface {
+	SetRenderer(Renderer)
+}
+
+// ColorMode decides if colors are used when no theme is configured.
+type ColorMode string
+
+const (
+	// ColorAuto uses colors unless NO_COLOR is set or the terminal, per TERM, does not support them.
+	ColorAuto ColorMode = "auto"
+	// ColorAlways uses colors.
+	ColorAlways ColorMode = "always"
+	// ColorNever does not use colors.
+	ColorNever ColorMode = "never"
+)
+
+// ParseColorMode returns the ColorMode named s.
+func ParseColorMode(s string) (ColorMode, error) {
+	switch m := ColorMode(s); m {
+	case ColorAuto, ColorAlways, ColorNever:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown color mode %q, expected auto, always or never", s)
+}
+
+// Enabled tells if colors should be used, see https://no-color.org for NO_COLOR.
+func (m ColorMode) Enabled() bool {
+	switch m {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	term := os.Getenv("TERM")
+	return term != "" && term != "dumb"
+}
+
+// Theme returns the ANSI theme if colors are enabled or the plain one otherwise.
+func (m ColorMode) Theme() Theme {
+	if m.Enabled() {
+		return ThemeANSI
+	}
+	return ThemePlain
+}
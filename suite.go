@@ -0,0 +1,124 @@
+package expect
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"perri.to/expect/snapshots"
+)
+
+// Suite is a set of snapshots, with its own configuration, names, mode and report, that is compared and cleaned
+// up independently of other suites in the same package, ie: the golden files of two different tools:
+//
+//	var toolA = expect.NewSuite(expect.WithSnapshotDir("testdata/tool_a"))
+//	var toolB = expect.NewSuite(expect.WithSnapshotDir("testdata/tool_b"))
+//
+// Suites must not share a snapshot directory, the cleanup of one would delete the snapshots of the other. The package
+// level functions use a default suite.
+type Suite struct {
+	// opts are applied to all the assertions of the suite, before their own.
+	opts []Option
+	// args is the mode, read from the flags of the test binary.
+	args *Args
+	// mutex guards names, files and ran.
+	mutex sync.Mutex
+	// names holds the names of the snapshots compared, which must be unique within the suite.
+	names map[string]bool
+	// files holds the file names of the snapshots compared, which the cleanup keeps. Extensions can have more than
+	// one dot, so names can not be told from the file names.
+	files map[string]bool
+	// ran is set if we ran at least one test.
+	ran bool
+	// started is when the suite was created, sidecars older than it were not written by a comparison of this run. It
+	// is truncated to the second, file times are coarser than the clock.
+	started time.Time
+	report  *Report
+}
+
+// defaultSuite is the one used by the package level functions.
+var defaultSuite = NewSuite()
+
+// NewSuite returns a suite whose assertions are made with opts, on top of the configuration, and in the mode set by
+// the flags of the test binary or by WithUpdate and WithCleanup.
+func NewSuite(opts ...Option) *Suite {
+	args := parseArgs(os.Args)
+	// the mode does not depend on the configuration.
+	mode := newAssertion(&Config{}, opts...)
+	args.shouldUpdate = args.shouldUpdate || mode.update
+	args.shouldCleanup = args.shouldCleanup || mode.cleanup
+	return &Suite{
+		opts:    opts,
+		args:    args,
+		names:   map[string]bool{},
+		files:   map[string]bool{},
+		started: time.Now().Truncate(time.Second),
+		report:  &Report{},
+	}
+}
+
+// registerTestName registers the snapshot testName, stored in the file fileName, as compared in this run.
+func (s *Suite) registerTestName(testName, fileName string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.ran = true // we ran at least once
+	if s.names[testName] {
+		return &ErrRepeated{repeatedSnapshot: testName}
+	}
+	s.names[testName] = true
+	s.files[fileName] = true
+	return nil
+}
+
+// FromSnapshot will fail if the stored information is not equal (in a non-agnostic comparison) to the passed
+// comparabletypes, see the package level FromSnapshot. The opts are applied after the ones of the suite.
+func (s *Suite) FromSnapshot(t *testing.T, name string, comparable snapshots.Comparable, opts ...Option) {
+	config, err := ReadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newAssertion(config, append(s.opts[:len(s.opts):len(s.opts)], opts...)...)
+	if a.err != nil {
+		t.Fatal(a.err)
+	}
+	if a.tolerance != nil {
+		tolerant, ok := comparable.(snapshots.Tolerant)
+		if !ok {
+			t.Fatal(fmt.Errorf("%s comparables do not take a tolerance", comparable.Kind()))
+		}
+		if err := tolerant.SetTolerance(*a.tolerance); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.doCompareAndEvaluateResultWithConfig(t, name, comparable, a.limitOS, a.config)
+}
+
+// Cleanup should be called in TestMain AFTER m.Run() to remove the stale snapshots of the suite.
+func (s *Suite) Cleanup() error {
+	config, err := ReadConfig()
+	if err != nil {
+		return fmt.Errorf("cleaning up stale snapshots: %w", err)
+	}
+	return s.cleanupAndReport(newAssertion(config, s.opts...).config, false)
+}
+
+// MustCleanup will do exactly as Cleanup but also fail if a cleanup was due and no flag was passed.
+func (s *Suite) MustCleanup() error {
+	config, err := ReadConfig()
+	if err != nil {
+		return fmt.Errorf("cleaning up stale snapshots: %w", err)
+	}
+	return s.cleanupAndReport(newAssertion(config, s.opts...).config, true)
+}
+
+// Finish should be called in TestMain AFTER m.Run(), for the suites that are not cleaned up, to write the run
+// report of the suite if it is enabled.
+func (s *Suite) Finish() error {
+	config, err := ReadConfig()
+	if err != nil {
+		return fmt.Errorf("finishing run: %w", err)
+	}
+	return s.writeReport(newAssertion(config, s.opts...).config)
+}
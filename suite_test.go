@@ -0,0 +1,45 @@
+package expect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"perri.to/expect/snapshots/comparabletypes"
+)
+
+func TestSuite(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	a := NewSuite(WithSnapshotDir(dirA), WithUpdate(), WithCleanup())
+	b := NewSuite(WithSnapshotDir(dirB), WithUpdate())
+	// the same name is used by both suites, which do not share their names.
+	a.FromSnapshot(t, "golden", comparabletypes.NewStringComparable("from a"))
+	b.FromSnapshot(t, "golden", comparabletypes.NewStringComparable("from b"))
+	for dir, want := range map[string]string{dirA: "from a", dirB: "from b"} {
+		fc, err := readFileContents(filepath.Join(dir, "golden.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(fc.body) != want {
+			t.Errorf("snapshot in %s is %q, want %q", dir, fc.body, want)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "stale.txt"), []byte("{}\n\nstale"), snapshotFilePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := a.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dirA, "stale.txt")); err == nil {
+		t.Errorf("the stale snapshot of the cleaned up suite was kept")
+	}
+	for _, p := range []string{filepath.Join(dirA, "golden.txt"), filepath.Join(dirB, "stale.txt")} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("%s was deleted: %v", p, err)
+		}
+	}
+	if err := b.Cleanup(); err != nil {
+		t.Errorf("Cleanup() of a suite in the default mode = %v", err)
+	}
+}